@@ -1,80 +1,225 @@
 package schema2code
 
 import (
-	"errors"
-	"fmt"
 	"github.com/azurity/schema2code/common"
 	"github.com/azurity/schema2code/golang"
+	"github.com/azurity/schema2code/ir"
 	"github.com/azurity/schema2code/schemas"
 	"github.com/azurity/schema2code/typescript"
 	"io"
-	"strings"
+	"os"
 )
 
 type CommonConfig = common.CommonConfig
+
+// IConfig is implemented by the config of every backend.
+type IConfig = common.IConfig
+
 type GolangConfig = golang.Config
 type TypescriptConfig = typescript.TypescriptConfig
-type TypeDesc = common.TypeDesc
+type TypeOverride = common.TypeOverride
+
+// Error is returned by Generate for problems tied to a place in a schema, and
+// ValidationError when schemas break their meta-schema.
+type Error = schemas.Error
+type Location = schemas.Location
+type ValidationError = schemas.ValidationError
 
-func walkDefs(baseKey []string, defs schemas.Definitions, action func(key []string, item *schemas.Type) error) error {
-	if defs == nil {
-		return nil
+// Generate reads a schema from reader and writes the generated code to writer.
+// Relative $ref are resolved against the current working directory unless the
+// schema declares an absolute $id.
+func Generate(reader io.Reader, writer io.Writer, config interface{}) error {
+	return GenerateToSink(reader, &writerSink{writer: writer}, config)
+}
+
+// GenerateToSink works like Generate, writing the generated files to output.
+func GenerateToSink(reader io.Reader, output Sink, config interface{}) error {
+	wd, err := os.Getwd()
+	if err != nil {
+		return err
 	}
-	for key, item := range defs {
-		newKey := append(baseKey, key)
-		if err := action(append([]string{}, newKey...), item); err != nil {
-			return err
-		}
-		if err := walkDefs(newKey, item.Definitions, action); err != nil {
-			return err
-		}
+	baseURL, err := schemas.FileURL(wd)
+	if err != nil {
+		return err
 	}
-	return nil
+	casedConfig := config.(common.IConfig).Common()
+	format := casedConfig.Format
+	if format == "" {
+		format = schemas.FormatJSON
+	}
+	raw, err := schemas.ReadDocument(baseURL+"/", reader, format)
+	if err != nil {
+		return err
+	}
+	return generate([]source{{raw, casedConfig.RootType}}, output, config)
 }
 
-//func walkInlineType(index *uint64, item *schemas.Type, action func(index uint64, item *schemas.Type)) {
-//	// TODO:
-//}
+// GenerateFile works like Generate, reading the schema from fileName and
+// resolving relative $ref against it.
+func GenerateFile(fileName string, writer io.Writer, config interface{}) error {
+	return GenerateFileToSink(fileName, &writerSink{writer: writer}, config)
+}
 
-func Generate(reader io.Reader, writer io.Writer, config interface{}) error {
-	schema, err := schemas.FromJSONReader(reader)
+// GenerateFileToSink works like GenerateFile, writing the generated files to
+// output.
+func GenerateFileToSink(fileName string, output Sink, config interface{}) error {
+	casedConfig := config.(common.IConfig).Common()
+	raw, err := readFile(fileName, casedConfig.Format)
 	if err != nil {
 		return err
 	}
+	return generate([]source{{raw, casedConfig.RootType}}, output, config)
+}
 
-	casedConfig := config.(common.IConfig).Common()
-	types := map[string]*TypeDesc{}
-	if schema.ObjectAsType != nil {
-		if casedConfig.RootType == "" {
-			return errors.New("need a root-type name")
-		}
-		rootType := schemas.Type(*schema.ObjectAsType)
-		types[casedConfig.RootType] = &TypeDesc{
-			Path: []string{},
-			Type: &rootType,
+// source is a schema document to generate, with the name of its root type.
+type source struct {
+	raw      *schemas.RawDocument
+	rootType string
+}
+
+func readFile(fileName string, format string) (*schemas.RawDocument, error) {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	baseURL, err := schemas.FileURL(fileName)
+	if err != nil {
+		return nil, err
+	}
+	if format == "" {
+		format = schemas.FormatFromFileName(fileName)
+	}
+	return schemas.ReadDocument(baseURL, file, format)
+}
+
+// built is a module ready to render.
+type built struct {
+	module *ir.Module
+	// hash covers everything the code depends on: the options and the
+	// documents.
+	hash string
+	// files are the local files of the sources and of the documents they
+	// reference, those which could not be loaded included.
+	files []string
+	// resolver holds the documents of inputs and those they reference.
+	resolver *schemas.Resolver
+	inputs   []ir.Input
+}
+
+// build loads sources and builds the module to generate. On failure, the
+// files loaded until then are returned with the error.
+func build(sources []source, config interface{}) (*built, error) {
+	result, err := load(sources, config)
+	if err != nil {
+		return result, err
+	}
+	return result, result.buildModule(config)
+}
+
+// load reads sources and the documents they reference, and hashes them, which
+// needs no module. On failure, the files loaded until then are returned with
+// the error.
+func load(sources []source, config interface{}) (*built, error) {
+	result := &built{}
+	for _, item := range sources {
+		if fileName, ok := schemas.FilePath(item.raw.URL); ok {
+			result.files = append(result.files, fileName)
 		}
 	}
-	err = walkDefs([]string{}, schema.Definitions, func(key []string, item *schemas.Type) error {
-		unifiedName := strings.Join(key, "/")
-		if _, ok := types[unifiedName]; ok {
-			return errors.New(fmt.Sprintf("duplicate name %s", unifiedName))
+
+	casedConfig := config.(common.IConfig).Common()
+	draft, err := schemas.ParseDraft(casedConfig.Draft)
+	if err != nil {
+		return result, err
+	}
+
+	resolver := schemas.NewResolver(schemas.NewLoader(""))
+	result.resolver = resolver
+	defer func() {
+		result.files = documentFiles(resolver, result.files)
+	}()
+	resolver.SetDraft(draft)
+	if !casedConfig.NoValidate {
+		resolver.SetValidation(&schemas.ValidateOptions{
+			DisallowUnknownKeywords: casedConfig.DisallowUnknownKeywords,
+		})
+	}
+	for _, item := range sources {
+		doc, err := resolver.LoadDocument(item.raw)
+		if err != nil {
+			return result, err
 		}
-		types[unifiedName] = &TypeDesc{
-			Path: key,
-			Type: item,
+		result.inputs = append(result.inputs, ir.Input{Doc: doc, RootType: item.rootType})
+	}
+	// the documents which fail to load are reported by the build, where
+	// they are used, and their files are watched until they do
+	for _, docURL := range resolver.LoadReferenced() {
+		if fileName, ok := schemas.FilePath(docURL); ok {
+			result.files = append(result.files, fileName)
 		}
-		return nil
+	}
+	result.hash, err = sourceHash(resolver, config)
+	if err != nil {
+		return result, err
+	}
+	return result, nil
+}
+
+// buildModule builds the module of the loaded documents.
+func (result *built) buildModule(config interface{}) error {
+	casedConfig := config.(common.IConfig).Common()
+	module, err := ir.Build(result.resolver, result.inputs, &ir.Options{
+		Overrides: casedConfig.Overrides,
+		Include:   casedConfig.Include,
+		Exclude:   casedConfig.Exclude,
+		Reachable: casedConfig.Reachable,
+		Hoist:     !casedConfig.NoHoist,
+		Dedupe:    ir.Dedupe(casedConfig.Dedupe),
 	})
 	if err != nil {
 		return err
 	}
+	result.module = module
+	return nil
+}
+
+// documentFiles adds the local files of the documents loaded by resolver to
+// files.
+func documentFiles(resolver *schemas.Resolver, files []string) []string {
+	seen := map[string]bool{}
+	for _, fileName := range files {
+		seen[fileName] = true
+	}
+	for _, doc := range resolver.Documents() {
+		if fileName, ok := schemas.FilePath(doc.URL); ok && !seen[fileName] {
+			seen[fileName] = true
+			files = append(files, fileName)
+		}
+	}
+	return files
+}
+
+// render writes the files of module with the backend of config, each after
+// the header recording hash.
+func render(module *ir.Module, hash string, output Sink, config interface{}) error {
+	backend, err := backendFor(config)
+	if err != nil {
+		return err
+	}
+	comment := "//"
+	if commenter, ok := config.(LineCommenter); ok {
+		comment = commenter.LineComment()
+	} else if commenter, ok := backend.(LineCommenter); ok {
+		comment = commenter.LineComment()
+	}
+	return backend.Generate(module, config, &headerSink{output, header(comment, hash)})
+}
 
-	switch config.(type) {
-	case *GolangConfig:
-		return golang.GenerateCode(types, config.(*GolangConfig), writer)
-	case *TypescriptConfig:
-		return typescript.GenerateCode(types, config.(*TypescriptConfig), writer)
-	default:
-		return errors.New("unknown config type")
+func generate(sources []source, output Sink, config interface{}) error {
+	result, err := build(sources, config)
+	if err != nil {
+		return err
 	}
+	return render(result.module, result.hash, output, config)
 }
@@ -0,0 +1,114 @@
+package schema2code
+
+import (
+	"errors"
+	"fmt"
+	"github.com/azurity/schema2code/golang"
+	"github.com/azurity/schema2code/ir"
+	"github.com/azurity/schema2code/typescript"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Backend generates code in one language. Generate and the project files
+// parse, validate and resolve the schemas, then hand the resulting module to
+// the backend.
+type Backend interface {
+	// Name selects the backend, such as "go" in project files and the command
+	// line.
+	Name() string
+	// NewConfig returns a new config of the backend, with its default options.
+	// It must be a pointer implementing common.IConfig, usually a struct
+	// embedding CommonConfig, and it selects the backend in Generate.
+	NewConfig() interface{}
+	// Generate writes the files of module to output. Each file starts with
+	// the header of generated files already.
+	Generate(module *ir.Module, config interface{}, output Sink) error
+}
+
+// LineCommenter is implemented by backends, or their config, whose language
+// does not start line comments with "//", for the header of generated files.
+type LineCommenter interface {
+	LineComment() string
+}
+
+var (
+	backendsMu sync.RWMutex
+	backends   = map[string]Backend{}
+)
+
+func init() {
+	RegisterBackend(golang.Backend{}, "golang")
+	RegisterBackend(typescript.Backend{}, "typescript")
+}
+
+// RegisterBackend makes backend available under its name and aliases. It
+// panics when a name is taken already.
+func RegisterBackend(backend Backend, aliases ...string) {
+	backendsMu.Lock()
+	defer backendsMu.Unlock()
+	for _, name := range append([]string{backend.Name()}, aliases...) {
+		if _, ok := backends[name]; ok {
+			panic(fmt.Sprintf("schema2code: backend %s registered twice", name))
+		}
+		backends[name] = backend
+	}
+}
+
+// LookupBackend returns the backend registered under name, or else the plugin
+// of that name found on PATH.
+func LookupBackend(name string) (Backend, bool) {
+	backendsMu.RLock()
+	backend, ok := backends[name]
+	backendsMu.RUnlock()
+	if ok {
+		return backend, true
+	}
+	if plugin, err := FindPlugin(name); err == nil {
+		return plugin, true
+	}
+	return nil, false
+}
+
+// Backends returns the names of the registered backends, aliases excluded.
+func Backends() []string {
+	backendsMu.RLock()
+	defer backendsMu.RUnlock()
+	names := []string{}
+	for name, backend := range backends {
+		if backend.Name() == name {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Languages returns the names of the registered backends, then those of the
+// plugins found on PATH.
+func Languages() []string {
+	return append(Backends(), Plugins()...)
+}
+
+// backendFor returns the backend named by config, as PluginConfig does, or
+// else the one whose config has the type of config.
+func backendFor(config interface{}) (Backend, error) {
+	if named, ok := config.(interface{ BackendName() string }); ok {
+		backend, ok := LookupBackend(named.BackendName())
+		if !ok {
+			return nil, errors.New(fmt.Sprintf("unknown language %q, expected one of %s", named.BackendName(), strings.Join(Languages(), ", ")))
+		}
+		return backend, nil
+	}
+	backendsMu.RLock()
+	defer backendsMu.RUnlock()
+	configType := reflect.TypeOf(config)
+	for name, backend := range backends {
+		if backend.Name() == name && reflect.TypeOf(backend.NewConfig()) == configType {
+			return backend, nil
+		}
+	}
+	return nil, errors.New(fmt.Sprintf("no backend registered for config %T", config))
+}
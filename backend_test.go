@@ -0,0 +1,72 @@
+package schema2code
+
+import (
+	"fmt"
+	"github.com/azurity/schema2code/common"
+	"github.com/azurity/schema2code/ir"
+	"strings"
+	"testing"
+)
+
+type namesConfig struct {
+	CommonConfig
+}
+
+func (c *namesConfig) LineComment() string {
+	return "#"
+}
+
+// namesBackend writes the names of the types, one per line.
+type namesBackend struct{}
+
+func (namesBackend) Name() string {
+	return "names"
+}
+
+func (namesBackend) NewConfig() interface{} {
+	return &namesConfig{}
+}
+
+func (namesBackend) Generate(module *ir.Module, config interface{}, output Sink) error {
+	lines := []string{}
+	for _, value := range module.Types {
+		lines = append(lines, fmt.Sprintf("%s %s", value.Name, value.Node.Kind))
+	}
+	return common.WriteFile(output, "names.txt", []byte(strings.Join(lines, "\n")+"\n"))
+}
+
+func init() {
+	RegisterBackend(namesBackend{}, "type-names")
+}
+
+func TestRegisterBackend(t *testing.T) {
+	backend, ok := LookupBackend("type-names")
+	if !ok || backend.Name() != "names" {
+		t.Fatalf("alias: got %v", backend)
+	}
+	found := false
+	for _, name := range Backends() {
+		found = found || name == "names"
+		if name == "type-names" {
+			t.Errorf("aliases are listed by Backends")
+		}
+	}
+	if !found {
+		t.Errorf("names is not listed by Backends")
+	}
+
+	code := &strings.Builder{}
+	if err := Generate(strings.NewReader(`{"$defs": {"a": {"type": "string"}, "b": {"type": "object"}}}`), code, &namesConfig{}); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(code.String(), "# "+GeneratedHeader+"\n") || !strings.HasSuffix(code.String(), "\n\na string\nb object\n") {
+		t.Errorf("got\n%s", code)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("registering a name twice does not panic")
+		}
+	}()
+	RegisterBackend(namesBackend{})
+}
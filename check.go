@@ -0,0 +1,183 @@
+package schema2code
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"github.com/azurity/schema2code/common"
+	"github.com/azurity/schema2code/schemas"
+	"os"
+	"path"
+	"runtime/debug"
+	"sort"
+	"strings"
+)
+
+// GeneratedHeader is the first line of generated files, after the line
+// comment marker of the language.
+const GeneratedHeader = "Code generated by schema2code. DO NOT EDIT."
+
+const hashPrefix = "schema2code-hash: "
+
+func header(comment string, hash string) string {
+	return comment + " " + GeneratedHeader + "\n" + comment + " " + hashPrefix + hash + "\n\n"
+}
+
+const modulePath = "github.com/azurity/schema2code"
+
+// generatorVersion identifies the build of schema2code: the version of its
+// module, and the revision it was built from when it is the main module.
+func generatorVersion() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return ""
+	}
+	module := &info.Main
+	for _, dep := range info.Deps {
+		if dep.Path == modulePath {
+			module = dep
+			if dep.Replace != nil {
+				module = dep.Replace
+			}
+		}
+	}
+	version := module.Path + "@" + module.Version + " " + module.Sum
+	if module == &info.Main {
+		for _, setting := range info.Settings {
+			if setting.Key == "vcs.revision" || setting.Key == "vcs.modified" {
+				version += " " + setting.Value
+			}
+		}
+	}
+	return version
+}
+
+// sourceHash hashes the version of schema2code, the options and every
+// document loaded by resolver, which are all the generated code depends on.
+func sourceHash(resolver *schemas.Resolver, config interface{}) (string, error) {
+	options, err := json.Marshal(config)
+	if err != nil {
+		return "", err
+	}
+	hash := sha256.New()
+	write := func(data []byte) {
+		size := [8]byte{}
+		binary.BigEndian.PutUint64(size[:], uint64(len(data)))
+		hash.Write(size[:])
+		hash.Write(data)
+	}
+	write([]byte(generatorVersion()))
+	write(options)
+	for _, doc := range resolver.Documents() {
+		write(doc.Source)
+	}
+	return "sha256:" + hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// headerHash returns the hash recorded in the header of generated code.
+func headerHash(code []byte) string {
+	scanner := bufio.NewScanner(bytes.NewReader(code))
+	for i := 0; i < 3 && scanner.Scan(); i += 1 {
+		if index := strings.Index(scanner.Text(), hashPrefix); index >= 0 {
+			return scanner.Text()[index+len(hashPrefix):]
+		}
+	}
+	return ""
+}
+
+// Drift is a generated file which differs from the code its schemas give now.
+type Drift struct {
+	File string
+	// Diff is the unified diff from the file to the expected code.
+	Diff string
+}
+
+// CheckOptions adjust how generated files are checked.
+type CheckOptions struct {
+	// Full compares the code even when the hash in the header of the file
+	// matches the schemas and options. Without it, edits to a file below an
+	// unchanged header go unnoticed.
+	Full bool
+}
+
+// check compares the files at output, shown as name, with those generated from
+// sources. The schemas are only built and the code generated when the hash in
+// a single file is out of date, unless options.Full is set.
+func check(sources []source, config interface{}, output string, name string, options *CheckOptions) ([]Drift, error) {
+	if options == nil {
+		options = &CheckOptions{}
+	}
+	split := config.(common.IConfig).Common().Split
+	current := MemorySink{}
+	if split {
+		var err error
+		if current, err = readOutput(output); err != nil {
+			return nil, err
+		}
+	} else {
+		data, err := os.ReadFile(output)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+		if data != nil {
+			current[""] = data
+		}
+	}
+	result, err := load(sources, config)
+	if err != nil {
+		return nil, err
+	}
+	if !split && !options.Full && current[""] != nil && headerHash(current[""]) == result.hash {
+		return nil, nil
+	}
+	if err := result.buildModule(config); err != nil {
+		return nil, err
+	}
+	generated := MemorySink{}
+	if err := render(result.module, result.hash, generated, config); err != nil {
+		return nil, err
+	}
+	if !split {
+		data, err := singleFile(generated)
+		if err != nil {
+			return nil, err
+		}
+		generated = MemorySink{"": data}
+	}
+
+	names := generated.Names()
+	for _, fileName := range current.Names() {
+		if _, ok := generated[fileName]; !ok {
+			names = append(names, fileName)
+		}
+	}
+	sort.Strings(names)
+	drifts := []Drift{}
+	for _, fileName := range names {
+		if bytes.Equal(current[fileName], generated[fileName]) {
+			continue
+		}
+		shown := path.Join(name, fileName)
+		drifts = append(drifts, Drift{
+			File: shown,
+			Diff: common.UnifiedDiff(shown, shown+" (generated)", current[fileName], generated[fileName]),
+		})
+	}
+	return drifts, nil
+}
+
+// CheckFile reports whether output holds the code generated from the schema
+// fileName, returning the differences when it does not. With
+// CommonConfig.Split, output is the directory or zip archive of the files.
+func CheckFile(fileName string, output string, config interface{}, options *CheckOptions) ([]Drift, error) {
+	casedConfig := config.(common.IConfig).Common()
+	raw, err := readFile(fileName, casedConfig.Format)
+	if err != nil {
+		return nil, err
+	}
+	return check([]source{{raw, casedConfig.RootType}}, config, output, output, options)
+}
@@ -0,0 +1,102 @@
+package schema2code
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCheckFile(t *testing.T) {
+	dir := t.TempDir()
+	schema := filepath.Join(dir, "a.json")
+	referenced := filepath.Join(dir, "b.json")
+	output := filepath.Join(dir, "a.go")
+	write := func(fileName string, data string) {
+		if err := os.WriteFile(fileName, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(schema, `{"$defs": {"item": {"type": "object", "properties": {"price": {"$ref": "b.json"}}}}}`)
+	write(referenced, `{"type": "number"}`)
+	config := &GolangConfig{Package: "gen"}
+	code := &bytes.Buffer{}
+	if err := GenerateFile(schema, code, config); err != nil {
+		t.Fatal(err)
+	}
+	write(output, code.String())
+
+	drifts := func(full bool) int {
+		t.Helper()
+		found, err := CheckFile(schema, output, config, &CheckOptions{Full: full})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return len(found)
+	}
+	if n := drifts(false); n != 0 {
+		t.Errorf("up to date: got %d drifts", n)
+	}
+
+	write(referenced, `{"type": "integer"}`)
+	if n := drifts(false); n != 1 {
+		t.Errorf("referenced schema changed: got %d drifts, want 1", n)
+	}
+	write(referenced, `{"type": "number"}`)
+
+	// the hash in the header is trusted without building the schemas
+	write(output, code.String()+"\nvar edited = 1\n")
+	if n := drifts(false); n != 0 {
+		t.Errorf("edited below the header: got %d drifts without Full", n)
+	}
+	if n := drifts(true); n != 1 {
+		t.Errorf("edited below the header: got %d drifts with Full, want 1", n)
+	}
+}
+
+func TestCheckSplit(t *testing.T) {
+	dir := t.TempDir()
+	schema := filepath.Join(dir, "a.json")
+	output := filepath.Join(dir, "gen")
+	if err := os.WriteFile(schema, []byte(`{"$defs": {"item": {"type": "object"}, "order": {"type": "object"}}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	config := &GolangConfig{}
+	config.Split = true
+	files := MemorySink{}
+	if err := GenerateFileToSink(schema, files, config); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeOutput(output, true, files); err != nil {
+		t.Fatal(err)
+	}
+	// a file of the user is not generated, so not compared
+	if err := os.WriteFile(filepath.Join(output, "extra.go"), []byte("package gen\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	drifts, err := CheckFile(schema, output, config, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(drifts) != 0 {
+		t.Errorf("up to date: got drifts in %v", drifts)
+	}
+
+	if err := os.Remove(filepath.Join(output, "item_gen.go")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(output, "stale_gen.go"), files["order_gen.go"], 0644); err != nil {
+		t.Fatal(err)
+	}
+	drifts, err = CheckFile(schema, output, config, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := []string{}
+	for _, drift := range drifts {
+		got = append(got, filepath.Base(drift.File))
+	}
+	if len(got) != 2 || got[0] != "item_gen.go" || got[1] != "stale_gen.go" {
+		t.Errorf("got drifts in %v, want item_gen.go and stale_gen.go", got)
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+
+	s2c "github.com/azurity/schema2code"
+)
+
+func runCheck(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) error {
+	fs := flag.NewFlagSet("check", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	target := &targetFlags{}
+	target.register(fs)
+	options := &s2c.CheckOptions{}
+	fs.BoolVar(&options.Full, "full", false, "compare the code even when the recorded schema hash matches")
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "usage: schema2code check [--config schema2code.json] [--full]")
+		fmt.Fprintln(stderr, "       schema2code check --lang go|ts --in schema.json --out file [flags]")
+		fs.PrintDefaults()
+	}
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	drifts := []s2c.Drift{}
+	if projectFile := target.projectFile(); projectFile != "" {
+		project, err := s2c.LoadProject(projectFile)
+		if err != nil {
+			return err
+		}
+		drifts, err = project.Check(options)
+		if err != nil {
+			return err
+		}
+	} else {
+		if target.in == "-" || target.out == "-" {
+			return errors.New("check needs --in and --out, or a project file")
+		}
+		config, err := target.langConfig()
+		if err != nil {
+			return err
+		}
+		drifts, err = s2c.CheckFile(target.in, target.out, config, options)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, drift := range drifts {
+		fmt.Fprint(stdout, drift.Diff)
+	}
+	if len(drifts) != 0 {
+		return errors.New(fmt.Sprintf("%d generated file(s) out of date, run schema2code generate", len(drifts)))
+	}
+	return nil
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	s2c "github.com/azurity/schema2code"
+)
+
+// targetFlags are the flags selecting what to generate: a project file, or a
+// single schema with its options.
+type targetFlags struct {
+	config string
+	lang   string
+	in     string
+	out    string
+	pkg    string
+	common s2c.CommonConfig
+}
+
+func (f *targetFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&f.config, "config", "", "project file listing the targets (default: schema2code.json or .yaml in the current directory, when --lang is not given)")
+	fs.StringVar(&f.lang, "lang", "", "output language, one of the registered backends such as go or ts")
+	fs.StringVar(&f.in, "in", "-", "schema file, - for stdin")
+	fs.StringVar(&f.out, "out", "-", "output file, - for stdout")
+	fs.StringVar(&f.pkg, "package", "", "Go package path (default: the name of the output directory)")
+	fs.StringVar(&f.common.RootType, "root-type", "", "name of the type generated for the root schema")
+	fs.StringVar(&f.common.Format, "format", "", "input format, json or yaml (default: from the file extension)")
+	fs.StringVar(&f.common.Draft, "draft", "", "draft of schemas without $schema, such as draft-07 (default 2020-12)")
+	fs.BoolVar(&f.common.NoValidate, "no-validate", false, "skip validating schemas against their meta-schema")
+	fs.Var((*patternList)(&f.common.Include), "include", "generate only the types matching this pattern, such as user/* (repeatable)")
+	fs.Var((*patternList)(&f.common.Exclude), "exclude", "skip the types matching this pattern (repeatable)")
+	fs.BoolVar(&f.common.Reachable, "reachable", false, "also generate the types referenced by the included ones")
+	fs.BoolVar(&f.common.NoHoist, "no-hoist", false, "keep inline objects and enums anonymous instead of naming them")
+	fs.StringVar(&f.common.Dedupe, "dedupe", "", "merge types of the same structure: none, alias or reference (default none)")
+	fs.BoolVar(&f.common.Split, "split", false, "generate a file per type into the --out directory or .zip archive")
+	fs.BoolVar(&f.common.DisallowUnknownKeywords, "disallow-unknown-keywords", false, "report keywords the draft does not define")
+}
+
+// patternList collects the values of a repeated flag, which may also be
+// separated by commas.
+type patternList []string
+
+func (l *patternList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *patternList) Set(value string) error {
+	*l = append(*l, strings.Split(value, ",")...)
+	return nil
+}
+
+// projectFile returns the project file to use, or "" for a single schema.
+func (f *targetFlags) projectFile() string {
+	if f.config == "" && f.lang == "" && f.in == "-" {
+		if found, err := s2c.FindProject("."); err == nil {
+			return found
+		}
+	}
+	return f.config
+}
+
+// langConfig returns the config of the backend selected by --lang.
+func (f *targetFlags) langConfig() (interface{}, error) {
+	if f.lang == "" {
+		return nil, errors.New("missing --lang, or a project file")
+	}
+	backend, ok := s2c.LookupBackend(strings.ToLower(f.lang))
+	if !ok {
+		return nil, errors.New(fmt.Sprintf("unknown language %q, expected one of %s", f.lang, strings.Join(s2c.Languages(), ", ")))
+	}
+	config := backend.NewConfig()
+	*config.(s2c.IConfig).Common() = f.common
+	if config, ok := config.(*s2c.GolangConfig); ok {
+		config.Package = f.pkg
+		if config.Package == "" && f.out != "-" {
+			abs, err := filepath.Abs(f.out)
+			if err != nil {
+				return nil, err
+			}
+			config.Package = s2c.DefaultPackage(abs, f.common.Split)
+		}
+		if config.Package == "" {
+			return nil, errors.New("--package is required when writing Go to stdout")
+		}
+	}
+	return config, nil
+}
+
+// parseFlags parses args, printing the usage on errors.
+func parseFlags(fs *flag.FlagSet, args []string) error {
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return err
+		}
+		return errUsage
+	}
+	if fs.NArg() != 0 {
+		fmt.Fprintf(fs.Output(), "unexpected argument %q\n", fs.Arg(0))
+		fs.Usage()
+		return errUsage
+	}
+	return nil
+}
+
+func runGenerate(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) error {
+	fs := flag.NewFlagSet("generate", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	target := &targetFlags{}
+	target.register(fs)
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "usage: schema2code generate --lang go|ts [flags]")
+		fmt.Fprintln(stderr, "       schema2code generate [--config schema2code.json]")
+		fs.PrintDefaults()
+	}
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	if project := target.projectFile(); project != "" {
+		return s2c.GenerateProject(project)
+	}
+
+	config, err := target.langConfig()
+	if err != nil {
+		return err
+	}
+
+	if target.common.Split {
+		project, err := singleProject(target)
+		if err != nil {
+			return err
+		}
+		return project.Generate()
+	}
+
+	// generate in memory first, so a failure does not truncate the output file
+	buffer := &bytes.Buffer{}
+	if target.in == "-" {
+		err = s2c.Generate(stdin, buffer, config)
+	} else {
+		err = s2c.GenerateFile(target.in, buffer, config)
+	}
+	if err != nil {
+		return err
+	}
+
+	if target.out == "-" {
+		_, err = stdout.Write(buffer.Bytes())
+		return err
+	}
+	return os.WriteFile(target.out, buffer.Bytes(), 0644)
+}
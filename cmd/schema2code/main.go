@@ -0,0 +1,85 @@
+// Command schema2code generates code from JSON Schema.
+//
+// Usage:
+//
+//	schema2code generate --lang go|ts [--in schema.json] [--out file] [--root-type Name] [--package path]
+//
+// The schema is read from stdin and the code written to stdout unless --in and
+// --out are given. Languages other than go and ts are generated by plugins,
+// executables named schema2code-gen-<lang> found on PATH.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	s2c "github.com/azurity/schema2code"
+)
+
+type command struct {
+	summary string
+	run     func(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) error
+}
+
+var commands = map[string]command{
+	"check":    {"check that generated files are up to date", runCheck},
+	"generate": {"generate code from a schema", runGenerate},
+	"watch":    {"generate again whenever the schemas change", runWatch},
+}
+
+// errUsage reports a wrong command line, the usage has been printed already.
+var errUsage = errors.New("usage")
+
+func usage(w io.Writer) {
+	fmt.Fprintln(w, "usage: schema2code <command> [flags]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "commands:")
+	names := []string{}
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(w, "  %-10s %s\n", name, commands[name].summary)
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintf(w, "languages: %s\n", strings.Join(s2c.Languages(), ", "))
+	fmt.Fprintf(w, "other languages are generated by %s<lang> plugins found on PATH\n", s2c.PluginPrefix)
+}
+
+func run(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+	if len(args) == 0 {
+		usage(stderr)
+		return 2
+	}
+	if args[0] == "help" || args[0] == "-h" || args[0] == "--help" {
+		usage(stdout)
+		return 0
+	}
+	cmd, ok := commands[args[0]]
+	if !ok {
+		fmt.Fprintf(stderr, "schema2code: unknown command %q\n", args[0])
+		usage(stderr)
+		return 2
+	}
+	if err := cmd.run(args[1:], stdin, stdout, stderr); err != nil {
+		if errors.Is(err, errUsage) {
+			return 2
+		}
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		fmt.Fprintf(stderr, "schema2code: %s\n", err)
+		return 1
+	}
+	return 0
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
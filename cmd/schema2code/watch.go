@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"time"
+
+	s2c "github.com/azurity/schema2code"
+)
+
+// singleProject makes a project of the schema and options given by flags.
+func singleProject(target *targetFlags) (*s2c.Project, error) {
+	if target.in == "-" || target.out == "-" {
+		return nil, errors.New("needs --in and --out, or a project file")
+	}
+	config, err := target.langConfig()
+	if err != nil {
+		return nil, err
+	}
+	options, err := json.Marshal(config)
+	if err != nil {
+		return nil, err
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		return nil, err
+	}
+	return &s2c.Project{
+		Inputs: []s2c.ProjectInput{{File: target.in}},
+		Targets: []s2c.ProjectTarget{{
+			Lang:    target.lang,
+			Output:  target.out,
+			Options: options,
+		}},
+		Dir: wd,
+	}, nil
+}
+
+func runWatch(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) error {
+	fs := flag.NewFlagSet("watch", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	target := &targetFlags{}
+	target.register(fs)
+	options := &s2c.WatchOptions{}
+	fs.DurationVar(&options.Interval, "interval", 300*time.Millisecond, "how often to look for changed files")
+	fs.DurationVar(&options.Debounce, "debounce", 500*time.Millisecond, "how long files must stay unchanged before generating")
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "usage: schema2code watch [--config schema2code.json] [flags]")
+		fmt.Fprintln(stderr, "       schema2code watch --lang go|ts --in schema.json --out file [flags]")
+		fs.PrintDefaults()
+	}
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	var project *s2c.Project
+	var err error
+	if projectFile := target.projectFile(); projectFile != "" {
+		project, err = s2c.LoadProject(projectFile)
+	} else {
+		project, err = singleProject(target)
+	}
+	if err != nil {
+		return err
+	}
+
+	options.Report = func(target *s2c.ProjectTarget, err error) {
+		now := time.Now().Format("15:04:05")
+		if err != nil {
+			fmt.Fprintf(stderr, "%s schema2code: %s: %s\n", now, target, err)
+			return
+		}
+		fmt.Fprintf(stderr, "%s generated %s\n", now, target)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	return project.Watch(ctx, options)
+}
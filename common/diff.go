@@ -0,0 +1,172 @@
+package common
+
+import (
+	"fmt"
+	"strings"
+)
+
+type editKind int
+
+const (
+	editEqual editKind = iota
+	editDelete
+	editInsert
+)
+
+// edit is a step of an edit script, at line a of the old text and line b of
+// the new one.
+type edit struct {
+	kind editKind
+	a    int
+	b    int
+}
+
+const diffContext = 3
+
+// UnifiedDiff returns the differences between the lines of a and b in the
+// unified format, with three lines of context. It is empty when they are equal.
+func UnifiedDiff(aName, bName string, a, b []byte) string {
+	aLines := splitLines(a)
+	bLines := splitLines(b)
+	edits := lineEdits(aLines, bLines)
+
+	changes := []int{}
+	for i, item := range edits {
+		if item.kind != editEqual {
+			changes = append(changes, i)
+		}
+	}
+	if len(changes) == 0 {
+		return ""
+	}
+
+	out := &strings.Builder{}
+	fmt.Fprintf(out, "--- %s\n+++ %s\n", aName, bName)
+	for i := 0; i < len(changes); {
+		// extend the hunk while the next change is close enough to share context
+		j := i
+		for j+1 < len(changes) && changes[j+1]-changes[j] <= 2*diffContext {
+			j += 1
+		}
+		start := changes[i] - diffContext
+		if start < 0 {
+			start = 0
+		}
+		end := changes[j] + diffContext + 1
+		if end > len(edits) {
+			end = len(edits)
+		}
+		writeHunk(out, edits[start:end], aLines, bLines)
+		i = j + 1
+	}
+	return out.String()
+}
+
+func writeHunk(out *strings.Builder, edits []edit, aLines, bLines []string) {
+	aCount, bCount := 0, 0
+	for _, item := range edits {
+		if item.kind != editInsert {
+			aCount += 1
+		}
+		if item.kind != editDelete {
+			bCount += 1
+		}
+	}
+	fmt.Fprintf(out, "@@ -%s +%s @@\n", hunkRange(edits[0].a, aCount), hunkRange(edits[0].b, bCount))
+	for _, item := range edits {
+		switch item.kind {
+		case editEqual:
+			out.WriteString(" " + aLines[item.a] + "\n")
+		case editDelete:
+			out.WriteString("-" + aLines[item.a] + "\n")
+		case editInsert:
+			out.WriteString("+" + bLines[item.b] + "\n")
+		}
+	}
+}
+
+func hunkRange(start, count int) string {
+	if count == 0 {
+		return fmt.Sprintf("%d,0", start)
+	}
+	if count == 1 {
+		return fmt.Sprintf("%d", start+1)
+	}
+	return fmt.Sprintf("%d,%d", start+1, count)
+}
+
+// splitLines splits text into lines. A last line without newline carries the
+// marker of the unified format, so it differs from the same line with one.
+func splitLines(text []byte) []string {
+	if len(text) == 0 {
+		return []string{}
+	}
+	lines := strings.Split(strings.TrimSuffix(string(text), "\n"), "\n")
+	if text[len(text)-1] != '\n' {
+		lines[len(lines)-1] += "\n\\ No newline at end of file"
+	}
+	return lines
+}
+
+// lineEdits finds a shortest edit script from a to b with the algorithm of
+// Myers.
+func lineEdits(a, b []string) []edit {
+	n, m := len(a), len(b)
+	offset := n + m + 1
+	v := make([]int, 2*offset+1)
+	trace := [][]int{}
+
+search:
+	for d := 0; d <= n+m; d += 1 {
+		trace = append(trace, append([]int{}, v...))
+		for k := -d; k <= d; k += 2 {
+			x := 0
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x += 1
+				y += 1
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				break search
+			}
+		}
+	}
+
+	reversed := []edit{}
+	x, y := n, m
+	for d := len(trace) - 1; d >= 0; d -= 1 {
+		v := trace[d]
+		k := x - y
+		prevK := k - 1
+		if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+			prevK = k + 1
+		}
+		prevX := v[offset+prevK]
+		prevY := prevX - prevK
+		for x > prevX && y > prevY {
+			x -= 1
+			y -= 1
+			reversed = append(reversed, edit{editEqual, x, y})
+		}
+		if d > 0 {
+			if x == prevX {
+				reversed = append(reversed, edit{editInsert, x, prevY})
+			} else {
+				reversed = append(reversed, edit{editDelete, prevX, y})
+			}
+		}
+		x, y = prevX, prevY
+	}
+
+	edits := make([]edit, 0, len(reversed))
+	for i := len(reversed) - 1; i >= 0; i -= 1 {
+		edits = append(edits, reversed[i])
+	}
+	return edits
+}
@@ -0,0 +1,98 @@
+package common
+
+import (
+	"strings"
+	"unicode"
+)
+
+// PointerName derives the path of a type name from the JSON pointer segments
+// leading to an inline schema. Definition and property names are kept, the
+// other keywords are replaced by a short word describing the position.
+func PointerName(pointer []string) []string {
+	name := []string{}
+	for i := 0; i < len(pointer); i += 1 {
+		keyword := pointer[i]
+		hasNext := i+1 < len(pointer)
+		switch keyword {
+		case "$defs", "definitions", "properties", "dependentSchemas", "dependencies":
+			if hasNext {
+				i += 1
+				name = append(name, pointer[i])
+			}
+		case "items", "additionalItems", "contains":
+			if keyword == "items" && hasNext && isIndex(pointer[i+1]) {
+				i += 1
+				name = append(name, "item"+pointer[i])
+			} else {
+				name = append(name, "item")
+			}
+		case "additionalProperties":
+			name = append(name, "value")
+		case "patternProperties":
+			if hasNext {
+				i += 1
+			}
+			name = append(name, "value")
+		case "prefixItems":
+			if hasNext {
+				i += 1
+				name = append(name, "item"+pointer[i])
+			}
+		case "allOf", "anyOf", "oneOf":
+			if hasNext {
+				i += 1
+				name = append(name, keyword+pointer[i])
+			}
+		default:
+			name = append(name, keyword)
+		}
+	}
+	return name
+}
+
+func isIndex(token string) bool {
+	if token == "" {
+		return false
+	}
+	for _, r := range token {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// Identifier turns a name into an exported identifier of Go or TypeScript.
+// The characters an identifier cannot hold are dropped, starting a new
+// word, and "-" becomes "_".
+func Identifier(name string) string {
+	result := []rune{}
+	upper := true
+	for _, r := range strings.ReplaceAll(name, "-", "_") {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_' {
+			upper = true
+			continue
+		}
+		if upper {
+			r = unicode.ToUpper(r)
+			upper = false
+		}
+		result = append(result, r)
+	}
+	if len(result) == 0 || unicode.IsDigit(result[0]) {
+		result = append([]rune{'X'}, result...)
+	}
+	return string(result)
+}
+
+// IdentifierClash returns two of names which become the same identifier.
+func IdentifierClash(names []string) (string, string, bool) {
+	seen := map[string]string{}
+	for _, name := range names {
+		if other, ok := seen[Identifier(name)]; ok {
+			return other, name, true
+		}
+		seen[Identifier(name)] = name
+	}
+	return "", "", false
+}
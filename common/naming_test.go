@@ -0,0 +1,53 @@
+package common
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestIdentifier(t *testing.T) {
+	cases := map[string]string{
+		"name":      "Name",
+		"user-id":   "User_id",
+		"x y":       "XY",
+		"a/b":       "AB",
+		"a.b.c":     "ABC",
+		"2fa":       "X2fa",
+		"":          "X",
+		"/":         "X",
+		"émoji ok":  "ÉmojiOk",
+		"snake_100": "Snake_100",
+	}
+	for name, want := range cases {
+		if got := Identifier(name); got != want {
+			t.Errorf("Identifier(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestIdentifierClash(t *testing.T) {
+	if a, b, ok := IdentifierClash([]string{"x y", "z", "xY"}); !ok || a != "x y" || b != "xY" {
+		t.Errorf("got %q %q %t", a, b, ok)
+	}
+	if _, _, ok := IdentifierClash([]string{"a", "b"}); ok {
+		t.Error("a and b clash")
+	}
+}
+
+func TestPointerName(t *testing.T) {
+	cases := map[string]string{
+		"$defs/order/properties/shipping":                   "order/shipping",
+		"$defs/order/properties/lines/items":                "order/lines/item",
+		"$defs/order/prefixItems/0":                         "order/item0",
+		"$defs/pet/oneOf/1":                                 "pet/oneOf1",
+		"$defs/tags/additionalProperties":                   "tags/value",
+		"$defs/tags/patternProperties/^x-":                  "tags/value",
+		"$defs/a/items/2":                                   "a/item2",
+		"definitions/a/properties/x y/properties/allOf/a/b": "a/x y/allOf/a/b",
+	}
+	for pointer, want := range cases {
+		if got := strings.Join(PointerName(strings.Split(pointer, "/")), "/"); got != want {
+			t.Errorf("PointerName(%s) = %s, want %s", pointer, got, want)
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package common
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Sink receives the files generated for a target.
+type Sink interface {
+	// Create returns the writer of the file name, a path relative to the output
+	// separated by "/". The file is complete once the writer is closed.
+	Create(name string) (io.WriteCloser, error)
+}
+
+// FileName derives a file name, without extension, from the path of a type.
+func FileName(path []string) string {
+	name := strings.ToLower(strings.Join(path, "_"))
+	return strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') || r == '_' {
+			return r
+		}
+		return '_'
+	}, name)
+}
+
+// FileSet tells the files of a sink apart, the types of a module may map to
+// the same file name.
+type FileSet map[string]string
+
+// Add records the file name generated for what, and fails when another one
+// generates it already.
+func (s FileSet) Add(name string, what string) error {
+	if other, ok := s[name]; ok {
+		return errors.New(fmt.Sprintf("%s and %s are both generated into %s", other, what, name))
+	}
+	s[name] = what
+	return nil
+}
+
+// WriteFile creates name in sink and writes data to it.
+func WriteFile(sink Sink, name string, data []byte) error {
+	file, err := sink.Create(name)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
@@ -1,9 +1,50 @@
 package common
 
-import "github.com/azurity/schema2code/schemas"
-
 type CommonConfig struct {
 	RootType string
+	// Format of the input schema, "json" or "yaml". When empty, GenerateFile
+	// picks it from the file extension and Generate reads JSON.
+	Format string
+	// Draft assumed for schemas without $schema, such as "draft-07" or
+	// "2020-12". Defaults to 2020-12.
+	Draft string
+	// NoValidate skips checking the schemas against the meta-schema of their
+	// draft before generating.
+	NoValidate bool
+	// DisallowUnknownKeywords reports keywords the draft does not define as
+	// validation errors.
+	DisallowUnknownKeywords bool
+	// Overrides replace the generated types, by name, with existing ones.
+	Overrides map[string]TypeOverride
+	// Include generates only the types whose name matches one of these
+	// patterns, such as "user/*", all of them when empty. "*" matches within
+	// a segment of the name and "**" any number of segments.
+	Include []string
+	// Exclude skips the types whose name matches one of these patterns.
+	Exclude []string
+	// Reachable also generates the types referenced, transitively, by those
+	// selected by Include and Exclude. Otherwise they may only reference
+	// selected or overridden types.
+	Reachable bool
+	// NoHoist keeps inline objects and enums anonymous, instead of generating
+	// named types for them.
+	NoHoist bool
+	// Dedupe merges types of the same structure: "none" by default, "alias"
+	// to declare the copies as aliases of one of them, or "reference" to
+	// reference that one instead, dropping the inline copies.
+	Dedupe string
+	// Split generates a file per type, plus the shared ones, instead of a
+	// single file.
+	Split bool
+}
+
+// TypeOverride is a type provided by the application in place of a generated
+// one.
+type TypeOverride struct {
+	// Type is the type expression, such as "time.Time" or "Date".
+	Type string `json:"type"`
+	// Import is the package or module providing Type, if any.
+	Import string `json:"import,omitempty"`
 }
 
 type IConfig interface {
@@ -13,9 +54,3 @@ type IConfig interface {
 func (c *CommonConfig) Common() *CommonConfig {
 	return c
 }
-
-type TypeDesc struct {
-	Path         []string
-	RenderedName string
-	Type         *schemas.Type
-}
@@ -6,8 +6,8 @@ import (
 	"errors"
 	"fmt"
 	"github.com/azurity/schema2code/common"
-	"github.com/azurity/schema2code/schemas"
-	"io"
+	"github.com/azurity/schema2code/ir"
+	"regexp"
 	"sort"
 	"strings"
 	"sync/atomic"
@@ -23,32 +23,122 @@ type Config struct {
 
 type Context struct {
 	regexCounter uint64
+	names        map[*ir.Type]string
 }
 
 type Path struct {
 	namedPath []string
-	quotePath []string
+	// jsonPath leads to the value in the raw JSON the required properties
+	// are checked in, "*" standing for every item of an array or map.
+	jsonPath []string
+	required *[]requiredCheck
 }
 
+// requiredCheck is the required properties of the objects at jsonPath.
+type requiredCheck struct {
+	jsonPath []string
+	names    []string
+}
+
+// itemPath is the path of the items or values of the collection at path.
+func itemPath(path *Path) *Path {
+	return &Path{
+		namedPath: []string{"item"},
+		jsonPath:  append(append([]string{}, path.jsonPath...), "*"),
+		required:  path.required,
+	}
+}
+
+// writeRequired fails the decoding when an object misses a required
+// property, looking into raw, the JSON of the value at location.
+func writeRequired(checks []requiredCheck, raw string, location string, writer *common.CodeWriter) {
+	for _, check := range checks {
+		path := "nil"
+		if len(check.jsonPath) != 0 {
+			quoted := []string{}
+			for _, name := range check.jsonPath {
+				quoted = append(quoted, fmt.Sprintf("%q", name))
+			}
+			path = fmt.Sprintf("[]string{%s}", strings.Join(quoted, ", "))
+		}
+		names := []string{}
+		for _, name := range check.names {
+			names = append(names, fmt.Sprintf("%q", name))
+		}
+		writer.CommonLine()
+		writer.Write(fmt.Sprintf("err = RequiredValidation(%s, %s, %s, %s)", raw, location, path, strings.Join(names, ", ")))
+		writer.CommonLine()
+		writer.Write("if err != nil {")
+		writer.Indent()
+		writer.Write("return err")
+		writer.Dedent()
+		writer.Write("}")
+	}
+}
+
+// rootPath is the value decoded in UnmarshalJSON, a pointer to the internal
+// type.
+const rootPath = "main"
+
 func validationError(writer *common.CodeWriter, reason string) {
 	writer.Write(fmt.Sprintf("return errors.New(\"%s\")", reason))
 	// TODO: add more log here
 }
 
+// writeDeprecated marks the declaration written next as deprecated when the schema does.
+func writeDeprecated(writer *common.CodeWriter, node *ir.Node) {
+	if node == nil || !node.Deprecated {
+		return
+	}
+	writer.Write("// Deprecated: marked deprecated by the schema.")
+	writer.CommonLine()
+}
+
 func formatName(name string) string {
-	snake := strings.ReplaceAll(name, "-", "_")
-	if snake == "" {
-		return snake
+	return common.Identifier(name)
+}
+
+// valuePointer returns a pointer of goType to the value at path, optional
+// values being pointers already.
+func valuePointer(path *Path, optional bool, goType string) string {
+	name := strings.Join(path.namedPath, ".")
+	if optional {
+		return name
 	}
-	return strings.ToUpper(snake[:1]) + snake[1:]
+	if name == rootPath {
+		return fmt.Sprintf("(*%s)(%s)", goType, rootPath)
+	}
+	return "&" + name
 }
 
-func generateNull(ctx *Context, path *Path, imports map[string]interface{}, desc *schemas.Type, optional bool, writer *common.CodeWriter, globalCode *common.CodeWriter, validationCode *common.CodeWriter) (bool, error) {
-	writer.Write("*null")
+// checkNames fails when two generated types are given the same name.
+func checkNames(ctx *Context, module *ir.Module) error {
+	seen := map[string]*ir.Type{}
+	for _, value := range module.Types {
+		if value.Override != nil {
+			continue
+		}
+		if other, ok := seen[ctx.names[value]]; ok {
+			return errors.New(fmt.Sprintf("types %s and %s are both named %s", other.Name, value.Name, ctx.names[value]))
+		}
+		seen[ctx.names[value]] = value
+	}
+	return nil
+}
+
+func bound(value *ir.Bound) (float64, bool, bool) {
+	if value == nil {
+		return 0, false, false
+	}
+	return value.Value, value.Exclusive, true
+}
+
+func generateNull(ctx *Context, path *Path, imports map[string]interface{}, node *ir.Node, optional bool, writer *common.CodeWriter, globalCode *common.CodeWriter, validationCode *common.CodeWriter) (bool, error) {
+	writer.Write("*Null")
 	return true, nil
 }
 
-func generateBoolean(ctx *Context, path *Path, imports map[string]interface{}, desc *schemas.Type, optional bool, writer *common.CodeWriter, globalCode *common.CodeWriter, validationCode *common.CodeWriter) (bool, error) {
+func generateBoolean(ctx *Context, path *Path, imports map[string]interface{}, node *ir.Node, optional bool, writer *common.CodeWriter, globalCode *common.CodeWriter, validationCode *common.CodeWriter) (bool, error) {
 	if optional {
 		writer.Write("*bool")
 	} else {
@@ -57,109 +147,51 @@ func generateBoolean(ctx *Context, path *Path, imports map[string]interface{}, d
 	return true, nil
 }
 
-func generateInteger(ctx *Context, path *Path, imports map[string]interface{}, desc *schemas.Type, optional bool, writer *common.CodeWriter, globalCode *common.CodeWriter, validationCode *common.CodeWriter) (bool, error) {
+func generateNumeric(path *Path, node *ir.Node, optional bool, goType string, helper string, reason string, writer *common.CodeWriter, validationCode *common.CodeWriter) (bool, error) {
 	if optional {
-		writer.Write("*int")
-	} else {
-		writer.Write("int")
-	}
-	mini := float64(0)
-	maxi := float64(0)
-	hasMini := false
-	hasMaxi := false
-	exMini := false
-	exMaxi := false
-	if desc.Minimum != nil {
-		hasMini = true
-		mini = *desc.Minimum
-		if desc.ExclusiveMinimum != nil {
-			exMini = *desc.ExclusiveMinimum
-		}
-	}
-	if desc.Maximum != nil {
-		hasMaxi = true
-		maxi = *desc.Maximum
-		if desc.ExclusiveMaximum != nil {
-			exMaxi = *desc.ExclusiveMaximum
-		}
-	}
-	multiple := 1
-	useMultiple := false
-	if desc.MultipleOf != nil {
-		useMultiple = true
-		multiple = *desc.MultipleOf
-	}
-	if hasMini || hasMaxi || useMultiple {
-		prefix := "&"
-		if optional {
-			prefix = ""
+		writer.Write("*")
+	}
+	writer.Write(goType)
+	mini, exMini, hasMini := bound(node.Minimum)
+	maxi, exMaxi, hasMaxi := bound(node.Maximum)
+	multiples := node.MultipleOf
+	if len(multiples) == 0 {
+		multiples = []float64{1}
+	}
+	for i, multiple := range multiples {
+		useMultiple := len(node.MultipleOf) != 0
+		if i != 0 {
+			// the bounds are checked with the first multiple
+			hasMini, hasMaxi = false, false
+		}
+		if !(hasMini || hasMaxi || useMultiple) {
+			continue
 		}
 		validationCode.CommonLine()
 		validationCode.Write("if !")
-		validationCode.Write(fmt.Sprintf("IntegerValidation(%g, %g, %t, %t, %t, %t, %d, %t, %s%s)", mini, maxi, hasMini, hasMaxi, exMini, exMaxi, multiple, useMultiple, prefix, strings.Join(path.namedPath, ".")))
+		validationCode.Write(fmt.Sprintf("%s(%g, %g, %t, %t, %t, %t, %g, %t, %s)", helper, mini, maxi, hasMini, hasMaxi, exMini, exMaxi, multiple, useMultiple, valuePointer(path, optional, goType)))
 		validationCode.Write(" {")
 		validationCode.Indent()
-		validationError(validationCode, "integer check failed")
+		validationError(validationCode, reason)
 		validationCode.Dedent()
 		validationCode.Write("}")
 	}
-	return !(hasMini || hasMaxi || useMultiple), nil
+	return !(node.Minimum != nil || node.Maximum != nil || len(node.MultipleOf) != 0), nil
 }
 
-func generateNumber(ctx *Context, path *Path, imports map[string]interface{}, desc *schemas.Type, optional bool, writer *common.CodeWriter, globalCode *common.CodeWriter, validationCode *common.CodeWriter) (bool, error) {
-	if optional {
-		writer.Write("*float64")
-	} else {
-		writer.Write("float64")
-	}
-	mini := float64(0)
-	maxi := float64(0)
-	hasMini := false
-	hasMaxi := false
-	exMini := false
-	exMaxi := false
-	if desc.Minimum != nil {
-		hasMini = true
-		mini = *desc.Minimum
-		if desc.ExclusiveMinimum != nil {
-			exMini = *desc.ExclusiveMinimum
-		}
-	}
-	if desc.Maximum != nil {
-		hasMaxi = true
-		maxi = *desc.Maximum
-		if desc.ExclusiveMaximum != nil {
-			exMaxi = *desc.ExclusiveMaximum
-		}
-	}
-	multiple := 1
-	useMultiple := false
-	if desc.MultipleOf != nil {
-		useMultiple = true
-		multiple = *desc.MultipleOf
-	}
-	if hasMini || hasMaxi || useMultiple {
-		prefix := "&"
-		if optional {
-			prefix = ""
-		}
-		validationCode.CommonLine()
-		validationCode.Write("if !")
-		validationCode.Write(fmt.Sprintf("NumberValidation(%g, %g, %t, %t, %t, %t, %d, %t, %s%s)", mini, maxi, hasMini, hasMaxi, exMini, exMaxi, multiple, useMultiple, prefix, strings.Join(path.namedPath, ".")))
-		validationCode.Write(" {")
-		validationCode.Indent()
-		validationError(validationCode, "number check failed")
-		validationCode.Dedent()
-		validationCode.Write("}")
-	}
-	return !(hasMini || hasMaxi || useMultiple), nil
+func generateInteger(ctx *Context, path *Path, imports map[string]interface{}, node *ir.Node, optional bool, writer *common.CodeWriter, globalCode *common.CodeWriter, validationCode *common.CodeWriter) (bool, error) {
+	return generateNumeric(path, node, optional, "int", "IntegerValidation", "integer check failed", writer, validationCode)
 }
 
-func generateString(ctx *Context, path *Path, imports map[string]interface{}, desc *schemas.Type, optional bool, writer *common.CodeWriter, globalCode *common.CodeWriter, validationCode *common.CodeWriter) (bool, error) {
+func generateNumber(ctx *Context, path *Path, imports map[string]interface{}, node *ir.Node, optional bool, writer *common.CodeWriter, globalCode *common.CodeWriter, validationCode *common.CodeWriter) (bool, error) {
+	return generateNumeric(path, node, optional, "float64", "NumberValidation", "number check failed", writer, validationCode)
+}
+
+func generateString(ctx *Context, path *Path, imports map[string]interface{}, node *ir.Node, optional bool, writer *common.CodeWriter, globalCode *common.CodeWriter, validationCode *common.CodeWriter) (bool, error) {
 	if optional {
 		writer.Write("*")
 	}
-	if desc.Format != nil {
+	if node.Format != nil {
 		// TODO:
 	}
 	writer.Write("string")
@@ -167,58 +199,68 @@ func generateString(ctx *Context, path *Path, imports map[string]interface{}, de
 	maxLen := 0
 	useMinLength := false
 	useMaxLength := false
-	if desc.MinLength != nil {
+	if node.MinLength != nil {
 		useMinLength = true
-		minLen = *desc.MinLength
+		minLen = *node.MinLength
 	}
-	if desc.MaxLength != nil {
+	if node.MaxLength != nil {
 		useMaxLength = true
-		maxLen = *desc.MaxLength
-	}
-	prefix := "&"
-	if optional {
-		prefix = ""
-	}
-	stringName := "raw"
-	for _, it := range path.quotePath {
-		stringName += "[" + it + "]"
+		maxLen = *node.MaxLength
 	}
+	stringName := valuePointer(path, optional, "string")
 	if useMinLength || useMaxLength {
 		validationCode.CommonLine()
 		validationCode.Write("if !")
-		validationCode.Write(fmt.Sprintf("StringValidation(%d, %d, %t, %t, %s%s)", minLen, maxLen, useMinLength, useMaxLength, prefix, stringName))
+		validationCode.Write(fmt.Sprintf("StringValidation(%d, %d, %t, %t, %s)", minLen, maxLen, useMinLength, useMaxLength, stringName))
 		validationCode.Write(" {")
 		validationCode.Indent()
 		validationError(validationCode, "string check length failed")
 		validationCode.Dedent()
 		validationCode.Write("}")
 	}
-	if desc.Pattern != nil {
+	for _, pattern := range node.Pattern {
 		imports["regexp"] = struct{}{}
 		index := atomic.AddUint64(&ctx.regexCounter, 1)
 		globalCode.CommonLine()
-		globalCode.Write(fmt.Sprintf("var stringRegex%d = regexp.MustCompile(`%s`)", index, *desc.Pattern))
+		globalCode.Write(fmt.Sprintf("var stringRegex%d = regexp.MustCompile(`%s`)", index, pattern))
+		check := fmt.Sprintf("stringRegex%d.MatchString(*%s)", index, stringName)
+		if optional {
+			check = fmt.Sprintf("%s == nil || %s", stringName, check)
+		}
 		validationCode.CommonLine()
-		validationCode.Write("if !")
-		validationCode.Write(fmt.Sprintf("stringRegex%d.MatchString(%s%s)", index, prefix, stringName))
-		validationCode.Write(" {")
+		validationCode.Write("if !(")
+		validationCode.Write(check)
+		validationCode.Write(") {")
 		validationCode.Indent()
 		validationError(validationCode, "string check pattern failed")
 		validationCode.Dedent()
 		validationCode.Write("}")
 	}
-	return !(useMinLength || useMaxLength || desc.Pattern != nil), nil
+	if node.Enum != nil {
+		quoted := []string{}
+		for _, value := range node.Enum {
+			quoted = append(quoted, fmt.Sprintf("%q", value))
+		}
+		check := fmt.Sprintf("EnumValidation(*%s, []string{%s})", stringName, strings.Join(quoted, ", "))
+		if optional {
+			check = fmt.Sprintf("%s == nil || %s", stringName, check)
+		}
+		validationCode.CommonLine()
+		validationCode.Write(fmt.Sprintf("if !(%s) {", check))
+		validationCode.Indent()
+		validationError(validationCode, "wrong enum value")
+		validationCode.Dedent()
+		validationCode.Write("}")
+	}
+	return !(useMinLength || useMaxLength || len(node.Pattern) != 0 || node.Enum != nil), nil
 }
 
-func generateArray(ctx *Context, path *Path, imports map[string]interface{}, desc *schemas.Type, optional bool, writer *common.CodeWriter, globalCode *common.CodeWriter, validationCode *common.CodeWriter) (bool, error) {
-	if desc.AdditionalItems != nil {
-		return false, errors.New("only support single type array")
-	}
-	if desc.Items == nil {
-		return false, errors.New("array must have item type")
-	}
+func generateArray(ctx *Context, path *Path, imports map[string]interface{}, node *ir.Node, optional bool, writer *common.CodeWriter, globalCode *common.CodeWriter, validationCode *common.CodeWriter) (bool, error) {
 	writer.Write("[]")
 	arrayName := strings.Join(path.namedPath, ".")
+	if arrayName == rootPath {
+		arrayName = "*" + rootPath
+	}
 	if !optional {
 		validationCode.CommonLine()
 		validationCode.Write(fmt.Sprintf("if %s == nil {", arrayName))
@@ -227,56 +269,274 @@ func generateArray(ctx *Context, path *Path, imports map[string]interface{}, des
 		validationCode.Dedent()
 		validationCode.Write("}")
 	}
-	validationCode.CommonLine()
-	validationCode.Write(fmt.Sprintf("if %s != nil {", arrayName))
-	validationCode.Indent()
 
-	if desc.MinItems != nil || desc.MaxItems != nil {
+	hasLength := node.MinItems != nil || node.MaxItems != nil || node.UniqueItems
+	checkItems := needsValidation(node.Items, false)
+	if hasLength || checkItems {
+		validationCode.CommonLine()
+		validationCode.Write(fmt.Sprintf("if %s != nil {", arrayName))
+		validationCode.Indent()
+	}
+
+	if hasLength {
 		mini := 0
 		maxi := 0
-		if desc.MaxItems != nil {
-			mini = *desc.MinItems
+		if node.MinItems != nil {
+			mini = *node.MinItems
 		}
-		if desc.MaxItems != nil {
-			maxi = *desc.MaxItems
+		if node.MaxItems != nil {
+			maxi = *node.MaxItems
 		}
 		validationCode.Write("if !")
-		validationCode.Write(fmt.Sprintf("ArrayValidation(%d, %d, %t, %t, %t, %s)", mini, maxi, desc.MinItems != nil, desc.MaxItems != nil, desc.UniqueItems, arrayName))
+		validationCode.Write(fmt.Sprintf("ArrayValidation(%d, %d, %t, %t, %t, %s)", mini, maxi, node.MinItems != nil, node.MaxItems != nil, node.UniqueItems, arrayName))
 		validationCode.Write(" {")
 		validationCode.Indent()
 		validationError(validationCode, "array check failed")
 		validationCode.Dedent()
 		validationCode.Write("}")
+		if checkItems {
+			validationCode.CommonLine()
+		}
 	}
 
-	validationCode.Write(fmt.Sprintf("for index, item := range %s {", arrayName))
-	validationCode.Indent()
-	_, ignore, err := generateType(ctx, &Path{
-		namedPath: []string{"item"},
-		quotePath: append(append([]string{}, path.quotePath...), "index"),
-	}, imports, desc.Items, false, writer, globalCode, validationCode)
+	if checkItems {
+		validationCode.Write(fmt.Sprintf("for _, item := range %s {", arrayName))
+		validationCode.Indent()
+	}
+	_, err := generateType(ctx, itemPath(path), imports, node.Items, false, writer, globalCode, validationCode)
 	if err != nil {
 		return false, err
 	}
+	if checkItems {
+		validationCode.Dedent()
+		validationCode.Write("}")
+	}
+
+	if hasLength || checkItems {
+		validationCode.Dedent()
+		validationCode.Write("}")
+	}
+	return !needsValidation(node, optional), nil
+}
+
+// generateMap writes a dictionary as a map, checking each value.
+func generateMap(ctx *Context, path *Path, imports map[string]interface{}, node *ir.Node, writer *common.CodeWriter, globalCode *common.CodeWriter, validationCode *common.CodeWriter) (bool, error) {
+	writer.Write("map[string]")
+	mapName := strings.Join(path.namedPath, ".")
+	if mapName == rootPath {
+		mapName = "*" + rootPath
+	}
+	checkValues := needsValidation(node.AdditionalProperties, false)
+	if checkValues {
+		validationCode.CommonLine()
+		validationCode.Write(fmt.Sprintf("for _, item := range %s {", mapName))
+		validationCode.Indent()
+	}
+	_, err := generateType(ctx, itemPath(path), imports, node.AdditionalProperties, false, writer, globalCode, validationCode)
+	if err != nil {
+		return false, err
+	}
+	if checkValues {
+		validationCode.Dedent()
+		validationCode.Write("}")
+	}
+	return !checkValues, nil
+}
+
+// extraField is a map field holding the properties of an object which are
+// not declared.
+type extraField struct {
+	name string
+	// pattern is the index of the regular expression the names match, 0 for
+	// the additional properties.
+	pattern uint64
+	goType  string
+	// validation checks item, a value of the field.
+	validation []byte
+}
+
+// generateExtraProperties adds the map fields of the pattern and additional
+// properties of an object, decoded from the raw object after the declared
+// properties.
+func generateExtraProperties(ctx *Context, imports map[string]interface{}, node *ir.Node, writer *common.CodeWriter, globalCode *common.CodeWriter, validationCode *common.CodeWriter) error {
+	fields := []*extraField{}
+	nodes := []*ir.Node{}
+	for i, property := range node.PatternProperties {
+		if _, err := regexp.Compile(property.Pattern); err != nil {
+			return errors.New(fmt.Sprintf("pattern property %q of %s is not supported: %s", property.Pattern, strings.Join(node.Path, "/"), err))
+		}
+		index := atomic.AddUint64(&ctx.regexCounter, 1)
+		imports["regexp"] = struct{}{}
+		globalCode.CommonLine()
+		globalCode.Write(fmt.Sprintf("var propertyRegex%d = regexp.MustCompile(`%s`)", index, property.Pattern))
+		name := "PatternProperties"
+		if len(node.PatternProperties) > 1 {
+			name = fmt.Sprintf("PatternProperties%d", i+1)
+		}
+		fields = append(fields, &extraField{name: name, pattern: index})
+		nodes = append(nodes, property.Node)
+	}
+	if node.AdditionalProperties != nil {
+		fields = append(fields, &extraField{name: "AdditionalProperties"})
+		nodes = append(nodes, node.AdditionalProperties)
+	}
+	for i, field := range fields {
+		typeBuffer := &bytes.Buffer{}
+		typeWriter := &common.CodeWriter{
+			Writer: typeBuffer,
+			Tab:    "\t",
+		}
+		validationBuffer := &bytes.Buffer{}
+		validationWriter := &common.CodeWriter{
+			Writer: validationBuffer,
+			Tab:    "\t",
+		}
+		typeWriter.Indent()
+		validationWriter.Indent()
+		validationWriter.Indent()
+		validationWriter.Indent()
+		typeBuffer.Reset()
+		validationBuffer.Reset()
+		required := []requiredCheck{}
+		_, err := generateType(ctx, &Path{
+			namedPath: []string{"item"},
+			required:  &required,
+		}, imports, nodes[i], false, typeWriter, globalCode, validationWriter)
+		if err != nil {
+			return err
+		}
+		writeRequired(required, "value", "\"/\"+pointerToken(key)", validationWriter)
+		field.goType = typeBuffer.String()
+		field.validation = validationBuffer.Bytes()
+		writer.CommonLine()
+		writer.Write(fmt.Sprintf("%s map[string]%s `json:\"-\"`", field.name, field.goType))
+	}
+
+	imports["encoding/json"] = struct{}{}
+	validationCode.CommonLine()
+	validationCode.Write("raw := map[string]json.RawMessage{}")
+	validationCode.CommonLine()
+	validationCode.Write("err = json.Unmarshal(buffer, &raw)\n\tif err != nil {\n\t\treturn err\n\t}")
+	validationCode.CommonLine()
+	if len(fields) == 0 {
+		// a closed object without patterns only checks the names
+		validationCode.Write("for key := range raw {")
+	} else {
+		validationCode.Write("for key, value := range raw {")
+	}
+	validationCode.Indent()
+	if len(node.Properties) != 0 {
+		quoted := []string{}
+		for _, property := range node.Properties {
+			quoted = append(quoted, fmt.Sprintf("%q", property.Name))
+		}
+		validationCode.Write("switch key {")
+		validationCode.CommonLine()
+		validationCode.Write(fmt.Sprintf("case %s:", strings.Join(quoted, ", ")))
+		validationCode.Indent()
+		validationCode.Write("continue")
+		validationCode.Dedent()
+		validationCode.Write("}")
+		validationCode.CommonLine()
+	}
+	checkKnown := node.AdditionalProperties != nil || node.Closed
+	if checkKnown {
+		validationCode.Write("known := false")
+		validationCode.CommonLine()
+	}
+	writeField := func(field *extraField) {
+		validationCode.Write(fmt.Sprintf("var item %s", field.goType))
+		validationCode.CommonLine()
+		validationCode.Write("err = DecodeJSON(value, &item)\n\t\t\tif err != nil {\n\t\t\t\treturn MissingPropertyAt(key, err)\n\t\t\t}")
+		validationCode.Writer.Write(field.validation)
+		validationCode.CommonLine()
+		validationCode.Write(fmt.Sprintf("if %s.%s == nil {", rootPath, field.name))
+		validationCode.Indent()
+		validationCode.Write(fmt.Sprintf("%s.%s = map[string]%s{}", rootPath, field.name, field.goType))
+		validationCode.Dedent()
+		validationCode.Write("}")
+		validationCode.CommonLine()
+		validationCode.Write(fmt.Sprintf("%s.%s[key] = item", rootPath, field.name))
+	}
+	for _, field := range fields {
+		if field.pattern == 0 {
+			continue
+		}
+		validationCode.Write(fmt.Sprintf("if propertyRegex%d.MatchString(key) {", field.pattern))
+		validationCode.Indent()
+		if checkKnown {
+			validationCode.Write("known = true")
+			validationCode.CommonLine()
+		}
+		writeField(field)
+		validationCode.Dedent()
+		validationCode.Write("}")
+		validationCode.CommonLine()
+	}
+	if checkKnown {
+		validationCode.Write("if !known {")
+		validationCode.Indent()
+		if node.Closed {
+			validationCode.Write("return errors.New(\"unknown property \" + key)")
+		} else {
+			writeField(fields[len(fields)-1])
+		}
+		validationCode.Dedent()
+		validationCode.Write("}")
+	}
 	validationCode.Dedent()
 	validationCode.Write("}")
-	validationCode.Dedent()
-	validationCode.Write("}")
-	return ignore && !(desc.MinItems != nil || desc.MaxItems != nil), nil
+	return nil
 }
 
-type sortableKV struct {
-	key   string
-	value interface{}
+// holdsTypes reports whether node holds values of generated types, whose
+// missing properties are then located from the decoding of node.
+func holdsTypes(node *ir.Node) bool {
+	if node == nil {
+		return false
+	}
+	if node.Kind == ir.KindRef {
+		return node.Ref.Override == nil
+	}
+	if holdsTypes(node.Items) || holdsTypes(node.AdditionalProperties) {
+		return true
+	}
+	for _, property := range node.Properties {
+		if holdsTypes(property.Node) {
+			return true
+		}
+	}
+	for _, property := range node.PatternProperties {
+		if holdsTypes(property.Node) {
+			return true
+		}
+	}
+	return false
 }
 
-type sortKV []sortableKV
-
-func (a sortKV) Len() int           { return len(a) }
-func (a sortKV) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
-func (a sortKV) Less(i, j int) bool { return a[i].key < a[j].key }
+// isRoot reports whether path is the value decoded by UnmarshalJSON.
+func isRoot(path *Path) bool {
+	return len(path.namedPath) == 1 && path.namedPath[0] == rootPath
+}
 
-func generateObject(ctx *Context, path *Path, imports map[string]interface{}, desc *schemas.Type, optional bool, writer *common.CodeWriter, globalCode *common.CodeWriter, validationCode *common.CodeWriter) (bool, error) {
+func generateObject(ctx *Context, path *Path, imports map[string]interface{}, node *ir.Node, optional bool, writer *common.CodeWriter, globalCode *common.CodeWriter, validationCode *common.CodeWriter) (bool, error) {
+	if len(node.Schema.Required) != 0 {
+		// required names need not be declared properties
+		*path.required = append(*path.required, requiredCheck{jsonPath: path.jsonPath, names: node.Schema.Required})
+	}
+	if node.IsMap() {
+		return generateMap(ctx, path, imports, node, writer, globalCode, validationCode)
+	}
+	if node.HasExtraProperties() && !isRoot(path) {
+		return false, errors.New(fmt.Sprintf("object at %s with additional or pattern properties needs a named type, do not disable hoisting", strings.Join(node.Path, "/")))
+	}
+	propertyNames := []string{}
+	for _, property := range node.Properties {
+		propertyNames = append(propertyNames, property.Name)
+	}
+	if a, b, ok := common.IdentifierClash(propertyNames); ok {
+		return false, errors.New(fmt.Sprintf("properties %q and %q of %s are both named %s", a, b, strings.Join(node.Path, "/"), formatName(a)))
+	}
 	if optional {
 		writer.Write("*struct{")
 	} else {
@@ -284,268 +544,598 @@ func generateObject(ctx *Context, path *Path, imports map[string]interface{}, de
 	}
 	writer.Indent()
 
-	globalIgnore := true
-
-	required := desc.Required
-	if required == nil {
-		required = []string{}
-	}
-
-	validationCode.CommonLine()
-	if optional {
+	checkProperties := needsValidation(node, false)
+	if optional && checkProperties {
+		validationCode.CommonLine()
 		validationCode.Write(fmt.Sprintf("if %s != nil {", strings.Join(path.namedPath, ".")))
 		validationCode.Indent()
 	}
 
-	sorted := sortKV{}
-	for name, value := range desc.Properties {
-		sorted = append(sorted, sortableKV{name, value})
-	}
-	sort.Sort(sorted)
-
-	for _, iter := range sorted {
-		name := iter.key
-		value := iter.value.(*schemas.Type)
-		propOptional := true
-		for _, item := range required {
-			if name == item {
-				propOptional = false
-				break
-			}
-		}
+	for _, property := range node.Properties {
 		writer.CommonLine()
-		writer.Write(fmt.Sprintf("%s ", formatName(name)))
-		_, ignore, err := generateType(ctx, &Path{
-			namedPath: append(append([]string{}, path.namedPath...), formatName(name)),
-			quotePath: append(append([]string{}, path.quotePath...), "\""+name+"\""),
-		}, imports, value, propOptional, writer, globalCode, validationCode)
+		writeDeprecated(writer, property.Node)
+		writer.Write(fmt.Sprintf("%s ", formatName(property.Name)))
+		_, err := generateType(ctx, &Path{
+			namedPath: append(append([]string{}, path.namedPath...), formatName(property.Name)),
+			jsonPath:  append(append([]string{}, path.jsonPath...), property.Name),
+			required:  path.required,
+		}, imports, property.Node, !property.Required, writer, globalCode, validationCode)
 		if err != nil {
 			return false, err
 		}
 
-		globalIgnore = globalIgnore && ignore
-
-		writer.Write(fmt.Sprintf(" `json:\"%s\"`", name))
+		writer.Write(fmt.Sprintf(" `json:\"%s\"`", property.Name))
+	}
+	if node.HasExtraProperties() {
+		if err := generateExtraProperties(ctx, imports, node, writer, globalCode, validationCode); err != nil {
+			return false, err
+		}
 	}
 
-	if optional {
+	if optional && checkProperties {
 		validationCode.Dedent()
 		validationCode.Write("}")
 	}
 
 	writer.Dedent()
 	writer.Write("}")
-	return globalIgnore, nil
+	return !checkProperties, nil
 }
 
-// ignore value & error
-func generateType(ctx *Context, path *Path, imports map[string]interface{}, desc *schemas.Type, optional bool, writer *common.CodeWriter, globalCode *common.CodeWriter, validationCode *common.CodeWriter) (string, bool, error) {
-	if desc == nil {
-		return "", false, errors.New("must define type impl")
-	}
-	if desc.Ref != nil {
-		parts := strings.Split(*desc.Ref, "/")
-		if parts[0] != "#" {
-			return "", false, errors.New("only local $ref is support")
-		}
-		parts = parts[1:]
-		realName := []string{}
-		for i, item := range parts {
-			if i%2 != 0 {
-				realName = append(realName, formatName(item))
-			} else {
-				if item != "$defs" && item != "definitions" {
-					return "", false, errors.New("wrong $ref format")
-				}
+// needsValidation reports whether decoding a value of node needs more checks
+// than encoding/json does. Referenced types check themselves.
+func needsValidation(node *ir.Node, optional bool) bool {
+	switch node.Kind {
+	case ir.KindInteger, ir.KindNumber, ir.KindString:
+		return !node.Constraints.IsZero()
+	case ir.KindArray:
+		return !optional || !node.Constraints.IsZero() || needsValidation(node.Items, false)
+	case ir.KindObject:
+		if node.HasExtraProperties() {
+			return true
+		}
+		if node.IsMap() {
+			return needsValidation(node.AdditionalProperties, false)
+		}
+		for _, property := range node.Properties {
+			if needsValidation(property.Node, !property.Required) {
+				return true
 			}
 		}
+		return false
+	default:
+		return false
+	}
+}
+
+// ignore value & error
+func generateType(ctx *Context, path *Path, imports map[string]interface{}, node *ir.Node, optional bool, writer *common.CodeWriter, globalCode *common.CodeWriter, validationCode *common.CodeWriter) (bool, error) {
+	// TODO: impl enum here
+	switch node.Kind {
+	case ir.KindRef:
 		if optional {
 			writer.Write("*")
 		}
-		writer.Write(strings.Join(realName, ""))
-		return "", true, nil
-	}
-	if len(desc.Type) != 1 {
-		// TODO: try union later by use interface{}
-		return "", false, errors.New("multiple type is not supported")
-	}
-	// TODO: impl enum here
-	switch desc.Type[0] {
-	case schemas.TypeNameNull:
-		ign, err := generateNull(ctx, path, imports, desc, optional, writer, globalCode, validationCode)
-		return "", ign, err
-	case schemas.TypeNameBoolean:
-		ign, err := generateBoolean(ctx, path, imports, desc, optional, writer, globalCode, validationCode)
-		return "", ign, err
-	case schemas.TypeNameInteger:
-		ign, err := generateInteger(ctx, path, imports, desc, optional, writer, globalCode, validationCode)
-		return "0", ign, err
-	case schemas.TypeNameNumber:
-		ign, err := generateNumber(ctx, path, imports, desc, optional, writer, globalCode, validationCode)
-		return "float64(0)", ign, err
-	case schemas.TypeNameString:
-		ign, err := generateString(ctx, path, imports, desc, optional, writer, globalCode, validationCode)
-		return "\"\"", ign, err
-	case schemas.TypeNameArray:
-		ign, err := generateArray(ctx, path, imports, desc, optional, writer, globalCode, validationCode)
-		return "[]interface{}{}", ign, err
-	case schemas.TypeNameObject:
-		ign, err := generateObject(ctx, path, imports, desc, optional, writer, globalCode, validationCode)
-		return "map[string]interface{}{}", ign, err
+		if node.Ref.Override != nil && node.Ref.Override.Import != "" {
+			imports[node.Ref.Override.Import] = struct{}{}
+		}
+		writer.Write(ctx.names[node.Ref])
+		return true, nil
+	case ir.KindNull:
+		return generateNull(ctx, path, imports, node, optional, writer, globalCode, validationCode)
+	case ir.KindBoolean:
+		return generateBoolean(ctx, path, imports, node, optional, writer, globalCode, validationCode)
+	case ir.KindInteger:
+		return generateInteger(ctx, path, imports, node, optional, writer, globalCode, validationCode)
+	case ir.KindNumber:
+		return generateNumber(ctx, path, imports, node, optional, writer, globalCode, validationCode)
+	case ir.KindString:
+		return generateString(ctx, path, imports, node, optional, writer, globalCode, validationCode)
+	case ir.KindArray:
+		return generateArray(ctx, path, imports, node, optional, writer, globalCode, validationCode)
+	case ir.KindUnion:
+		return false, errors.New(fmt.Sprintf("union at %s needs a named type, do not disable hoisting", strings.Join(node.Path, "/")))
 	default:
-		return "", false, errors.New(fmt.Sprintf("unknown type %s", desc.Type[0]))
+		return generateObject(ctx, path, imports, node, optional, writer, globalCode, validationCode)
 	}
 }
 
-func GenerateCode(types map[string]*common.TypeDesc, config *Config, writer io.Writer) error {
-	for key, value := range types {
-		rendered := []string{}
-		for _, it := range value.Path {
-			rendered = append(rendered, formatName(it))
+// variantNames names the fields of the variants of a union after their types.
+func variantNames(ctx *Context, node *ir.Node) []string {
+	names := []string{}
+	seen := map[string]bool{}
+	for i, variant := range node.Variants {
+		name := formatName(variant.Kind.String())
+		if variant.Kind == ir.KindRef {
+			name = ctx.names[variant.Ref]
+			name = formatName(name[strings.LastIndex(name, ".")+1:])
+		}
+		if seen[name] {
+			name = fmt.Sprintf("%s%d", name, i)
 		}
-		types[key].RenderedName = strings.Join(rendered, "")
+		seen[name] = true
+		names = append(names, name)
 	}
+	return names
+}
 
-	fileBuffer := &bytes.Buffer{}
-	fileWriter := &common.CodeWriter{
-		Writer: fileBuffer,
+// generateUnion declares a union as a struct with a field per variant, one of
+// them being set. Decoding picks the variant by the discriminator, or else
+// tries each of them in order.
+func generateUnion(ctx *Context, renderedName string, imports map[string]interface{}, node *ir.Node, fileWriter *common.CodeWriter) error {
+	imports["encoding/json"] = struct{}{}
+	imports["errors"] = struct{}{}
+	names := variantNames(ctx, node)
+
+	typeBuffer := &bytes.Buffer{}
+	typeWriter := &common.CodeWriter{
+		Writer: typeBuffer,
 		Tab:    "\t",
 	}
-
-	imports := map[string]interface{}{
-		"encoding/json": struct{}{},
-		"errors":        struct{}{},
-		"math":          struct{}{},
-		"regexp":        struct{}{},
+	validations := [][]byte{}
+	writeDeprecated(typeWriter, node)
+	typeWriter.Write(fmt.Sprintf("type %s struct{", renderedName))
+	typeWriter.Indent()
+	for i, variant := range node.Variants {
+		validationBuffer := &bytes.Buffer{}
+		validationWriter := &common.CodeWriter{
+			Writer: validationBuffer,
+			Tab:    "\t",
+		}
+		validationWriter.Indent()
+		validationWriter.Indent()
+		validationBuffer.Reset()
+		typeWriter.CommonLine()
+		typeWriter.Write(fmt.Sprintf("%s ", names[i]))
+		required := []requiredCheck{}
+		_, err := generateType(ctx, &Path{
+			namedPath: []string{rootPath, names[i]},
+			required:  &required,
+		}, imports, variant, true, typeWriter, fileWriter, validationWriter)
+		if err != nil {
+			return err
+		}
+		writeRequired(required, "buffer", "\"\"", validationWriter)
+		validations = append(validations, validationBuffer.Bytes())
 	}
+	typeWriter.Dedent()
+	typeWriter.Write("}")
 
-	ctx := Context{}
-
-	sortedType := sortKV{}
-	for name, value := range types {
-		sortedType = append(sortedType, sortableKV{name, value})
+	fileWriter.CommonLine()
+	fileWriter.Writer.Write(typeBuffer.Bytes())
+	fileWriter.CommonLine()
+	if node.Discriminator != nil {
+		generateTaggedDecoding(renderedName, node, names, validations, fileWriter)
+	} else {
+		generateTrialDecoding(renderedName, node, names, validations, fileWriter)
 	}
-	sort.Sort(sortedType)
 
-	for _, iter := range sortedType {
-		value := iter.value.(*common.TypeDesc)
-		if value.Type.Enum != nil {
-			if len(value.Type.Type) != 1 || value.Type.Type[0] != schemas.TypeNameString {
-				return errors.New("only support string enum")
+	fileWriter.CommonLine()
+	fileWriter.Write(fmt.Sprintf("func (object %s) MarshalJSON() ([]byte, error) {", renderedName))
+	fileWriter.Indent()
+	for i, variant := range node.Variants {
+		fileWriter.Write(fmt.Sprintf("if object.%s != nil {", names[i]))
+		fileWriter.Indent()
+		if node.Discriminator != nil {
+			quoted := []string{}
+			for _, value := range node.Discriminator.Values[i] {
+				quoted = append(quoted, fmt.Sprintf("%q", value))
 			}
+			fileWriter.Write(fmt.Sprintf("return DiscriminatedJSON(object.%s, %q, %s)", names[i], node.Discriminator.Property, strings.Join(quoted, ", ")))
+		} else if variant.Kind == ir.KindNull {
+			fileWriter.Write("return []byte(\"null\"), nil")
+		} else {
+			fileWriter.Write(fmt.Sprintf("return json.Marshal(object.%s)", names[i]))
+		}
+		fileWriter.Dedent()
+		fileWriter.Write("}")
+		fileWriter.CommonLine()
+	}
+	fileWriter.Write(fmt.Sprintf("return nil, errors.New(\"no variant of %s is set\")", renderedName))
+	fileWriter.Dedent()
+	fileWriter.Write("}")
+	fileWriter.CommonLine()
+	return nil
+}
 
-			fileWriter.CommonLine()
-			fileWriter.Write(fmt.Sprintf("type %s string", value.RenderedName))
-			fileWriter.CommonLine()
-			fileWriter.Write("const (")
-			fileWriter.Indent()
-			for _, item := range value.Type.Enum {
-				if cased, ok := item.(string); !ok {
-					return errors.New("only support string enum")
-				} else {
-					fileWriter.CommonLine()
-					fileWriter.Write(fmt.Sprintf("%s%s %s = \"%s\"", value.RenderedName, formatName(cased), value.RenderedName, cased))
-				}
-			}
-			fileWriter.Dedent()
-			fileWriter.Write(")")
-			fileWriter.CommonLine()
-			fileWriter.Write(fmt.Sprintf("var enumValues%s = []string{", value.RenderedName))
-			for i, item := range value.Type.Enum {
-				if i != 0 {
-					fileWriter.Write(", ")
-				}
-				fileWriter.Write(fmt.Sprintf("\"%s\"", item.(string)))
-			}
-			fileWriter.Write("}")
-			fileWriter.CommonLine()
-			fileWriter.Write(fmt.Sprintf("func (object *%s) UnmarshalJSON(buffer []byte) error {", value.RenderedName))
-			fileWriter.Indent()
+// generateTaggedDecoding decodes the variant named by the discriminator.
+func generateTaggedDecoding(renderedName string, node *ir.Node, names []string, validations [][]byte, fileWriter *common.CodeWriter) {
+	property := node.Discriminator.Property
+	fileWriter.Write(fmt.Sprintf("func (object *%s) UnmarshalJSON(buffer []byte) error {", renderedName))
+	fileWriter.Indent()
+	fileWriter.Write(fmt.Sprintf("discriminator := struct {\n\t\tValue *string `json:\"%s\"`\n\t}{}", property))
+	fileWriter.CommonLine()
+	fileWriter.Write("err := json.Unmarshal(buffer, &discriminator)\n\tif err != nil {\n\t\treturn err\n\t}")
+	fileWriter.CommonLine()
+	fileWriter.Write("if discriminator.Value == nil {")
+	fileWriter.Indent()
+	validationError(fileWriter, fmt.Sprintf("missing discriminator %s", property))
+	fileWriter.Dedent()
+	fileWriter.Write("}")
+	fileWriter.CommonLine()
+	fileWriter.Write(fmt.Sprintf("%s := %s{}", rootPath, renderedName))
+	fileWriter.CommonLine()
+	fileWriter.Write("switch *discriminator.Value {")
+	fileWriter.CommonLine()
+	for i := range node.Variants {
+		quoted := []string{}
+		for _, value := range node.Discriminator.Values[i] {
+			quoted = append(quoted, fmt.Sprintf("%q", value))
+		}
+		fileWriter.Write(fmt.Sprintf("case %s:", strings.Join(quoted, ", ")))
+		fileWriter.Indent()
+		fileWriter.Write(fmt.Sprintf("err = DecodeJSON(buffer, &%s.%s)\n\t\tif err != nil {\n\t\t\treturn err\n\t\t}", rootPath, names[i]))
+		fileWriter.Writer.Write(validations[i])
+		fileWriter.Dedent()
+	}
+	fileWriter.Write("default:")
+	fileWriter.Indent()
+	fileWriter.Write(fmt.Sprintf("return errors.New(\"unknown value of discriminator %s: \" + *discriminator.Value)", property))
+	fileWriter.Dedent()
+	fileWriter.Write("}")
+	fileWriter.CommonLine()
+	fileWriter.Write(fmt.Sprintf("*object = %s", rootPath))
+	fileWriter.CommonLine()
+	fileWriter.Write("return nil")
+	fileWriter.Dedent()
+	fileWriter.Write("}")
+	fileWriter.CommonLine()
+}
 
-			fileWriter.Write("raw := \"\"")
-			fileWriter.CommonLine()
-			fileWriter.Write("err := json.Unmarshal(buffer, &raw)\n\tif err != nil {\n\t\treturn err\n\t}")
-			fileWriter.CommonLine()
-			fileWriter.Write(fmt.Sprintf("if !EnumValidation(raw, enumValues%s) {", value.RenderedName))
+// generateTrialDecoding decodes the first variant the value is valid for. An
+// exclusive union tries every variant, so that it can fail when several match.
+func generateTrialDecoding(renderedName string, node *ir.Node, names []string, validations [][]byte, fileWriter *common.CodeWriter) {
+	fileWriter.Write(fmt.Sprintf("func (object *%s) UnmarshalJSON(buffer []byte) error {", renderedName))
+	fileWriter.Indent()
+	fileWriter.Write(fmt.Sprintf("decoded := %s{}", renderedName))
+	fileWriter.CommonLine()
+	fileWriter.Write("matched := []string{}")
+	fileWriter.CommonLine()
+	fileWriter.Write("failures := []string{}")
+	fileWriter.CommonLine()
+	fileWriter.Write(fmt.Sprintf("try := func(name string, decode func(%s *%s) error) {", rootPath, renderedName))
+	fileWriter.Indent()
+	if !node.Exclusive {
+		fileWriter.Write("if len(matched) != 0 {")
+		fileWriter.Indent()
+		fileWriter.Write("return")
+		fileWriter.Dedent()
+		fileWriter.Write("}")
+		fileWriter.CommonLine()
+	}
+	fileWriter.Write(fmt.Sprintf("%s := %s{}", rootPath, renderedName))
+	fileWriter.CommonLine()
+	fileWriter.Write(fmt.Sprintf("err := decode(&%s)", rootPath))
+	fileWriter.CommonLine()
+	fileWriter.Write("if err != nil {")
+	fileWriter.Indent()
+	fileWriter.Write("failures = append(failures, name+\": \"+err.Error())")
+	fileWriter.CommonLine()
+	fileWriter.Write("return")
+	fileWriter.Dedent()
+	fileWriter.Write("}")
+	fileWriter.CommonLine()
+	fileWriter.Write("if len(matched) == 0 {")
+	fileWriter.Indent()
+	fileWriter.Write(fmt.Sprintf("decoded = %s", rootPath))
+	fileWriter.Dedent()
+	fileWriter.Write("}")
+	fileWriter.CommonLine()
+	fileWriter.Write("matched = append(matched, name)")
+	fileWriter.Dedent()
+	fileWriter.Write("}")
+	for i, variant := range node.Variants {
+		fileWriter.CommonLine()
+		fileWriter.Write(fmt.Sprintf("try(%q, func(%s *%s) error {", names[i], rootPath, renderedName))
+		fileWriter.Indent()
+		if variant.Kind == ir.KindNull {
+			fileWriter.Write("if !IsNull(buffer) {")
 			fileWriter.Indent()
-			validationError(fileWriter, "wrong enum value")
+			validationError(fileWriter, "not null")
 			fileWriter.Dedent()
 			fileWriter.Write("}")
 			fileWriter.CommonLine()
-
-			fileWriter.Write(fmt.Sprintf("*object = %s(raw)", value.RenderedName))
-			fileWriter.CommonLine()
-			fileWriter.Write("return nil")
+			fileWriter.Write(fmt.Sprintf("%s.%s = &Null{}", rootPath, names[i]))
+		} else {
+			fileWriter.Write("if IsNull(buffer) {")
+			fileWriter.Indent()
+			validationError(fileWriter, "unexpected null")
 			fileWriter.Dedent()
 			fileWriter.Write("}")
 			fileWriter.CommonLine()
-			continue
+			fileWriter.Write(fmt.Sprintf("err := DecodeJSON(buffer, &%s.%s)\n\t\tif err != nil {\n\t\t\treturn err\n\t\t}", rootPath, names[i]))
+			fileWriter.Writer.Write(validations[i])
 		}
+		fileWriter.CommonLine()
+		fileWriter.Write("return nil")
+		fileWriter.Dedent()
+		fileWriter.Write("})")
+	}
+	fileWriter.CommonLine()
+	fileWriter.Write(fmt.Sprintf("err := UnionValidation(%t, matched, failures)", node.Exclusive))
+	fileWriter.CommonLine()
+	fileWriter.Write("if err != nil {")
+	fileWriter.Indent()
+	fileWriter.Write("return err")
+	fileWriter.Dedent()
+	fileWriter.Write("}")
+	fileWriter.CommonLine()
+	fileWriter.Write("*object = decoded")
+	fileWriter.CommonLine()
+	fileWriter.Write("return nil")
+	fileWriter.Dedent()
+	fileWriter.Write("}")
+	fileWriter.CommonLine()
+}
 
-		typeBuffer := &bytes.Buffer{}
-		typeWriter := &common.CodeWriter{
-			Writer: typeBuffer,
-			Tab:    "\t",
+// helperImports are the packages the helper code uses.
+var helperImports = []string{"encoding/json", "errors", "math", "reflect", "regexp", "sort", "strconv", "strings"}
+
+// generateDeclaration returns the code of value and the packages it imports.
+func generateDeclaration(ctx *Context, value *ir.Type) ([]byte, map[string]interface{}, error) {
+	imports := map[string]interface{}{}
+	fileBuffer := &bytes.Buffer{}
+	fileWriter := &common.CodeWriter{
+		Writer: fileBuffer,
+		Tab:    "\t",
+	}
+	renderedName := ctx.names[value]
+	if value.Alias != nil {
+		fileWriter.CommonLine()
+		fileWriter.Write(fmt.Sprintf("type %s = %s", renderedName, ctx.names[value.Alias]))
+		fileWriter.CommonLine()
+		return fileBuffer.Bytes(), imports, nil
+	}
+	if value.Node.Kind == ir.KindRef {
+		// an alias keeps the methods of the referenced type, which validate it
+		if value.Node.Ref.Override != nil && value.Node.Ref.Override.Import != "" {
+			imports[value.Node.Ref.Override.Import] = struct{}{}
 		}
-		validationBuffer := &bytes.Buffer{}
-		validationWriter := &common.CodeWriter{
-			Writer: validationBuffer,
-			Tab:    "\t",
+		fileWriter.CommonLine()
+		writeDeprecated(fileWriter, value.Node)
+		fileWriter.Write(fmt.Sprintf("type %s = %s", renderedName, ctx.names[value.Node.Ref]))
+		fileWriter.CommonLine()
+		return fileBuffer.Bytes(), imports, nil
+	}
+	if value.Node.Enum != nil && value.Node.Kind == ir.KindString {
+		imports["encoding/json"] = struct{}{}
+		imports["errors"] = struct{}{}
+		fileWriter.CommonLine()
+		writeDeprecated(fileWriter, value.Node)
+		fileWriter.Write(fmt.Sprintf("type %s string", renderedName))
+		fileWriter.CommonLine()
+		fileWriter.Write("const (")
+		fileWriter.Indent()
+		if a, b, ok := common.IdentifierClash(value.Node.Enum); ok {
+			return nil, nil, errors.New(fmt.Sprintf("values %q and %q of %s are both named %s", a, b, value.Name, formatName(a)))
 		}
-		typeWriter.Write(fmt.Sprintf("type %s ", value.RenderedName))
+		for _, item := range value.Node.Enum {
+			fileWriter.CommonLine()
+			fileWriter.Write(fmt.Sprintf("%s%s %s = \"%s\"", renderedName, formatName(item), renderedName, item))
+		}
+		fileWriter.Dedent()
+		fileWriter.Write(")")
+		fileWriter.CommonLine()
+		fileWriter.Write(fmt.Sprintf("var enumValues%s = []string{", renderedName))
+		for i, item := range value.Node.Enum {
+			if i != 0 {
+				fileWriter.Write(", ")
+			}
+			fileWriter.Write(fmt.Sprintf("\"%s\"", item))
+		}
+		fileWriter.Write("}")
+		fileWriter.CommonLine()
+		fileWriter.Write(fmt.Sprintf("func (object *%s) UnmarshalJSON(buffer []byte) error {", renderedName))
+		fileWriter.Indent()
 
-		validationWriter.Indent()
+		fileWriter.Write("raw := \"\"")
+		fileWriter.CommonLine()
+		fileWriter.Write("err := json.Unmarshal(buffer, &raw)\n\tif err != nil {\n\t\treturn err\n\t}")
+		fileWriter.CommonLine()
+		fileWriter.Write(fmt.Sprintf("if !EnumValidation(raw, enumValues%s) {", renderedName))
+		fileWriter.Indent()
+		validationError(fileWriter, "wrong enum value")
+		fileWriter.Dedent()
+		fileWriter.Write("}")
+		fileWriter.CommonLine()
 
-		rawType, ignore, err := generateType(&ctx, &Path{
-			namedPath: []string{"main"},
-			quotePath: []string{},
-		}, imports, value.Type, false, typeWriter, fileWriter, validationWriter)
-		if err != nil {
-			return err
+		fileWriter.Write(fmt.Sprintf("*object = %s(raw)", renderedName))
+		fileWriter.CommonLine()
+		fileWriter.Write("return nil")
+		fileWriter.Dedent()
+		fileWriter.Write("}")
+		fileWriter.CommonLine()
+		return fileBuffer.Bytes(), imports, nil
+	}
+
+	if value.Node.Kind == ir.KindUnion {
+		if err := generateUnion(ctx, renderedName, imports, value.Node, fileWriter); err != nil {
+			return nil, nil, err
 		}
+		return fileBuffer.Bytes(), imports, nil
+	}
 
+	typeBuffer := &bytes.Buffer{}
+	typeWriter := &common.CodeWriter{
+		Writer: typeBuffer,
+		Tab:    "\t",
+	}
+	validationBuffer := &bytes.Buffer{}
+	validationWriter := &common.CodeWriter{
+		Writer: validationBuffer,
+		Tab:    "\t",
+	}
+	writeDeprecated(typeWriter, value.Node)
+	typeWriter.Write(fmt.Sprintf("type %s ", renderedName))
+
+	validationWriter.Indent()
+
+	required := []requiredCheck{}
+	ignore, err := generateType(ctx, &Path{
+		namedPath: []string{rootPath},
+		required:  &required,
+	}, imports, value.Node, false, typeWriter, fileWriter, validationWriter)
+	if err != nil {
+		return nil, nil, err
+	}
+	ignore = ignore && len(required) == 0 && !holdsTypes(value.Node)
+
+	fileWriter.CommonLine()
+	fileWriter.Writer.Write(typeBuffer.Bytes())
+	fileWriter.CommonLine()
+	if !ignore {
+		// validations report their failures with errors.New, but the decoding
+		// of extra and the checks of required properties may not fail by them
+		if bytes.Contains(validationBuffer.Bytes(), []byte("errors.New")) {
+			imports["errors"] = struct{}{}
+		}
+		fileWriter.Write(fmt.Sprintf("func (object *%s) UnmarshalJSON(buffer []byte) error {", renderedName))
+		fileWriter.Indent()
+		fileWriter.Write(fmt.Sprintf("type internal %s", renderedName))
 		fileWriter.CommonLine()
-		fileWriter.Writer.Write(typeBuffer.Bytes())
-		fileWriter.CommonLine()
-		if !ignore {
-			fileWriter.Write(fmt.Sprintf("func (object *%s) UnmarshalJSON(buffer []byte) error {", value.RenderedName))
-			fileWriter.Indent()
-			fileWriter.Write(fmt.Sprintf("raw := %s", rawType))
-			fileWriter.CommonLine()
-			fileWriter.Write("err := json.Unmarshal(buffer, &raw)\n\tif err != nil {\n\t\treturn err\n\t}")
-			fileWriter.CommonLine()
-			fileWriter.Write(fmt.Sprintf("type internal %s", value.RenderedName))
-			fileWriter.CommonLine()
-			fileWriter.Write("main := new(internal)\n\terr = json.Unmarshal(buffer, main)\n\tif err != nil {\n\t\treturn err\n\t}")
+		fileWriter.Write("main := new(internal)\n\terr := DecodeJSON(buffer, main)\n\tif err != nil {\n\t\treturn InternalTypeError(err, main, object)\n\t}")
+		writeRequired(required, "buffer", "\"\"", fileWriter)
 
-			fileWriter.Writer.Write(validationBuffer.Bytes())
-			fileWriter.CommonLine()
+		fileWriter.Writer.Write(validationBuffer.Bytes())
+		fileWriter.CommonLine()
 
-			fileWriter.Write(fmt.Sprintf("*object = %s(*main)", value.RenderedName))
-			fileWriter.CommonLine()
-			fileWriter.Write("return nil")
-			fileWriter.Dedent()
-			fileWriter.Write("}")
+		fileWriter.Write(fmt.Sprintf("*object = %s(*main)", renderedName))
+		fileWriter.CommonLine()
+		fileWriter.Write("return nil")
+		fileWriter.Dedent()
+		fileWriter.Write("}")
+	}
+	if value.Node.HasExtraProperties() {
+		fields := []string{}
+		for i := range value.Node.PatternProperties {
+			if len(value.Node.PatternProperties) > 1 {
+				fields = append(fields, fmt.Sprintf("object.PatternProperties%d", i+1))
+			} else {
+				fields = append(fields, "object.PatternProperties")
+			}
+		}
+		if value.Node.AdditionalProperties != nil {
+			fields = append(fields, "object.AdditionalProperties")
 		}
+		fileWriter.CommonLine()
+		fileWriter.CommonLine()
+		fileWriter.Write(fmt.Sprintf("func (object %s) MarshalJSON() ([]byte, error) {", renderedName))
+		fileWriter.Indent()
+		fileWriter.Write(fmt.Sprintf("type internal %s", renderedName))
+		fileWriter.CommonLine()
+		fileWriter.Write(fmt.Sprintf("return ExtendedJSON(%s)", strings.Join(append([]string{"internal(object)"}, fields...), ", ")))
+		fileWriter.Dedent()
+		fileWriter.Write("}")
 	}
+	return fileBuffer.Bytes(), imports, nil
+}
 
+// writeFile writes a Go file of the package with its imports and code.
+func writeFile(output common.Sink, name string, config *Config, imports map[string]interface{}, code ...[]byte) error {
+	file, err := output.Create(name)
+	if err != nil {
+		return err
+	}
 	packageParts := strings.Split(config.Package, "/")
 	packName := packageParts[len(packageParts)-1]
-	writer.Write([]byte(fmt.Sprintf("package %s\n\n", packName)))
-	writer.Write([]byte("import (\n"))
+	file.Write([]byte(fmt.Sprintf("package %s\n\n", packName)))
 
-	sortedPack := []string{}
-	for pack, _ := range imports {
-		sortedPack = append(sortedPack, pack)
+	if len(imports) != 0 {
+		file.Write([]byte("import (\n"))
+		sortedPack := []string{}
+		for pack, _ := range imports {
+			sortedPack = append(sortedPack, pack)
+		}
+		sort.Strings(sortedPack)
+		for _, pack := range sortedPack {
+			file.Write([]byte(fmt.Sprintf("\t\"%s\"\n", pack)))
+		}
+		file.Write([]byte(")\n\n"))
+	}
+	for _, part := range code {
+		file.Write(part)
 	}
-	sort.Strings(sortedPack)
+	return file.Close()
+}
 
-	for _, pack := range sortedPack {
-		writer.Write([]byte(fmt.Sprintf("\t\"%s\"\n", pack)))
+// GenerateCode writes models.go, or with config.Split a file per type and
+// schema2code_gen.go for the helpers.
+func GenerateCode(module *ir.Module, config *Config, output common.Sink) error {
+	ctx := Context{
+		names: map[*ir.Type]string{},
+	}
+	for _, value := range module.Types {
+		if value.Override != nil {
+			ctx.names[value] = value.Override.Type
+			continue
+		}
+		rendered := []string{}
+		for _, it := range value.Path {
+			rendered = append(rendered, formatName(it))
+		}
+		ctx.names[value] = strings.Join(rendered, "")
+	}
+	if err := checkNames(&ctx, module); err != nil {
+		return err
 	}
-	writer.Write([]byte(")\n\n"))
-	writer.Write(helperCode)
-	writer.Write(fileBuffer.Bytes())
 
-	return nil
+	helperImport := map[string]interface{}{}
+	for _, pack := range helperImports {
+		helperImport[pack] = struct{}{}
+	}
+
+	if !config.Split {
+		imports := helperImport
+		code := [][]byte{helperCode}
+		for _, value := range module.Types {
+			if value.Override != nil {
+				continue
+			}
+			declaration, typeImports, err := generateDeclaration(&ctx, value)
+			if err != nil {
+				return err
+			}
+			for pack := range typeImports {
+				imports[pack] = struct{}{}
+			}
+			code = append(code, declaration)
+		}
+		return writeFile(output, "models.go", config, imports, code...)
+	}
+
+	files := common.FileSet{}
+	files.Add("schema2code_gen.go", "the helpers")
+	for _, value := range module.Types {
+		if value.Override != nil {
+			continue
+		}
+		name := common.FileName(value.Path) + "_gen.go"
+		if err := files.Add(name, value.Name); err != nil {
+			return err
+		}
+		declaration, imports, err := generateDeclaration(&ctx, value)
+		if err != nil {
+			return err
+		}
+		declaration = append(bytes.TrimSpace(declaration), '\n')
+		if err := writeFile(output, name, config, imports, declaration); err != nil {
+			return err
+		}
+	}
+	return writeFile(output, "schema2code_gen.go", config, helperImport, helperCode)
+}
+
+// Backend generates Go, with Config as config.
+type Backend struct{}
+
+func (Backend) Name() string {
+	return "go"
+}
+
+func (Backend) NewConfig() interface{} {
+	return &Config{}
+}
+
+func (Backend) Generate(module *ir.Module, config interface{}, output common.Sink) error {
+	return GenerateCode(module, config.(*Config), output)
 }
@@ -0,0 +1,291 @@
+package schema2code
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// goCase is a schema whose generated Go is built and run with program, the
+// body of main, printing what it checks.
+type goCase struct {
+	name    string
+	schema  string
+	noHoist bool
+	program string
+	output  string
+}
+
+// runGo generates Go from schema into a new module with program, vets it
+// and returns what it prints.
+func runGo(t *testing.T, schema string, config *GolangConfig, program string) string {
+	t.Helper()
+	goTool, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go is not installed")
+	}
+	dir := t.TempDir()
+	config.Package = "main"
+	if err := GenerateToSink(strings.NewReader(schema), &DirSink{Dir: dir}, config); err != nil {
+		t.Fatalf("generate: %v", err)
+	}
+	main := "package main\n\nimport (\n\t\"encoding/json\"\n\t\"fmt\"\n)\n\n" +
+		"func decode(value interface{}, data string) {\n\tfmt.Println(json.Unmarshal([]byte(data), value))\n}\n\n" +
+		"func encode(value interface{}) {\n\tdata, err := json.Marshal(value)\n\tfmt.Println(string(data), err)\n}\n\n" +
+		"func main() {\n" + program + "\n}\n"
+	files := map[string]string{
+		"go.mod":  "module golden\n\ngo 1.18\n",
+		"main.go": main,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, args := range [][]string{{"vet", "."}, {"run", "."}} {
+		command := exec.Command(goTool, args...)
+		command.Dir = dir
+		command.Env = append(os.Environ(), "GOFLAGS=-mod=mod", "GOWORK=off")
+		out, err := command.CombinedOutput()
+		if err != nil {
+			t.Fatalf("go %s: %v\n%s", args[0], err, out)
+		}
+		if args[0] == "run" {
+			return string(out)
+		}
+	}
+	return ""
+}
+
+func TestGeneratedGo(t *testing.T) {
+	cases := []goCase{
+		{
+			name:   "closed object",
+			schema: `{"$defs": {"strict": {"type": "object", "properties": {"x": {"type": "string"}}, "additionalProperties": false}}}`,
+			program: `decode(&Strict{}, ` + "`" + `{"x": "a"}` + "`" + `)
+	decode(&Strict{}, ` + "`" + `{"x": "a", "y": 1}` + "`" + `)`,
+			output: "<nil>\nunknown property y\n",
+		},
+		{
+			name:   "unevaluated properties",
+			schema: `{"$defs": {"strict": {"type": "object", "properties": {"x": {"type": "string"}, "old": {"type": "string", "deprecated": true}}, "unevaluatedProperties": false, "deprecated": true}}}`,
+			program: `decode(&Strict{}, ` + "`" + `{"x": "a", "old": "b"}` + "`" + `)
+	decode(&Strict{}, ` + "`" + `{"x": "a", "y": 1}` + "`" + `)`,
+			output: "<nil>\nunknown property y\n",
+		},
+		{
+			name:   "additional and pattern properties",
+			schema: `{"$defs": {"labels": {"type": "object", "properties": {"name": {"type": "string"}}, "patternProperties": {"^x-": {"type": "string", "maxLength": 3}}, "additionalProperties": {"type": "number"}}}}`,
+			program: `value := Labels{}
+	decode(&value, ` + "`" + `{"name": "a", "x-a": "abc", "b": 1.5}` + "`" + `)
+	encode(value)
+	decode(&value, ` + "`" + `{"name": "a", "x-a": "abcd"}` + "`" + `)`,
+			output: "<nil>\n{\"b\":1.5,\"name\":\"a\",\"x-a\":\"abc\"} <nil>\nstring check length failed\n",
+		},
+		{
+			name: "tagged union of allOf variants",
+			schema: `{"$defs": {
+				"base": {"type": "object", "properties": {"kind": {"type": "string"}, "name": {"type": "string"}}, "required": ["kind"]},
+				"cat": {"allOf": [{"$ref": "#/$defs/base"}, {"properties": {"kind": {"type": "string", "const": "cat"}, "lives": {"type": "integer"}}}]},
+				"dog": {"allOf": [{"$ref": "#/$defs/base"}, {"properties": {"kind": {"type": "string", "enum": ["dog", "puppy"]}}}]},
+				"pet": {"oneOf": [{"$ref": "#/$defs/cat"}, {"$ref": "#/$defs/dog"}], "discriminator": {"propertyName": "kind"}}
+			}}`,
+			program: `value := Pet{}
+	decode(&value, ` + "`" + `{"kind": "cat", "lives": 9}` + "`" + `)
+	encode(value)
+	decode(&value, ` + "`" + `{"kind": "puppy"}` + "`" + `)
+	encode(value)
+	decode(&value, ` + "`" + `{"kind": "Cat"}` + "`" + `)`,
+			output: "<nil>\n{\"kind\":\"cat\",\"lives\":9,\"name\":null} <nil>\n<nil>\n{\"kind\":\"puppy\",\"name\":null} <nil>\nunknown value of discriminator kind: Cat\n",
+		},
+		{
+			name: "tagged union",
+			schema: `{"$defs": {
+				"cat": {"type": "object", "properties": {"kind": {"type": "string"}, "lives": {"type": "integer", "maximum": 9}}, "required": ["kind"]},
+				"dog": {"type": "object", "properties": {"kind": {"type": "string"}, "name": {"type": "string"}}, "required": ["kind", "name"]},
+				"pet": {"oneOf": [{"$ref": "#/$defs/cat"}, {"$ref": "#/$defs/dog"}], "discriminator": {"propertyName": "kind"}}
+			}}`,
+			program: `value := Pet{}
+	decode(&value, ` + "`" + `{"kind": "dog", "name": "rex"}` + "`" + `)
+	fmt.Println(value.Cat == nil, value.Dog.Name)
+	encode(value)
+	decode(&value, ` + "`" + `{"kind": "cat", "lives": 10}` + "`" + `)
+	decode(&value, ` + "`" + `{"kind": "dog"}` + "`" + `)
+	decode(&value, ` + "`" + `{"name": "rex"}` + "`" + `)
+	decode(&value, ` + "`" + `{"kind": "cow"}` + "`" + `)
+	encode(Pet{})`,
+			output: "<nil>\ntrue rex\n{\"kind\":\"dog\",\"name\":\"rex\"} <nil>\ninteger check failed\nmissing property /name\nmissing discriminator kind\nunknown value of discriminator kind: cow\n json: error calling MarshalJSON for type *main.Pet: no variant of Pet is set\n",
+		},
+		{
+			name: "const",
+			schema: `{"$defs": {
+				"cat": {"type": "object", "properties": {"kind": {"const": "cat"}, "lives": {"type": "integer"}}, "required": ["kind"]},
+				"dog": {"type": "object", "properties": {"kind": {"const": "dog"}, "name": {"type": "string", "enum": ["rex", "max"]}}, "required": ["kind"]},
+				"pet": {"oneOf": [{"$ref": "#/$defs/cat"}, {"$ref": "#/$defs/dog"}]}
+			}}`,
+			noHoist: true,
+			program: `value := Pet{}
+	decode(&value, ` + "`" + `{"kind": "dog"}` + "`" + `)
+	fmt.Println(value.Cat == nil, value.Dog != nil)
+	decode(&Cat{}, ` + "`" + `{"kind": "dog"}` + "`" + `)
+	decode(&Dog{}, ` + "`" + `{"kind": "dog", "name": "rex"}` + "`" + `)
+	decode(&Dog{}, ` + "`" + `{"kind": "dog", "name": "bob"}` + "`" + `)`,
+			output: "<nil>\ntrue true\nwrong enum value\n<nil>\nwrong enum value\n",
+		},
+		{
+			name:   "multiple types",
+			schema: `{"$defs": {"person": {"type": "object", "properties": {"name": {"type": ["string", "null"], "minLength": 1}, "age": {"type": ["integer", "string"], "minimum": 0}}}}}`,
+			program: `value := Person{}
+	decode(&value, ` + "`" + `{"name": "a", "age": "unknown"}` + "`" + `)
+	encode(value)
+	decode(&value, ` + "`" + `{"name": null, "age": 2}` + "`" + `)
+	encode(value)
+	decode(&value, ` + "`" + `{"name": ""}` + "`" + `)
+	decode(&value, ` + "`" + `{"age": -1}` + "`" + `)`,
+			output: "<nil>\n{\"age\":\"unknown\",\"name\":\"a\"} <nil>\n<nil>\n{\"age\":2,\"name\":null} <nil>\nno variant matches: String: string check length failed; Null: not null\nno variant matches: Integer: integer check failed; String: json: cannot unmarshal number into Go value of type string\n",
+		},
+		{
+			name: "trial decoding",
+			schema: `{"$defs": {
+				"id": {"anyOf": [{"type": "string", "minLength": 1}, {"type": "object", "properties": {"id": {"type": "integer"}}, "required": ["id"]}, {"type": "null"}]},
+				"size": {"oneOf": [{"type": "integer", "minimum": 0}, {"type": "integer", "maximum": 10}]}
+			}}`,
+			program: `id := Id{}
+	decode(&id, ` + "`" + `"a"` + "`" + `)
+	encode(id)
+	decode(&id, ` + "`" + `{"id": 1}` + "`" + `)
+	encode(id)
+	decode(&id, ` + "`" + `null` + "`" + `)
+	encode(id)
+	decode(&id, ` + "`" + `""` + "`" + `)
+	size := Size{}
+	decode(&size, ` + "`" + `20` + "`" + `)
+	decode(&size, ` + "`" + `-1` + "`" + `)
+	decode(&size, ` + "`" + `5` + "`" + `)`,
+			output: "<nil>\n\"a\" <nil>\n<nil>\n{\"id\":1} <nil>\n<nil>\nnull <nil>\nno variant matches: String: string check length failed; IdAnyOf1: json: cannot unmarshal string into Go value of type main.IdAnyOf1; Null: not null\n<nil>\n<nil>\nseveral variants match: Integer, Integer1\n",
+		},
+		{
+			name: "required properties",
+			schema: `{"$defs": {
+				"order": {"type": "object", "properties": {
+					"shipping": {"type": "object", "properties": {"city": {"type": "string"}}, "required": ["city"]},
+					"items": {"type": "array", "items": {"type": "object", "properties": {"sku": {"type": "string"}}, "required": ["sku"]}},
+					"tags": {"type": "object", "additionalProperties": {"$ref": "#/$defs/tag"}}
+				}},
+				"tag": {"type": "object", "properties": {"v": {"type": "string"}}, "required": ["v", "extra"]},
+				"dict": {"type": "object", "additionalProperties": {"type": "integer"}, "required": ["a"]}
+			}}`,
+			program: `decode(&Order{}, ` + "`" + `{"shipping": {"city": "x"}, "items": [{"sku": "a"}], "tags": {"t": {"v": "a", "extra": 1}}}` + "`" + `)
+	decode(&Order{}, ` + "`" + `{"shipping": {}}` + "`" + `)
+	decode(&Order{}, ` + "`" + `{"items": [{"sku": "a"}, {}]}` + "`" + `)
+	decode(&Order{}, ` + "`" + `{"tags": {"a/b": {"v": "a"}}}` + "`" + `)
+	decode(&Dict{}, ` + "`" + `{"a": 1}` + "`" + `)
+	decode(&Dict{}, ` + "`" + `{"b": 1}` + "`" + `)`,
+			output: "<nil>\nmissing property /shipping/city\nmissing property /items/1/sku\nmissing property /tags/a~1b/extra\n<nil>\nmissing property /a\n",
+		},
+		{
+			name: "required properties without hoisting",
+			schema: `{"$defs": {
+				"order": {"type": "object", "properties": {
+					"shipping": {"type": "object", "properties": {"city": {"type": "string"}}, "required": ["city"]},
+					"items": {"type": "array", "items": {"type": "object", "properties": {"sku": {"type": "string"}}, "required": ["sku"]}},
+					"tags": {"type": "object", "additionalProperties": {"$ref": "#/$defs/tag"}}
+				}},
+				"tag": {"type": "object", "properties": {"v": {"type": "string"}}, "required": ["v", "extra"]},
+				"dict": {"type": "object", "additionalProperties": {"type": "integer"}, "required": ["a"]}
+			}}`,
+			noHoist: true,
+			program: `decode(&Order{}, ` + "`" + `{"shipping": {"city": "x"}, "items": [{"sku": "a"}], "tags": {"t": {"v": "a", "extra": 1}}}` + "`" + `)
+	decode(&Order{}, ` + "`" + `{"shipping": {}}` + "`" + `)
+	decode(&Order{}, ` + "`" + `{"items": [{"sku": "a"}, {}]}` + "`" + `)
+	decode(&Order{}, ` + "`" + `{"tags": {"a/b": {"v": "a"}}}` + "`" + `)
+	decode(&Dict{}, ` + "`" + `{"a": 1}` + "`" + `)
+	decode(&Dict{}, ` + "`" + `{"b": 1}` + "`" + `)`,
+			output: "<nil>\nmissing property /shipping/city\nmissing property /items/1/sku\nmissing property /tags/a~1b/extra\n<nil>\nmissing property /a\n",
+		},
+		{
+			name:   "required property deep in recursive types",
+			schema: `{"$defs": {"node": {"type": "object", "properties": {"v": {"type": "integer"}, "next": {"$ref": "#/$defs/node"}, "list": {"type": "array", "items": {"$ref": "#/$defs/node"}}}, "required": ["v"]}}}`,
+			program: `data := "{}"
+	for i := 0; i < 30; i++ {
+		data = "{\"v\": 1, \"list\": [{\"v\": 2}, " + data + "]}"
+	}
+	decode(&Node{}, data)`,
+			output: "missing property " + strings.Repeat("/list/1", 30) + "/v\n",
+		},
+		{
+			name:   "definition only referencing another",
+			schema: `{"$defs": {"money": {"type": "object", "properties": {"amount": {"type": "number", "minimum": 0}}, "required": ["amount"]}, "price": {"$ref": "#/$defs/money"}}}`,
+			program: `decode(&Price{}, ` + "`" + `{"amount": 1}` + "`" + `)
+	decode(&Price{}, ` + "`" + `{}` + "`" + `)
+	decode(&Price{}, ` + "`" + `{"amount": -1}` + "`" + `)`,
+			output: "<nil>\nmissing property /amount\nnumber check failed\n",
+		},
+		{
+			name: "reference by pointer",
+			schema: `{"$defs": {
+				"money": {"type": "object", "properties": {"amount": {"type": "number", "minimum": 0}}, "required": ["amount"]},
+				"order": {"type": "object", "properties": {"price": {"$ref": "#/$defs/money"}, "note": {"type": "string", "maxLength": 2}}},
+				"ptr": {"$ref": "#/$defs/order/properties/price"},
+				"note": {"$ref": "#/$defs/order/properties/note"}
+			}}`,
+			program: `decode(&Ptr{}, ` + "`" + `{"amount": 1}` + "`" + `)
+	decode(&Ptr{}, ` + "`" + `{}` + "`" + `)
+	decode(&OrderPrice{}, ` + "`" + `{"amount": -1}` + "`" + `)
+	decode(&Order{}, ` + "`" + `{"price": {}}` + "`" + `)
+	decode(new(Note), ` + "`" + `"abc"` + "`" + `)`,
+			output: "<nil>\nmissing property /amount\nnumber check failed\nmissing property /price/amount\nstring check length failed\n",
+		},
+		{
+			name:   "fractional multipleOf",
+			schema: `{"$defs": {"price": {"type": "object", "properties": {"amount": {"type": "number", "multipleOf": 0.01}, "step": {"type": "number", "multipleOf": 0.1}, "count": {"type": "integer", "multipleOf": 2.5}}}}}`,
+			program: `decode(&Price{}, ` + "`" + `{"amount": 19.99, "step": 0.3, "count": 10}` + "`" + `)
+	decode(&Price{}, ` + "`" + `{"amount": 19.999}` + "`" + `)
+	decode(&Price{}, ` + "`" + `{"step": 0.35}` + "`" + `)
+	decode(&Price{}, ` + "`" + `{"count": 6}` + "`" + `)`,
+			output: "<nil>\nnumber check failed\nnumber check failed\ninteger check failed\n",
+		},
+		{
+			name:   "allOf of patterns and multiples",
+			schema: `{"$defs": {"item": {"allOf": [{"type": "object", "properties": {"code": {"type": "string", "pattern": "^a"}, "step": {"type": "number", "multipleOf": 0.1}}}, {"properties": {"code": {"pattern": "b$"}, "step": {"multipleOf": 0.3}}}]}}}`,
+			program: `decode(&Item{}, ` + "`" + `{"code": "aab", "step": 0.9}` + "`" + `)
+	decode(&Item{}, ` + "`" + `{"code": "ba"}` + "`" + `)
+	decode(&Item{}, ` + "`" + `{"code": "aba"}` + "`" + `)
+	decode(&Item{}, ` + "`" + `{"step": 0.2}` + "`" + `)`,
+			output: "<nil>\nstring check pattern failed\nstring check pattern failed\nnumber check failed\n",
+		},
+		{
+			name:   "multipleOf of large values",
+			schema: `{"$defs": {"even": {"type": "object", "properties": {"count": {"type": "integer", "multipleOf": 2}, "size": {"type": "number", "multipleOf": 2}}}}}`,
+			program: `decode(&Even{}, ` + "`" + `{"count": 4000000000, "size": 4000000000}` + "`" + `)
+	decode(&Even{}, ` + "`" + `{"count": 1000000001}` + "`" + `)
+	decode(&Even{}, ` + "`" + `{"count": 2000000001}` + "`" + `)
+	decode(&Even{}, ` + "`" + `{"size": 1000000000000001}` + "`" + `)`,
+			output: "<nil>\ninteger check failed\ninteger check failed\nnumber check failed\n",
+		},
+		{
+			name:   "names which are not identifiers",
+			schema: `{"$defs": {"a/b": {"type": "object", "properties": {"x y": {"type": "object", "properties": {"2fa": {"type": "string"}, "a.b": {"type": "string", "enum": ["x y", "z"]}}, "required": ["a.b"]}}}}}`,
+			program: `value := AB{}
+	decode(&value, ` + "`" + `{"x y": {"2fa": "a", "a.b": "x y"}}` + "`" + `)
+	fmt.Println(*value.XY.X2fa, value.XY.AB == ABXYABXY)
+	decode(&value, ` + "`" + `{"x y": {}}` + "`" + `)`,
+			output: "<nil>\na true\nmissing property /x y/a.b\n",
+		},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			for _, split := range []bool{false, true} {
+				config := &GolangConfig{}
+				config.NoHoist = c.noHoist
+				config.Split = split
+				if out := runGo(t, c.schema, config, c.program); out != c.output {
+					t.Errorf("split %t: got\n%s\nwant\n%s", split, out, c.output)
+				}
+			}
+		})
+	}
+}
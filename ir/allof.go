@@ -0,0 +1,290 @@
+package ir
+
+import (
+	"errors"
+	"fmt"
+	"github.com/azurity/schema2code/common"
+	"github.com/azurity/schema2code/schemas"
+	"reflect"
+)
+
+// flatten merges desc with the branches of its allOf, following $ref, into
+// one schema. Properties defined by several branches are merged the same way.
+func (b *builder) flatten(desc *schemas.Type) (*schemas.Type, error) {
+	return b.flattenVisiting(desc, map[*schemas.Type]bool{})
+}
+
+func (b *builder) flattenVisiting(desc *schemas.Type, visiting map[*schemas.Type]bool) (*schemas.Type, error) {
+	if visiting[desc] {
+		return nil, schemas.ErrorAt(desc, "allOf includes itself")
+	}
+	visiting[desc] = true
+	defer delete(visiting, desc)
+
+	merged := *desc
+	merged.AllOf = nil
+	merged.Properties = map[string]*schemas.Type{}
+	for name, property := range desc.Properties {
+		merged.Properties[name] = property
+	}
+	merged.Required = append([]string{}, desc.Required...)
+	checks := &checkLists{}
+	checks.add(b.checksOf(desc))
+	for _, branch := range desc.AllOf {
+		part := branch
+		var origin *Type
+		for part.Ref != nil {
+			name, ok := b.refs[part]
+			if !ok {
+				return nil, schemas.ErrorAt(part, fmt.Sprintf("unresolved $ref %s", *part.Ref))
+			}
+			origin = b.types[name]
+			if visiting[origin.Schema] {
+				return nil, schemas.ErrorAt(branch, "allOf includes itself")
+			}
+			part = origin.Schema
+		}
+		if part.AllOf != nil {
+			flat, err := b.flattenVisiting(part, visiting)
+			if err != nil {
+				return nil, err
+			}
+			part = flat
+		}
+		if err := unsupportedKeyword(part, true); err != nil {
+			return nil, err
+		}
+		if origin != nil {
+			// inline types inherited from a definition are named after it
+			for name, property := range part.Properties {
+				if _, ok := b.inherited[property]; !ok {
+					b.inherited[property] = append(append([]string{}, origin.Path...), common.PointerName([]string{"properties", name})...)
+				}
+			}
+		}
+		if err := mergeSchema(&merged, part); err != nil {
+			return nil, schemas.WrapErrorAt(branch, err)
+		}
+		checks.add(b.checksOf(part))
+	}
+	b.checks[&merged] = checks
+	return &merged, nil
+}
+
+// checkLists are patterns and multipleOf values, every one of which a value
+// must satisfy.
+type checkLists struct {
+	patterns  []string
+	multiples []float64
+}
+
+// checksOf returns the patterns and multipleOf values of desc, of all its
+// branches when it was flattened.
+func (b *builder) checksOf(desc *schemas.Type) *checkLists {
+	if checks, ok := b.checks[desc]; ok {
+		return checks
+	}
+	checks := &checkLists{}
+	if desc.Pattern != nil {
+		checks.patterns = []string{*desc.Pattern}
+	}
+	if desc.MultipleOf != nil {
+		checks.multiples = []float64{*desc.MultipleOf}
+	}
+	return checks
+}
+
+func (c *checkLists) add(other *checkLists) {
+patterns:
+	for _, pattern := range other.patterns {
+		for _, current := range c.patterns {
+			if current == pattern {
+				continue patterns
+			}
+		}
+		c.patterns = append(c.patterns, pattern)
+	}
+multiples:
+	for _, multiple := range other.multiples {
+		for _, current := range c.multiples {
+			if current == multiple {
+				continue multiples
+			}
+		}
+		c.multiples = append(c.multiples, multiple)
+	}
+}
+
+// mergeSchema narrows into to the values part accepts too.
+func mergeSchema(into *schemas.Type, part *schemas.Type) error {
+	if len(part.Type) != 0 {
+		if len(into.Type) == 0 {
+			into.Type = part.Type
+		} else {
+			into.Type = intersectTypes(into.Type, part.Type)
+			if len(into.Type) == 0 {
+				return errors.New("allOf branches have no type in common")
+			}
+		}
+	}
+
+	for name, property := range part.Properties {
+		if current, ok := into.Properties[name]; ok && current != property {
+			into.Properties[name] = &schemas.Type{
+				AllOf:    []*schemas.Type{current, property},
+				Location: property.Location,
+			}
+		} else {
+			into.Properties[name] = property
+		}
+	}
+	for _, name := range part.Required {
+		if !isRequired(into.Required, name) {
+			into.Required = append(into.Required, name)
+		}
+	}
+	if part.PatternProperties != nil {
+		patterns := map[string]*schemas.Type{}
+		for pattern, property := range into.PatternProperties {
+			patterns[pattern] = property
+		}
+		for pattern, property := range part.PatternProperties {
+			if current, ok := patterns[pattern]; ok && current != property {
+				property = &schemas.Type{AllOf: []*schemas.Type{current, property}, Location: property.Location}
+			}
+			patterns[pattern] = property
+		}
+		into.PatternProperties = patterns
+	}
+	into.AdditionalProperties = mergeSubschema(into.AdditionalProperties, part.AdditionalProperties)
+	into.Items = mergeSubschema(into.Items, part.Items)
+
+	if value, exclusive, ok := part.LowerBound(); ok {
+		current, currentExclusive, has := into.LowerBound()
+		if !has || value > current || (value == current && exclusive && !currentExclusive) {
+			into.Minimum, into.ExclusiveMinimum = bound(value, exclusive)
+		}
+	}
+	if value, exclusive, ok := part.UpperBound(); ok {
+		current, currentExclusive, has := into.UpperBound()
+		if !has || value < current || (value == current && exclusive && !currentExclusive) {
+			into.Maximum, into.ExclusiveMaximum = bound(value, exclusive)
+		}
+	}
+	// the schema keeps the first pattern and multipleOf, the builder all of
+	// them
+	if into.MultipleOf == nil {
+		into.MultipleOf = part.MultipleOf
+	}
+
+	into.MinLength = stricterInt(into.MinLength, part.MinLength, true)
+	into.MaxLength = stricterInt(into.MaxLength, part.MaxLength, false)
+	into.MinItems = stricterInt(into.MinItems, part.MinItems, true)
+	into.MaxItems = stricterInt(into.MaxItems, part.MaxItems, false)
+	into.MinProperties = stricterInt(into.MinProperties, part.MinProperties, true)
+	into.MaxProperties = stricterInt(into.MaxProperties, part.MaxProperties, false)
+	into.UniqueItems = into.UniqueItems || part.UniqueItems
+	into.ReadOnly = into.ReadOnly || part.ReadOnly
+	into.Deprecated = into.Deprecated || part.Deprecated
+
+	if into.Pattern == nil {
+		into.Pattern = part.Pattern
+	}
+	if part.Format != nil {
+		if into.Format != nil && *into.Format != *part.Format {
+			return errors.New(fmt.Sprintf("allOf branches have formats %s and %s", *into.Format, *part.Format))
+		}
+		into.Format = part.Format
+	}
+	if part.Const != nil {
+		if into.Const != nil && !reflect.DeepEqual(*into.Const, *part.Const) {
+			return errors.New("allOf branches have different consts")
+		}
+		into.Const = part.Const
+	}
+	if part.Enum != nil {
+		if into.Enum == nil {
+			into.Enum = part.Enum
+		} else {
+			values := []interface{}{}
+			for _, value := range into.Enum {
+				for _, other := range part.Enum {
+					if reflect.DeepEqual(value, other) {
+						values = append(values, value)
+						break
+					}
+				}
+			}
+			if len(values) == 0 {
+				return errors.New("allOf branches have no enum value in common")
+			}
+			into.Enum = values
+		}
+	}
+
+	if part.OneOf != nil || part.AnyOf != nil {
+		if into.OneOf != nil || into.AnyOf != nil {
+			return errors.New("allOf of several unions is not supported")
+		}
+		into.OneOf = part.OneOf
+		into.AnyOf = part.AnyOf
+		if into.EffectiveDiscriminator() == nil {
+			into.Discriminator = part.EffectiveDiscriminator()
+		}
+	}
+	return nil
+}
+
+// mergeSubschema returns a schema of the values both a and b accept.
+func mergeSubschema(a *schemas.Type, b *schemas.Type) *schemas.Type {
+	if a == nil || a == b {
+		return b
+	}
+	if b == nil {
+		return a
+	}
+	return &schemas.Type{AllOf: []*schemas.Type{a, b}, Location: b.Location}
+}
+
+// intersectTypes returns the type names in both a and b, an integer being a
+// number too.
+func intersectTypes(a schemas.TypeList, b schemas.TypeList) schemas.TypeList {
+	result := schemas.TypeList{}
+	for _, name := range a {
+		for _, other := range b {
+			switch {
+			case name == other:
+				result = append(result, name)
+			case name == schemas.TypeNameInteger && other == schemas.TypeNameNumber,
+				name == schemas.TypeNameNumber && other == schemas.TypeNameInteger:
+				result = append(result, schemas.TypeNameInteger)
+			default:
+				continue
+			}
+			break
+		}
+	}
+	return result
+}
+
+func bound(value float64, exclusive bool) (*float64, *schemas.ExclusiveBound) {
+	if exclusive {
+		return nil, &schemas.ExclusiveBound{Number: &value}
+	}
+	return &value, nil
+}
+
+// stricterInt returns the larger of two limits when lower is set, else the
+// smaller.
+func stricterInt(a *int, b *int, lower bool) *int {
+	if a == nil {
+		return b
+	}
+	if b == nil {
+		return a
+	}
+	if (*b > *a) == lower {
+		return b
+	}
+	return a
+}
@@ -0,0 +1,646 @@
+package ir
+
+import (
+	"errors"
+	"fmt"
+	"github.com/azurity/schema2code/common"
+	"github.com/azurity/schema2code/schemas"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+type builder struct {
+	types map[string]*Type
+	refs  map[*schemas.Type]string
+	// hoist names inline types, and schemaTypes finds the type of a schema.
+	hoist       bool
+	schemaTypes map[*schemas.Type]*Type
+	// hoisted are the types referenced by hoisted nodes since the last build.
+	hoisted []*Type
+	// inherited are the paths of the properties of definitions merged by
+	// allOf, which name their inline types.
+	inherited map[*schemas.Type][]string
+	// checks are the patterns and multipleOf values of the schemas flattened
+	// from allOf, which have room for one of each.
+	checks map[*schemas.Type]*checkLists
+}
+
+func walkDefs(baseKey []string, defs schemas.Definitions, action func(key []string, item *schemas.Type) error) error {
+	if defs == nil {
+		return nil
+	}
+	for key, item := range defs {
+		newKey := append(baseKey, key)
+		if err := action(append([]string{}, newKey...), item); err != nil {
+			return err
+		}
+		if err := walkDefs(newKey, item.Definitions, action); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Input is a loaded document to build types from.
+type Input struct {
+	Doc *schemas.Document
+	// RootType names the type of the document root, which is needed when the
+	// root describes a value.
+	RootType string
+}
+
+// Options adjust how a module is built.
+type Options struct {
+	// Overrides replace types, by name, with types provided by the
+	// application. Such types are referenced but not built.
+	Overrides map[string]common.TypeOverride
+	// Include selects the types whose name matches one of these patterns, all
+	// of them when empty. Patterns match the names segment by segment like
+	// path.Match, "**" matching any number of segments.
+	Include []string
+	// Exclude drops the types whose name matches one of these patterns from
+	// the selection.
+	Exclude []string
+	// Reachable keeps the selected types and those they reference,
+	// transitively. Otherwise selected types may only reference selected or
+	// overridden ones.
+	Reachable bool
+	// Hoist turns the inline objects, enums and unions into named types, named
+	// after their title or their path in the type they belong to. They are
+	// generated along with it.
+	Hoist bool
+	// Dedupe merges the types of the same structure, DedupeNone by default.
+	Dedupe Dedupe
+}
+
+// Build turns loaded documents into one module. Their definitions become
+// types, as well as each root describing a value. The documents are expected
+// to be normalized already, which the resolver does when loading them.
+func Build(resolver *schemas.Resolver, inputs []Input, options *Options) (*Module, error) {
+	if options == nil {
+		options = &Options{}
+	}
+	types := map[string]*Type{}
+	origins := map[string]*schemas.Document{}
+	for _, input := range inputs {
+		doc := input.Doc
+		if doc.Schema.HasRootType() {
+			if input.RootType == "" {
+				return nil, errors.New(fmt.Sprintf("need a root-type name for %s", doc.Name()))
+			}
+			if _, ok := types[input.RootType]; ok {
+				return nil, errors.New(fmt.Sprintf("duplicate name %s", input.RootType))
+			}
+			types[input.RootType] = &Type{
+				Name:   input.RootType,
+				Path:   []string{input.RootType},
+				Schema: doc.Root,
+			}
+			origins[input.RootType] = doc
+		}
+		err := walkDefs([]string{}, doc.Schema.Definitions, func(key []string, item *schemas.Type) error {
+			unifiedName := strings.Join(key, "/")
+			if _, ok := types[unifiedName]; ok {
+				return errors.New(fmt.Sprintf("duplicate name %s", unifiedName))
+			}
+			types[unifiedName] = &Type{
+				Name:   unifiedName,
+				Path:   key,
+				Schema: item,
+			}
+			origins[unifiedName] = doc
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	refs, err := resolveRefs(resolver, origins, types)
+	if err != nil {
+		return nil, err
+	}
+
+	for name := range options.Overrides {
+		if _, ok := types[name]; !ok {
+			return nil, errors.New(fmt.Sprintf("override of unknown type %s", name))
+		}
+	}
+
+	if err := checkPatterns(options.Include); err != nil {
+		return nil, err
+	}
+	if err := checkPatterns(options.Exclude); err != nil {
+		return nil, err
+	}
+	for _, pattern := range options.Include {
+		matched := false
+		for name := range types {
+			matched = matched || matchAny([]string{pattern}, name)
+		}
+		if !matched {
+			return nil, errors.New(fmt.Sprintf("include pattern %q matches no type", pattern))
+		}
+	}
+
+	b := &builder{
+		types:       types,
+		refs:        refs,
+		hoist:       options.Hoist,
+		schemaTypes: map[*schemas.Type]*Type{},
+		inherited:   map[*schemas.Type][]string{},
+		checks:      map[*schemas.Type]*checkLists{},
+	}
+	for _, value := range types {
+		b.schemaTypes[value.Schema] = value
+	}
+	selected := []*Type{}
+	for _, value := range types {
+		if (len(options.Include) == 0 || matchAny(options.Include, value.Name)) && !matchAny(options.Exclude, value.Name) {
+			selected = append(selected, value)
+		}
+	}
+	sortTypes(selected)
+
+	// build the selected types, then those they reference when reachable
+	kept := map[*Type]bool{}
+	for _, value := range selected {
+		kept[value] = true
+	}
+	module := &Module{}
+	for len(selected) != 0 {
+		value := selected[0]
+		selected = selected[1:]
+		module.Types = append(module.Types, value)
+		if override, ok := options.Overrides[value.Name]; ok {
+			value.Override = &override
+			continue
+		}
+		value.Node, err = b.node(value.Path, value.Schema)
+		if err != nil {
+			return nil, err
+		}
+		for _, hoisted := range b.hoisted {
+			if !kept[hoisted] {
+				kept[hoisted] = true
+				selected = append(selected, hoisted)
+			}
+		}
+		b.hoisted = nil
+		walkRefs(value.Node, func(node *Node) {
+			ref := node.Ref
+			if kept[ref] {
+				return
+			}
+			if _, ok := options.Overrides[ref.Name]; ok || options.Reachable {
+				kept[ref] = true
+				selected = append(selected, ref)
+			} else if err == nil {
+				err = schemas.ErrorAt(node.Schema, fmt.Sprintf("type %s references %s, which is not selected; include it or enable reachable", value.Name, ref.Name))
+			}
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	sortTypes(module.Types)
+	if err := dedupe(module, options.Dedupe); err != nil {
+		return nil, err
+	}
+	return module, nil
+}
+
+func sortTypes(types []*Type) {
+	sort.Slice(types, func(i, j int) bool { return types[i].Name < types[j].Name })
+}
+
+func stringEnum(values []interface{}) ([]string, bool) {
+	result := []string{}
+	for _, item := range values {
+		cased, ok := item.(string)
+		if !ok {
+			return nil, false
+		}
+		result = append(result, cased)
+	}
+	return result, true
+}
+
+func (b *builder) node(path []string, desc *schemas.Type) (*Node, error) {
+	if desc == nil {
+		return nil, errors.New("must define type impl")
+	}
+	union := desc.OneOf != nil || desc.AnyOf != nil
+	if err := unsupportedKeyword(desc, desc.Ref != nil || union); err != nil {
+		return nil, err
+	}
+	node := &Node{
+		Path:       path,
+		Schema:     desc,
+		ReadOnly:   desc.ReadOnly,
+		Deprecated: desc.Deprecated,
+	}
+	if desc.Ref != nil {
+		name, ok := b.refs[desc]
+		if !ok {
+			return nil, schemas.ErrorAt(desc, fmt.Sprintf("unresolved $ref %s", *desc.Ref))
+		}
+		node.Kind = KindRef
+		node.Ref = b.types[name]
+		return node, nil
+	}
+	if desc.AllOf != nil {
+		merged, err := b.flatten(desc)
+		if err != nil {
+			return nil, err
+		}
+		return b.node(path, merged)
+	}
+	if union {
+		return b.union(node, path, desc)
+	}
+	if desc.UnevaluatedProperties != nil || desc.UnevaluatedItems != nil {
+		// with allOf flattened, the properties and items no other keyword
+		// evaluates are those of additionalProperties and items
+		evaluated := *desc
+		if evaluated.AdditionalProperties == nil {
+			evaluated.AdditionalProperties = desc.UnevaluatedProperties
+		}
+		if evaluated.Items == nil {
+			evaluated.Items = desc.UnevaluatedItems
+		}
+		evaluated.UnevaluatedProperties = nil
+		evaluated.UnevaluatedItems = nil
+		desc = &evaluated
+		node.Schema = desc
+	}
+	types := desc.EffectiveType()
+	if len(types) == 0 {
+		return nil, schemas.ErrorAt(desc, "no type is given, set type, or a const or enum of one type")
+	}
+	if len(types) != 1 {
+		return b.typeUnion(node, path, desc, types)
+	}
+	kind, ok := kindNames[types[0]]
+	if !ok {
+		return nil, schemas.ErrorAt(desc, fmt.Sprintf("unknown type %s", types[0]))
+	}
+	node.Kind = kind
+	if kind != KindNull {
+		// a const is an enum of one value
+		if desc.Enum != nil {
+			values, ok := stringEnum(desc.Enum)
+			if !ok || kind != KindString {
+				return nil, schemas.ErrorAt(desc, "only support string enum")
+			}
+			node.Enum = values
+		}
+		if desc.Const != nil {
+			value, ok := (*desc.Const).(string)
+			if !ok || kind != KindString {
+				return nil, schemas.ErrorAt(desc, "only support string const")
+			}
+			found := node.Enum == nil
+			for _, item := range node.Enum {
+				found = found || item == value
+			}
+			if !found {
+				return nil, schemas.ErrorAt(desc, fmt.Sprintf("const %q is not one of enum", value))
+			}
+			node.Enum = []string{value}
+		}
+	}
+
+	switch kind {
+	case KindInteger, KindNumber:
+		if value, exclusive, ok := desc.LowerBound(); ok {
+			node.Minimum = &Bound{Value: value, Exclusive: exclusive}
+		}
+		if value, exclusive, ok := desc.UpperBound(); ok {
+			node.Maximum = &Bound{Value: value, Exclusive: exclusive}
+		}
+		node.MultipleOf = b.checksOf(desc).multiples
+	case KindString:
+		node.Format = desc.Format
+		node.MinLength = desc.MinLength
+		node.MaxLength = desc.MaxLength
+		node.Pattern = b.checksOf(desc).patterns
+	case KindArray:
+		if desc.PrefixItems != nil {
+			return nil, schemas.ErrorAt(desc, "tuple arrays are not supported")
+		}
+		if desc.Items == nil {
+			return nil, schemas.ErrorAt(desc, "array must have item type")
+		}
+		node.MinItems = desc.MinItems
+		node.MaxItems = desc.MaxItems
+		node.UniqueItems = desc.UniqueItems
+		items, err := b.child(append(append([]string{}, path...), common.PointerName([]string{"items"})...), desc.Items)
+		if err != nil {
+			return nil, err
+		}
+		node.Items = items
+	case KindObject:
+		names := []string{}
+		for name := range desc.Properties {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			value, err := b.child(append(append([]string{}, path...), common.PointerName([]string{"properties", name})...), desc.Properties[name])
+			if err != nil {
+				return nil, err
+			}
+			node.Properties = append(node.Properties, &Property{
+				Name:     name,
+				Required: isRequired(desc.Required, name),
+				Node:     value,
+			})
+		}
+		patterns := []string{}
+		for pattern := range desc.PatternProperties {
+			patterns = append(patterns, pattern)
+		}
+		sort.Strings(patterns)
+		for i, pattern := range patterns {
+			value, err := b.child(append(append([]string{}, path...), fmt.Sprintf("pattern%d", i+1)), desc.PatternProperties[pattern])
+			if err != nil {
+				return nil, err
+			}
+			node.PatternProperties = append(node.PatternProperties, &PatternProperty{Pattern: pattern, Node: value})
+		}
+		if additional := desc.AdditionalProperties; additional != nil {
+			if additional.IsFalse() {
+				node.Closed = true
+			} else if !additional.IsTrue() {
+				value, err := b.child(append(append([]string{}, path...), common.PointerName([]string{"additionalProperties"})...), additional)
+				if err != nil {
+					return nil, err
+				}
+				node.AdditionalProperties = value
+			}
+		}
+	}
+	return node, nil
+}
+
+// union builds the variants of a oneOf or anyOf, told apart by its
+// discriminator when it has one.
+func (b *builder) union(node *Node, path []string, desc *schemas.Type) (*Node, error) {
+	keyword, variants := "anyOf", desc.AnyOf
+	if desc.OneOf != nil {
+		if desc.AnyOf != nil {
+			return nil, schemas.ErrorAt(desc, "oneOf beside anyOf is not supported")
+		}
+		keyword, variants = "oneOf", desc.OneOf
+	}
+	if len(desc.Properties) != 0 {
+		return nil, schemas.ErrorAt(desc, fmt.Sprintf("properties beside %s are not supported, move them into the variants", keyword))
+	}
+	node.Kind = KindUnion
+	node.Exclusive = keyword == "oneOf"
+	discriminator := desc.EffectiveDiscriminator()
+	if discriminator == nil {
+		for i, item := range variants {
+			variant, err := b.child(append(append([]string{}, path...), common.PointerName([]string{keyword, strconv.Itoa(i)})...), item)
+			if err != nil {
+				return nil, err
+			}
+			node.Variants = append(node.Variants, variant)
+		}
+		return node, nil
+	}
+
+	node.Discriminator = &Discriminator{Property: discriminator.PropertyName}
+	mapped := map[string]bool{}
+	seen := map[string]int{}
+	for i, item := range variants {
+		variant, err := b.child(append(append([]string{}, path...), common.PointerName([]string{keyword, strconv.Itoa(i)})...), item)
+		if err != nil {
+			return nil, err
+		}
+		values, err := b.tags(discriminator, item, mapped)
+		if err != nil {
+			return nil, err
+		}
+		for _, value := range values {
+			if other, ok := seen[value]; ok {
+				return nil, schemas.ErrorAt(desc, fmt.Sprintf("variants %d and %d both have the discriminator value %q", other, i, value))
+			}
+			seen[value] = i
+		}
+		node.Variants = append(node.Variants, variant)
+		node.Discriminator.Values = append(node.Discriminator.Values, values)
+	}
+	for value := range discriminator.Mapping {
+		if !mapped[value] {
+			return nil, schemas.ErrorAt(desc, fmt.Sprintf("discriminator value %q maps to no variant", value))
+		}
+	}
+	return node, nil
+}
+
+// typeUnion builds the union of the types desc lists, decoded by trial like an
+// anyOf. Each variant keeps the keywords of desc for its type and the values
+// of its enum or const of that type.
+func (b *builder) typeUnion(node *Node, path []string, desc *schemas.Type, types schemas.TypeList) (*Node, error) {
+	node.Kind = KindUnion
+	for _, name := range types {
+		variant := *desc
+		variant.Type = schemas.TypeList{name}
+		variant.Enum = nil
+		for _, item := range desc.Enum {
+			if ofType(item, name) {
+				variant.Enum = append(variant.Enum, item)
+			}
+		}
+		if (desc.Enum != nil && variant.Enum == nil) || (desc.Const != nil && !ofType(*desc.Const, name)) {
+			continue
+		}
+		value, err := b.child(append(append([]string{}, path...), name), &variant)
+		if err != nil {
+			return nil, err
+		}
+		node.Variants = append(node.Variants, value)
+	}
+	if len(node.Variants) == 0 {
+		return nil, schemas.ErrorAt(desc, "no value of enum or const has one of the types")
+	}
+	return node, nil
+}
+
+// ofType reports whether value is of the type named name.
+func ofType(value interface{}, name string) bool {
+	found := schemas.ValueType(value)
+	return found == name || (name == schemas.TypeNameNumber && found == schemas.TypeNameInteger)
+}
+
+// tags returns the discriminator values of a variant: those mapped to it,
+// else the const or enum of the property, else the name of the referenced
+// definition.
+func (b *builder) tags(discriminator *schemas.Discriminator, desc *schemas.Type, mapped map[string]bool) ([]string, error) {
+	var target *Type
+	if desc.Ref != nil {
+		target = b.types[b.refs[desc]]
+	}
+	values := []string{}
+	for value, ref := range discriminator.Mapping {
+		if target != nil && (ref == *desc.Ref || ref == target.Name || ref == target.Path[len(target.Path)-1]) {
+			values = append(values, value)
+			mapped[value] = true
+		}
+	}
+	if len(values) != 0 {
+		sort.Strings(values)
+		return values, nil
+	}
+
+	schema := desc
+	if target != nil {
+		schema = target.Schema
+	}
+	if schema.AllOf != nil {
+		// the property may come from any branch
+		flat, err := b.flatten(schema)
+		if err != nil {
+			return nil, err
+		}
+		schema = flat
+	}
+	if property, ok := schema.Properties[discriminator.PropertyName]; ok && property != nil {
+		if property.AllOf != nil {
+			flat, err := b.flatten(property)
+			if err != nil {
+				return nil, err
+			}
+			property = flat
+		}
+		if property.Const != nil {
+			if value, ok := (*property.Const).(string); ok {
+				return []string{value}, nil
+			}
+		}
+		if values, ok := stringEnum(property.Enum); ok && len(values) != 0 {
+			return values, nil
+		}
+	}
+	if target != nil {
+		return []string{target.Path[len(target.Path)-1]}, nil
+	}
+	return nil, schemas.ErrorAt(desc, fmt.Sprintf("no value of discriminator %s for this variant, map it or set a const", discriminator.PropertyName))
+}
+
+// child builds the node of a subschema at path. With hoist, inline objects,
+// enums and unions become references to named types.
+func (b *builder) child(path []string, desc *schemas.Type) (*Node, error) {
+	if !b.hoist || desc == nil {
+		return b.node(path, desc)
+	}
+	merged := desc
+	if desc.AllOf != nil && desc.Ref == nil {
+		var err error
+		if merged, err = b.flatten(desc); err != nil {
+			return nil, err
+		}
+	}
+	if !hoistable(merged) {
+		return b.node(path, desc)
+	}
+	target, ok := b.schemaTypes[desc]
+	if !ok {
+		typePath := path
+		if inherited, ok := b.inherited[desc]; ok {
+			typePath = inherited
+		}
+		if desc.Title != nil {
+			if words := titleWords(*desc.Title); len(words) != 0 {
+				typePath = words
+			}
+		}
+		name := strings.Join(typePath, "/")
+		if _, ok := b.types[name]; ok {
+			return nil, schemas.ErrorAt(desc, fmt.Sprintf("inline type %s has the name of another type, set a distinct title", name))
+		}
+		target = &Type{
+			Name:   name,
+			Path:   typePath,
+			Schema: desc,
+			Inline: true,
+		}
+		b.types[name] = target
+		b.schemaTypes[desc] = target
+	}
+	b.hoisted = append(b.hoisted, target)
+	return &Node{
+		Kind:       KindRef,
+		Path:       path,
+		Schema:     desc,
+		Ref:        target,
+		ReadOnly:   desc.ReadOnly,
+		Deprecated: desc.Deprecated,
+	}, nil
+}
+
+// unsupportedKeyword fails when desc has a validation keyword the backends do
+// not check, unevaluatedProperties and unevaluatedItems too with unevaluated.
+func unsupportedKeyword(desc *schemas.Type, unevaluated bool) error {
+	keywords := []struct {
+		name string
+		set  bool
+	}{
+		{"not", desc.Not != nil},
+		{"if", desc.If != nil},
+		{"then", desc.Then != nil},
+		{"else", desc.Else != nil},
+		{"contains", desc.Contains != nil},
+		{"minContains", desc.MinContains != nil},
+		{"maxContains", desc.MaxContains != nil},
+		{"propertyNames", desc.PropertyNames != nil},
+		{"minProperties", desc.MinProperties != nil},
+		{"maxProperties", desc.MaxProperties != nil},
+		{"dependentRequired", desc.DependentRequired != nil},
+		{"dependentSchemas", desc.DependentSchemas != nil},
+		{"unevaluatedProperties", unevaluated && desc.UnevaluatedProperties != nil},
+		{"unevaluatedItems", unevaluated && desc.UnevaluatedItems != nil},
+	}
+	for _, keyword := range keywords {
+		if keyword.set {
+			return schemas.ErrorAt(desc, fmt.Sprintf("keyword %s is not supported", keyword.name))
+		}
+	}
+	return nil
+}
+
+// hoistable reports whether an inline schema is worth a named type: an
+// object, a string enum or a union. An allOf is judged once merged.
+func hoistable(desc *schemas.Type) bool {
+	if desc.Ref != nil {
+		return false
+	}
+	types := desc.EffectiveType()
+	if desc.OneOf != nil || desc.AnyOf != nil || len(types) > 1 {
+		return true
+	}
+	if len(types) != 1 {
+		return false
+	}
+	return types[0] == schemas.TypeNameObject || (types[0] == schemas.TypeNameString && desc.Enum != nil)
+}
+
+// titleWords splits a title into the words naming a type.
+func titleWords(title string) []string {
+	return strings.FieldsFunc(title, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+func isRequired(required []string, name string) bool {
+	for _, item := range required {
+		if name == item {
+			return true
+		}
+	}
+	return false
+}
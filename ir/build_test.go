@@ -0,0 +1,210 @@
+package ir
+
+import (
+	"github.com/azurity/schema2code/schemas"
+	"strings"
+	"testing"
+)
+
+func buildSchema(t *testing.T, schema string, options *Options) (*Module, error) {
+	t.Helper()
+	raw, err := schemas.ReadDocument("file:///test/", strings.NewReader(schema), schemas.FormatJSON)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	resolver := schemas.NewResolver(schemas.NewLoader(""))
+	doc, err := resolver.LoadDocument(raw)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	return Build(resolver, []Input{{Doc: doc}}, options)
+}
+
+func findType(module *Module, name string) *Type {
+	for _, value := range module.Types {
+		if value.Name == name {
+			return value
+		}
+	}
+	return nil
+}
+
+func TestInferredKind(t *testing.T) {
+	cases := []struct {
+		schema string
+		kind   Kind
+		enum   []string
+		err    string
+	}{
+		{schema: `{"const": "cat"}`, kind: KindString, enum: []string{"cat"}},
+		{schema: `{"const": 3}`, err: "only support string const"},
+		{schema: `{"enum": [1, 2.5]}`, err: "only support string enum"},
+		{schema: `{"const": true}`, err: "only support string const"},
+		{schema: `{"const": null}`, kind: KindNull},
+		{schema: `{"enum": ["a", "b"]}`, kind: KindString, enum: []string{"a", "b"}},
+		{schema: `{"enum": ["a", 1]}`, err: "no type is given"},
+		{schema: `{"const": "a", "enum": ["a", "b"]}`, kind: KindString, enum: []string{"a"}},
+		{schema: `{"const": "c", "enum": ["a", "b"]}`, err: "const \"c\" is not one of enum"},
+		{schema: `{"const": "a", "type": "integer"}`, err: "only support string const"},
+		{schema: `{"type": "integer", "enum": [1, 2]}`, err: "only support string enum"},
+		{schema: `{"description": "anything"}`, err: "no type is given"},
+		{schema: `{"type": ["string", "integer"]}`, kind: KindUnion},
+		{schema: `{"type": ["string", "null"], "const": 1}`, err: "no value of enum or const has one of the types"},
+	}
+	for _, c := range cases {
+		module, err := buildSchema(t, `{"$defs": {"value": `+c.schema+`}}`, nil)
+		if c.err != "" {
+			if err == nil || !strings.Contains(err.Error(), c.err) {
+				t.Errorf("%s: got error %v, want %q", c.schema, err, c.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", c.schema, err)
+			continue
+		}
+		node := findType(module, "value").Node
+		if node.Kind != c.kind || strings.Join(node.Enum, ",") != strings.Join(c.enum, ",") {
+			t.Errorf("%s: got kind %s and enum %v, want %s and %v", c.schema, node.Kind, node.Enum, c.kind, c.enum)
+		}
+	}
+}
+
+func TestTypeUnion(t *testing.T) {
+	module, err := buildSchema(t, `{"$defs": {
+		"value": {"type": ["object", "string", "null"], "enum": ["a", null], "properties": {"a": {"type": "string"}}},
+		"number": {"type": ["integer", "number"], "minimum": 1}
+	}}`, &Options{Hoist: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	node := findType(module, "value").Node
+	if node.Kind != KindUnion || node.Exclusive || len(node.Variants) != 2 {
+		t.Fatalf("value: got kind %s and %d variants, want a union of 2", node.Kind, len(node.Variants))
+	}
+	if variant := node.Variants[0]; variant.Kind != KindRef || variant.Ref.Name != "value/string" || strings.Join(variant.Ref.Node.Enum, ",") != "a" {
+		t.Errorf("value: got first variant %s, want a reference to the string enum", variant.Kind)
+	}
+	if variant := node.Variants[1]; variant.Kind != KindNull {
+		t.Errorf("value: got second variant %s, want null", variant.Kind)
+	}
+	for _, variant := range findType(module, "number").Node.Variants {
+		if variant.Minimum == nil || variant.Minimum.Value != 1 {
+			t.Errorf("number: variant %s lost its minimum", variant.Kind)
+		}
+	}
+}
+
+func TestDiscriminatorValues(t *testing.T) {
+	cases := []struct {
+		name   string
+		schema string
+		values [][]string
+	}{
+		{
+			name: "const without type",
+			schema: `{"$defs": {
+				"cat": {"type": "object", "properties": {"kind": {"const": "cat"}}},
+				"dog": {"type": "object", "properties": {"kind": {"enum": ["dog", "puppy"]}}},
+				"pet": {"oneOf": [{"$ref": "#/$defs/cat"}, {"$ref": "#/$defs/dog"}], "discriminator": {"propertyName": "kind"}}
+			}}`,
+			values: [][]string{{"cat"}, {"dog", "puppy"}},
+		},
+		{
+			name: "allOf variants",
+			schema: `{"$defs": {
+				"base": {"type": "object", "properties": {"kind": {"type": "string"}}, "required": ["kind"]},
+				"cat": {"allOf": [{"$ref": "#/$defs/base"}, {"properties": {"kind": {"const": "cat"}}}]},
+				"dog": {"allOf": [{"$ref": "#/$defs/base"}]},
+				"pet": {"oneOf": [{"$ref": "#/$defs/cat"}, {"$ref": "#/$defs/dog"}], "discriminator": {"propertyName": "kind"}}
+			}}`,
+			values: [][]string{{"cat"}, {"dog"}},
+		},
+		{
+			name: "mapping",
+			schema: `{"$defs": {
+				"cat": {"type": "object", "properties": {"kind": {"const": "cat"}}},
+				"dog": {"type": "object"},
+				"pet": {"oneOf": [{"$ref": "#/$defs/cat"}, {"$ref": "#/$defs/dog"}], "discriminator": {"propertyName": "kind", "mapping": {"hound": "#/$defs/dog", "mutt": "dog"}}}
+			}}`,
+			values: [][]string{{"cat"}, {"hound", "mutt"}},
+		},
+	}
+	for _, c := range cases {
+		module, err := buildSchema(t, c.schema, nil)
+		if err != nil {
+			t.Errorf("%s: %v", c.name, err)
+			continue
+		}
+		node := findType(module, "pet").Node
+		if node.Kind != KindUnion || node.Discriminator == nil {
+			t.Errorf("%s: not a tagged union", c.name)
+			continue
+		}
+		if got, want := strings.Join(flattenValues(node.Discriminator.Values), "|"), strings.Join(flattenValues(c.values), "|"); got != want {
+			t.Errorf("%s: got values %s, want %s", c.name, got, want)
+		}
+	}
+}
+
+func flattenValues(values [][]string) []string {
+	result := []string{}
+	for _, variant := range values {
+		result = append(result, strings.Join(variant, ","))
+	}
+	return result
+}
+
+func TestUnsupportedKeywords(t *testing.T) {
+	cases := []struct {
+		schema string
+		err    string
+	}{
+		{schema: `{"type": "string", "not": {"const": "a"}}`, err: "#/$defs/value: keyword not is not supported"},
+		{schema: `{"type": "object", "if": {}, "then": {}}`, err: "keyword if is not supported"},
+		{schema: `{"type": "array", "contains": {"type": "string"}}`, err: "keyword contains is not supported"},
+		{schema: `{"type": "object", "propertyNames": {"maxLength": 3}}`, err: "keyword propertyNames is not supported"},
+		{schema: `{"type": "object", "dependentRequired": {"a": ["b"]}}`, err: "keyword dependentRequired is not supported"},
+		{schema: `{"allOf": [{"type": "object"}, {"unevaluatedProperties": false}]}`, err: "keyword unevaluatedProperties is not supported"},
+		{schema: `{"oneOf": [{"type": "object"}], "unevaluatedProperties": false}`, err: "keyword unevaluatedProperties is not supported"},
+	}
+	for _, c := range cases {
+		_, err := buildSchema(t, `{"$defs": {"value": `+c.schema+`}}`, nil)
+		if err == nil || !strings.Contains(err.Error(), c.err) {
+			t.Errorf("%s: got error %v, want %q", c.schema, err, c.err)
+		}
+	}
+}
+
+func TestUnevaluatedProperties(t *testing.T) {
+	module, err := buildSchema(t, `{"$defs": {
+		"closed": {"type": "object", "properties": {"a": {"type": "string"}}, "unevaluatedProperties": false},
+		"extra": {"type": "object", "unevaluatedProperties": {"type": "integer"}}
+	}}`, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if node := findType(module, "closed").Node; !node.Closed {
+		t.Errorf("closed: got an open object")
+	}
+	if node := findType(module, "extra").Node; node.AdditionalProperties == nil || node.AdditionalProperties.Kind != KindInteger {
+		t.Errorf("extra: got additional properties %v, want integers", node.AdditionalProperties)
+	}
+}
+
+func TestAllOfChecks(t *testing.T) {
+	module, err := buildSchema(t, `{"$defs": {
+		"base": {"type": "string", "pattern": "^a"},
+		"code": {"allOf": [{"$ref": "#/$defs/base"}, {"pattern": "b$"}, {"allOf": [{"pattern": "^a"}, {"pattern": "^.{3}$"}]}]},
+		"step": {"type": "number", "multipleOf": 0.1, "allOf": [{"multipleOf": 0.3}, {"multipleOf": 0.1}]}
+	}}`, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(findType(module, "code").Node.Pattern, " "); got != "^a b$ ^.{3}$" {
+		t.Errorf("code: got patterns %s", got)
+	}
+	if got := findType(module, "step").Node.MultipleOf; len(got) != 2 || got[0] != 0.1 || got[1] != 0.3 {
+		t.Errorf("step: got multipleOf %v, want [0.1 0.3]", got)
+	}
+}
@@ -0,0 +1,195 @@
+package ir
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// Dedupe is how types of the same structure are merged.
+type Dedupe string
+
+const (
+	// DedupeNone generates every type.
+	DedupeNone Dedupe = "none"
+	// DedupeAlias turns the copies into aliases of one of them.
+	DedupeAlias Dedupe = "alias"
+	// DedupeReference points every reference to the one kept and drops the
+	// inline copies. Copied definitions remain as aliases.
+	DedupeReference Dedupe = "reference"
+)
+
+// shape is the structure of a node, references being named by type.
+type shape struct {
+	Kind          Kind            `json:"kind"`
+	Ref           string          `json:"ref,omitempty"`
+	Enum          []string        `json:"enum,omitempty"`
+	Format        *string         `json:"format,omitempty"`
+	ReadOnly      bool            `json:"readOnly,omitempty"`
+	Items         *shape          `json:"items,omitempty"`
+	Properties    []shapeProperty `json:"properties,omitempty"`
+	Required      []string        `json:"required,omitempty"`
+	Patterns      []shapeProperty `json:"patterns,omitempty"`
+	Additional    *shape          `json:"additional,omitempty"`
+	Closed        bool            `json:"closed,omitempty"`
+	Variants      []*shape        `json:"variants,omitempty"`
+	Discriminator *Discriminator  `json:"discriminator,omitempty"`
+	Exclusive     bool            `json:"exclusive,omitempty"`
+	Constraints
+}
+
+type shapeProperty struct {
+	Name     string `json:"name"`
+	Required bool   `json:"required,omitempty"`
+	Node     *shape `json:"node"`
+}
+
+func nodeShape(node *Node, refName func(ref *Type) string) *shape {
+	if node == nil {
+		return nil
+	}
+	result := &shape{
+		Kind:          node.Kind,
+		Enum:          node.Enum,
+		Format:        node.Format,
+		ReadOnly:      node.ReadOnly,
+		Items:         nodeShape(node.Items, refName),
+		Additional:    nodeShape(node.AdditionalProperties, refName),
+		Closed:        node.Closed,
+		Discriminator: node.Discriminator,
+		Exclusive:     node.Exclusive,
+		Constraints:   node.Constraints,
+	}
+	if node.Ref != nil {
+		result.Ref = refName(node.Ref)
+	}
+	if node.Kind == KindObject && node.Schema != nil && len(node.Schema.Required) != 0 {
+		// required names need not be declared properties
+		result.Required = append([]string{}, node.Schema.Required...)
+		sort.Strings(result.Required)
+	}
+	for _, property := range node.Properties {
+		result.Properties = append(result.Properties, shapeProperty{
+			Name:     property.Name,
+			Required: property.Required,
+			Node:     nodeShape(property.Node, refName),
+		})
+	}
+	for _, property := range node.PatternProperties {
+		result.Patterns = append(result.Patterns, shapeProperty{
+			Name: property.Pattern,
+			Node: nodeShape(property.Node, refName),
+		})
+	}
+	for _, variant := range node.Variants {
+		result.Variants = append(result.Variants, nodeShape(variant, refName))
+	}
+	return result
+}
+
+// StructuralHash hashes what node describes, ignoring annotations and names
+// but the names of properties and referenced types. Nodes of the same hash
+// describe the same values.
+func StructuralHash(node *Node) string {
+	return structuralHash(node, func(ref *Type) string { return ref.Name })
+}
+
+func structuralHash(node *Node, refName func(ref *Type) string) string {
+	data, _ := json.Marshal(nodeShape(node, refName))
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// preferred tells which of two identical types is kept: a definition over an
+// inline type, then the shorter path, then the first name.
+func preferred(a *Type, b *Type) bool {
+	if a.Inline != b.Inline {
+		return !a.Inline
+	}
+	if len(a.Path) != len(b.Path) {
+		return len(a.Path) < len(b.Path)
+	}
+	return a.Name < b.Name
+}
+
+// dedupe merges the types of module with the same structure. Types are split
+// into classes by their structure, references being named by the class of the
+// referenced type, until no class splits further, so that recursive types
+// referencing themselves merge too.
+func dedupe(module *Module, policy Dedupe) error {
+	switch policy {
+	case "", DedupeNone:
+		return nil
+	case DedupeAlias, DedupeReference:
+	default:
+		return errors.New(fmt.Sprintf("unknown dedupe policy %q, expected none, alias or reference", policy))
+	}
+
+	class := map[*Type]string{}
+	refName := func(ref *Type) string {
+		if ref.Node == nil {
+			return ref.Name
+		}
+		return class[ref]
+	}
+	for count := -1; ; {
+		next := map[*Type]string{}
+		classes := map[string]bool{}
+		for _, value := range module.Types {
+			if value.Node != nil {
+				next[value] = structuralHash(value.Node, refName)
+				classes[next[value]] = true
+			}
+		}
+		class = next
+		if len(classes) == count {
+			break
+		}
+		count = len(classes)
+	}
+
+	kept := map[string]*Type{}
+	for _, value := range module.Types {
+		if value.Node == nil {
+			continue
+		}
+		if other, ok := kept[class[value]]; !ok || preferred(value, other) {
+			kept[class[value]] = value
+		}
+	}
+	canonical := map[*Type]*Type{}
+	for value, hash := range class {
+		canonical[value] = kept[hash]
+	}
+	find := func(value *Type) *Type {
+		if target, ok := canonical[value]; ok {
+			return target
+		}
+		return value
+	}
+
+	types := []*Type{}
+	for _, value := range module.Types {
+		target := find(value)
+		if target == value {
+			types = append(types, value)
+		} else if policy == DedupeAlias || !value.Inline {
+			// definitions keep their name, the application may use it
+			value.Alias = target
+			value.Node = nil
+			types = append(types, value)
+		}
+	}
+	if policy == DedupeReference {
+		for _, value := range types {
+			walkRefs(value.Node, func(node *Node) {
+				node.Ref = find(node.Ref)
+			})
+		}
+	}
+	module.Types = types
+	return nil
+}
@@ -0,0 +1,45 @@
+package ir
+
+import (
+	"testing"
+)
+
+func TestDedupeRecursive(t *testing.T) {
+	schema := `{"$defs": {
+		"node": {"type": "object", "properties": {"value": {"type": "string"}, "next": {"$ref": "#/$defs/node"}}},
+		"node2": {"type": "object", "properties": {"value": {"type": "string"}, "next": {"$ref": "#/$defs/node2"}}},
+		"other": {"type": "object", "properties": {"value": {"type": "integer"}, "next": {"$ref": "#/$defs/other"}}},
+		"ping": {"type": "object", "properties": {"value": {"type": "string"}, "next": {"$ref": "#/$defs/pong"}}},
+		"pong": {"type": "object", "properties": {"value": {"type": "string"}, "next": {"$ref": "#/$defs/ping"}}},
+		"list": {"type": "array", "items": {"$ref": "#/$defs/node2"}}
+	}}`
+	module, err := buildSchema(t, schema, &Options{Dedupe: DedupeReference})
+	if err != nil {
+		t.Fatal(err)
+	}
+	aliases := map[string]string{
+		"node":  "",
+		"node2": "node",
+		"other": "",
+		"ping":  "node",
+		"pong":  "node",
+		"list":  "",
+	}
+	for name, alias := range aliases {
+		value := findType(module, name)
+		if value == nil {
+			t.Errorf("%s: missing", name)
+			continue
+		}
+		got := ""
+		if value.Alias != nil {
+			got = value.Alias.Name
+		}
+		if got != alias {
+			t.Errorf("%s: got alias %q, want %q", name, got, alias)
+		}
+	}
+	if ref := findType(module, "list").Node.Items.Ref; ref.Name != "node" {
+		t.Errorf("list: got items of %s, want node", ref.Name)
+	}
+}
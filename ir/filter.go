@@ -0,0 +1,74 @@
+package ir
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+)
+
+// checkPatterns reports the first malformed pattern.
+func checkPatterns(patterns []string) error {
+	for _, pattern := range patterns {
+		for _, segment := range strings.Split(pattern, "/") {
+			if _, err := path.Match(segment, ""); err != nil {
+				return errors.New(fmt.Sprintf("invalid pattern %q", pattern))
+			}
+		}
+	}
+	return nil
+}
+
+// matchAny reports whether the type name matches one of patterns. Patterns
+// are matched segment by segment with path.Match, "**" matching any number of
+// segments.
+func matchAny(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if matchSegments(strings.Split(pattern, "/"), strings.Split(name, "/")) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchSegments(pattern []string, name []string) bool {
+	if len(pattern) == 0 {
+		return len(name) == 0
+	}
+	if pattern[0] == "**" {
+		for i := 0; i <= len(name); i += 1 {
+			if matchSegments(pattern[1:], name[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(name) == 0 {
+		return false
+	}
+	if ok, _ := path.Match(pattern[0], name[0]); !ok {
+		return false
+	}
+	return matchSegments(pattern[1:], name[1:])
+}
+
+// walkRefs calls action with every KindRef node under node.
+func walkRefs(node *Node, action func(ref *Node)) {
+	if node == nil {
+		return
+	}
+	if node.Kind == KindRef {
+		action(node)
+	}
+	walkRefs(node.Items, action)
+	for _, property := range node.Properties {
+		walkRefs(property.Node, action)
+	}
+	for _, property := range node.PatternProperties {
+		walkRefs(property.Node, action)
+	}
+	walkRefs(node.AdditionalProperties, action)
+	for _, variant := range node.Variants {
+		walkRefs(variant, action)
+	}
+}
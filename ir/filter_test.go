@@ -0,0 +1,54 @@
+package ir
+
+import (
+	"github.com/azurity/schema2code/common"
+	"strings"
+	"testing"
+)
+
+func TestSelectTypes(t *testing.T) {
+	schema := `{"$defs": {
+		"user": {"type": "object", "properties": {"address": {"$ref": "#/$defs/address"}, "orders": {"type": "array", "items": {"$ref": "#/$defs/order"}}}},
+		"address": {"type": "object"},
+		"order": {"type": "object", "properties": {"item": {"$ref": "#/$defs/item"}}},
+		"item": {"type": "object"},
+		"admin": {"type": "string", "$defs": {"role": {"type": "string"}, "right": {"type": "object", "$defs": {"scope": {"type": "string"}}}}}
+	}}`
+	cases := []struct {
+		name    string
+		options *Options
+		types   string
+		err     string
+	}{
+		{name: "every type", options: &Options{}, types: "address admin admin/right admin/right/scope admin/role item order user"},
+		{name: "reachable", options: &Options{Include: []string{"user"}, Reachable: true}, types: "address item order user"},
+		{name: "not reachable", options: &Options{Include: []string{"user"}}, err: "type user references address, which is not selected"},
+		{name: "excluded reference", options: &Options{Include: []string{"order", "item"}, Exclude: []string{"item"}}, err: "type order references item"},
+		{name: "override", options: &Options{Include: []string{"order"}, Overrides: map[string]common.TypeOverride{"item": {Type: "Item"}}}, types: "item order"},
+		{name: "segment pattern", options: &Options{Include: []string{"admin/*"}}, types: "admin/right admin/role"},
+		{name: "any segments", options: &Options{Include: []string{"admin/**"}, Exclude: []string{"**/scope"}}, types: "admin admin/right admin/role"},
+		{name: "exclude only", options: &Options{Exclude: []string{"admin", "admin/**", "user"}}, types: "address item order"},
+		{name: "unmatched pattern", options: &Options{Include: []string{"users"}}, err: `include pattern "users" matches no type`},
+		{name: "invalid pattern", options: &Options{Exclude: []string{"a/[b"}}, err: `invalid pattern "a/[b"`},
+	}
+	for _, c := range cases {
+		module, err := buildSchema(t, schema, c.options)
+		if c.err != "" {
+			if err == nil || !strings.Contains(err.Error(), c.err) {
+				t.Errorf("%s: got error %v, want %q", c.name, err, c.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", c.name, err)
+			continue
+		}
+		names := []string{}
+		for _, value := range module.Types {
+			names = append(names, value.Name)
+		}
+		if got := strings.Join(names, " "); got != c.types {
+			t.Errorf("%s: got types %s, want %s", c.name, got, c.types)
+		}
+	}
+}
@@ -0,0 +1,173 @@
+// Package ir is the language-neutral representation of schemas the backends
+// generate code from. Building it resolves every $ref to a named type, rejects
+// what no backend supports and precomputes the constraints of each node, so
+// a backend only has to render it.
+package ir
+
+import (
+	"github.com/azurity/schema2code/common"
+	"github.com/azurity/schema2code/schemas"
+)
+
+type Kind int
+
+const (
+	KindNull Kind = iota
+	KindBoolean
+	KindInteger
+	KindNumber
+	KindString
+	KindArray
+	KindObject
+	// KindRef is a reference to a named type.
+	KindRef
+	// KindUnion is a value of one of its variants.
+	KindUnion
+)
+
+var kindNames = map[string]Kind{
+	schemas.TypeNameNull:    KindNull,
+	schemas.TypeNameBoolean: KindBoolean,
+	schemas.TypeNameInteger: KindInteger,
+	schemas.TypeNameNumber:  KindNumber,
+	schemas.TypeNameString:  KindString,
+	schemas.TypeNameArray:   KindArray,
+	schemas.TypeNameObject:  KindObject,
+}
+
+// String returns the schema type name of the kind, "ref" or "union".
+func (k Kind) String() string {
+	for name, kind := range kindNames {
+		if kind == k {
+			return name
+		}
+	}
+	if k == KindUnion {
+		return "union"
+	}
+	return "ref"
+}
+
+// Module is the set of named types generated from a schema.
+type Module struct {
+	// Types are sorted by name.
+	Types []*Type
+}
+
+// Type is a named type, a definition or the root type of a schema.
+type Type struct {
+	// Name is the path joined with "/", unique in the module.
+	Name string
+	// Path is the name split at nested definitions, backends join it in their
+	// own casing.
+	Path   []string
+	Schema *schemas.Type
+	// Override is the application type replacing this one, Node is nil then.
+	Override *common.TypeOverride
+	// Alias is the type of the same structure this one stands for, when types
+	// are deduplicated. Node is nil then.
+	Alias *Type
+	// Inline is set for types hoisted from an inline schema.
+	Inline bool
+	Node   *Node
+}
+
+// Node is a schema reduced to what the backends generate.
+type Node struct {
+	Kind Kind
+	// Path names the node after the type it belongs to and its place in it,
+	// so inline types can be given a name.
+	Path   []string
+	Schema *schemas.Type
+	// Ref is the referenced type of a KindRef node.
+	Ref *Type
+	// Enum lists the values of a string enum.
+	Enum     []string
+	Format   *string
+	ReadOnly bool
+	// Deprecated is set when the schema marks the values deprecated.
+	Deprecated bool
+	// Items is the item of a KindArray node.
+	Items *Node
+	// Properties of a KindObject node, sorted by name.
+	Properties []*Property
+	// PatternProperties of a KindObject node, sorted by pattern, are the
+	// other properties whose name matches a pattern.
+	PatternProperties []*PatternProperty
+	// AdditionalProperties is the node of the properties of a KindObject node
+	// neither declared nor matching a pattern, nil when they may be anything.
+	AdditionalProperties *Node
+	// Closed is set when a KindObject node allows no additional properties.
+	Closed bool
+	// Variants of a KindUnion node, in the order of the schema.
+	Variants []*Node
+	// Discriminator tells the variants of a KindUnion node apart. When nil,
+	// the variants are tried in order.
+	Discriminator *Discriminator
+	// Exclusive is set for a oneOf: a value must be of exactly one variant.
+	Exclusive bool
+	Constraints
+}
+
+// Discriminator is the property whose value tells which variant of a union
+// an object is.
+type Discriminator struct {
+	Property string `json:"property"`
+	// Values are the values of the property for each variant, the first
+	// one being written.
+	Values [][]string `json:"values"`
+}
+
+type Property struct {
+	Name     string
+	Required bool
+	Node     *Node
+}
+
+type PatternProperty struct {
+	Pattern string
+	Node    *Node
+}
+
+// IsMap reports whether a KindObject node is a dictionary: no declared
+// property, and the same node for every value.
+func (n *Node) IsMap() bool {
+	return n.Kind == KindObject && len(n.Properties) == 0 && len(n.PatternProperties) == 0 && n.AdditionalProperties != nil
+}
+
+// HasExtraProperties reports whether a KindObject node checks properties other
+// than the declared ones, without being a dictionary.
+func (n *Node) HasExtraProperties() bool {
+	return n.Kind == KindObject && !n.IsMap() && (len(n.PatternProperties) != 0 || n.AdditionalProperties != nil || n.Closed)
+}
+
+// Bound is a numeric bound, already combined from the inclusive and exclusive
+// keywords of any draft.
+type Bound struct {
+	Value     float64 `json:"value"`
+	Exclusive bool    `json:"exclusive,omitempty"`
+}
+
+// Constraints are the validation keywords of a node. Only those applying to
+// its kind are set. MultipleOf and Pattern hold several values when allOf
+// branches give different ones, each of which applies.
+type Constraints struct {
+	Minimum    *Bound    `json:"minimum,omitempty"`
+	Maximum    *Bound    `json:"maximum,omitempty"`
+	MultipleOf []float64 `json:"multipleOf,omitempty"`
+
+	MinLength *int     `json:"minLength,omitempty"`
+	MaxLength *int     `json:"maxLength,omitempty"`
+	Pattern   []string `json:"pattern,omitempty"`
+
+	MinItems    *int `json:"minItems,omitempty"`
+	MaxItems    *int `json:"maxItems,omitempty"`
+	UniqueItems bool `json:"uniqueItems,omitempty"`
+}
+
+// IsZero reports whether there is nothing to validate.
+func (c *Constraints) IsZero() bool {
+	return c.Minimum == nil && c.Maximum == nil && len(c.MultipleOf) == 0 &&
+		c.MinLength == nil && c.MaxLength == nil && len(c.Pattern) == 0 &&
+		c.MinItems == nil && c.MaxItems == nil && !c.UniqueItems
+}
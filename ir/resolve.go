@@ -0,0 +1,132 @@
+package ir
+
+import (
+	"errors"
+	"fmt"
+	"github.com/azurity/schema2code/common"
+	"github.com/azurity/schema2code/schemas"
+	"sort"
+	"strings"
+)
+
+type pendingType struct {
+	doc  *schemas.Document
+	item *schemas.Type
+}
+
+type refResolver struct {
+	resolver *schemas.Resolver
+	types    map[string]*Type
+	names    map[*schemas.Type]string
+	// refs maps each schema with a $ref to the name of the referenced type.
+	refs  map[*schemas.Type]string
+	queue []pendingType
+}
+
+// resolveRefs follows every $ref reachable from types, each found in the
+// document given by origins, registering the targets found in other documents
+// or inside other types as new types. It returns the referenced type name of
+// each schema with a $ref.
+func resolveRefs(resolver *schemas.Resolver, origins map[string]*schemas.Document, types map[string]*Type) (map[*schemas.Type]string, error) {
+	r := &refResolver{
+		resolver: resolver,
+		types:    types,
+		names:    map[*schemas.Type]string{},
+		refs:     map[*schemas.Type]string{},
+	}
+
+	sortedNames := []string{}
+	for name := range types {
+		sortedNames = append(sortedNames, name)
+	}
+	sort.Strings(sortedNames)
+
+	for _, name := range sortedNames {
+		r.names[types[name].Schema] = name
+		r.queue = append(r.queue, pendingType{origins[name], types[name].Schema})
+	}
+
+	for len(r.queue) > 0 {
+		pending := r.queue[0]
+		r.queue = r.queue[1:]
+		err := schemas.Walk(pending.item, false, func(pointer []string, item *schemas.Type) error {
+			if item.Ref == nil {
+				return nil
+			}
+			name, err := r.resolve(pending.doc, item, *item.Ref)
+			if err != nil {
+				return schemas.WrapErrorAt(item, err)
+			}
+			r.refs[item] = name
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return r.refs, nil
+}
+
+func (r *refResolver) resolve(doc *schemas.Document, item *schemas.Type, ref string) (string, error) {
+	base := r.resolver.BaseURI(item)
+	if base == "" {
+		base = doc.Base
+	}
+	targetDoc, target, pointer, err := r.resolver.Resolve(base, ref)
+	if err != nil {
+		return "", err
+	}
+	if name, ok := r.names[target]; ok {
+		return name, nil
+	}
+
+	path, err := r.hoistedName(targetDoc, pointer)
+	if err != nil {
+		return "", err
+	}
+	unifiedName := strings.Join(path, "/")
+
+	r.types[unifiedName] = &Type{
+		Name:   unifiedName,
+		Path:   path,
+		Schema: target,
+	}
+	r.names[target] = unifiedName
+	r.queue = append(r.queue, pendingType{targetDoc, target})
+	return unifiedName, nil
+}
+
+// hoistedName names a $ref target which is not a type yet, after its closest
+// ancestor that is one and the pointer from there. Targets outside of any
+// type take the document name as prefix, unless they are definitions.
+func (r *refResolver) hoistedName(doc *schemas.Document, pointer []string) ([]string, error) {
+	base := []string{}
+	rest := pointer
+	for i := len(pointer) - 1; i >= 0; i -= 1 {
+		ancestor, err := doc.Root.Lookup(pointer[:i])
+		if err != nil {
+			// pointer[:i] stops in the middle of a keyword
+			continue
+		}
+		if name, ok := r.names[ancestor]; ok {
+			base = r.types[name].Path
+			rest = pointer[i:]
+			break
+		}
+	}
+
+	path := append(append([]string{}, base...), common.PointerName(rest)...)
+	prefixed := len(base) != 0
+	if !prefixed && (len(rest) == 0 || (rest[0] != "$defs" && rest[0] != "definitions")) {
+		path = append([]string{doc.Name()}, path...)
+		prefixed = true
+	}
+	if _, ok := r.types[strings.Join(path, "/")]; ok && !prefixed {
+		path = append([]string{doc.Name()}, path...)
+	}
+	if _, ok := r.types[strings.Join(path, "/")]; ok {
+		return nil, errors.New(fmt.Sprintf("duplicate name %s", strings.Join(path, "/")))
+	}
+	return path, nil
+}
@@ -0,0 +1,315 @@
+package schema2code
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/azurity/schema2code/common"
+	"github.com/azurity/schema2code/ir"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+// PluginPrefix starts the name of plugin executables, followed by the name of
+// the language they generate.
+const PluginPrefix = "schema2code-gen-"
+
+// PluginVersion is the version of the plugin protocol, sent in every request.
+const PluginVersion = 1
+
+// PluginRequest is written as JSON to the standard input of a plugin.
+type PluginRequest struct {
+	Version int `json:"version"`
+	// Options are the fields of the PluginConfig of the target.
+	Options json.RawMessage `json:"options"`
+	// Types are sorted by name.
+	Types []PluginType `json:"types"`
+}
+
+// PluginType is a named type of the module.
+type PluginType struct {
+	Name string   `json:"name"`
+	Path []string `json:"path"`
+	// Schema is the schema of the type, normalized to draft 2020-12.
+	Schema json.RawMessage `json:"schema"`
+	// Override replaces the type, Node is nil then.
+	Override *TypeOverride `json:"override,omitempty"`
+	// Alias names the type of the same structure this one stands for, Node is
+	// nil then.
+	Alias string `json:"alias,omitempty"`
+	// Inline is set for types named after an inline schema.
+	Inline bool        `json:"inline,omitempty"`
+	Node   *PluginNode `json:"node,omitempty"`
+}
+
+// PluginNode is an ir.Node, with references given by type name.
+type PluginNode struct {
+	// Kind is the schema type name, "ref" or "union".
+	Kind                 string            `json:"kind"`
+	Path                 []string          `json:"path"`
+	Ref                  string            `json:"ref,omitempty"`
+	Enum                 []string          `json:"enum,omitempty"`
+	Format               *string           `json:"format,omitempty"`
+	ReadOnly             bool              `json:"readOnly,omitempty"`
+	Deprecated           bool              `json:"deprecated,omitempty"`
+	Items                *PluginNode       `json:"items,omitempty"`
+	Properties           []PluginProperty  `json:"properties,omitempty"`
+	PatternProperties    []PluginProperty  `json:"patternProperties,omitempty"`
+	AdditionalProperties *PluginNode       `json:"additionalProperties,omitempty"`
+	Closed               bool              `json:"closed,omitempty"`
+	Variants             []*PluginNode     `json:"variants,omitempty"`
+	Discriminator        *ir.Discriminator `json:"discriminator,omitempty"`
+	Exclusive            bool              `json:"exclusive,omitempty"`
+	ir.Constraints
+}
+
+// PluginProperty is a property, or a pattern property named by its pattern.
+type PluginProperty struct {
+	Name     string      `json:"name"`
+	Required bool        `json:"required,omitempty"`
+	Node     *PluginNode `json:"node"`
+}
+
+// PluginResponse is read as JSON from the standard output of a plugin.
+type PluginResponse struct {
+	Files []PluginFile `json:"files"`
+	// Error fails the generation with this message.
+	Error string `json:"error,omitempty"`
+}
+
+type PluginFile struct {
+	Name    string `json:"name"`
+	Content string `json:"content"`
+}
+
+// PluginConfig is the config of plugins. Fields other than those of
+// CommonConfig and Comment are kept in Options for the plugin.
+type PluginConfig struct {
+	CommonConfig
+	// Plugin is the name of the plugin, without PluginPrefix.
+	Plugin string `json:"-"`
+	// Comment starts the lines of the header of generated files, "//" by
+	// default.
+	Comment string `json:"comment,omitempty"`
+	// Options hold every field, by name.
+	Options map[string]json.RawMessage `json:"-"`
+}
+
+func (c *PluginConfig) UnmarshalJSON(data []byte) error {
+	type plain PluginConfig
+	if err := json.Unmarshal(data, (*plain)(c)); err != nil {
+		return err
+	}
+	return json.Unmarshal(data, &c.Options)
+}
+
+func (c *PluginConfig) MarshalJSON() ([]byte, error) {
+	type plain PluginConfig
+	data, err := json.Marshal((*plain)(c))
+	if err != nil {
+		return nil, err
+	}
+	fields := map[string]json.RawMessage{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	// the options repeat the known fields, maybe with another case
+options:
+	for name, value := range c.Options {
+		for known := range fields {
+			if strings.EqualFold(name, known) {
+				continue options
+			}
+		}
+		fields[name] = value
+	}
+	return json.Marshal(fields)
+}
+
+func (c *PluginConfig) BackendName() string {
+	return c.Plugin
+}
+
+func (c *PluginConfig) LineComment() string {
+	if c.Comment == "" {
+		return "//"
+	}
+	return c.Comment
+}
+
+// Plugin is a backend run as an executable, found on PATH by FindPlugin.
+type Plugin struct {
+	// Lang is the name of the executable without PluginPrefix.
+	Lang string
+	Path string
+}
+
+// FindPlugin looks for the executable of the plugin name on PATH. A name
+// holding a path separator is rejected, LookPath would run that file.
+func FindPlugin(name string) (*Plugin, error) {
+	if name == "" || strings.ContainsAny(name, `/\`) || strings.Contains(name, "..") {
+		return nil, errors.New(fmt.Sprintf("invalid plugin name %q", name))
+	}
+	path, err := exec.LookPath(PluginPrefix + name)
+	if err != nil {
+		return nil, err
+	}
+	return &Plugin{Lang: name, Path: path}, nil
+}
+
+// Plugins returns the names of the plugins found on PATH.
+func Plugins() []string {
+	found := map[string]bool{}
+	for _, dir := range filepath.SplitList(os.Getenv("PATH")) {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, entry := range entries {
+			name := entry.Name()
+			if runtime.GOOS == "windows" {
+				name = strings.TrimSuffix(name, filepath.Ext(name))
+			}
+			if !strings.HasPrefix(name, PluginPrefix) || entry.IsDir() || name == PluginPrefix {
+				continue
+			}
+			if info, err := entry.Info(); err != nil || (runtime.GOOS != "windows" && info.Mode()&0111 == 0) {
+				continue
+			}
+			found[strings.TrimPrefix(name, PluginPrefix)] = true
+		}
+	}
+	names := []string{}
+	for name := range found {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (p *Plugin) Name() string {
+	return p.Lang
+}
+
+func (p *Plugin) NewConfig() interface{} {
+	return &PluginConfig{Plugin: p.Lang}
+}
+
+// Generate runs the plugin and writes the files it generated to output.
+func (p *Plugin) Generate(module *ir.Module, config interface{}, output Sink) error {
+	request, err := NewPluginRequest(module, config.(*PluginConfig))
+	if err != nil {
+		return err
+	}
+	response, err := p.Run(request)
+	if err != nil {
+		return err
+	}
+	for _, file := range response.Files {
+		if err := common.WriteFile(output, file.Name, []byte(file.Content)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// NewPluginRequest describes module and config for a plugin.
+func NewPluginRequest(module *ir.Module, config *PluginConfig) (*PluginRequest, error) {
+	options, err := json.Marshal(config)
+	if err != nil {
+		return nil, err
+	}
+	request := &PluginRequest{Version: PluginVersion, Options: options, Types: []PluginType{}}
+	for _, value := range module.Types {
+		schema, err := json.Marshal(value.Schema)
+		if err != nil {
+			return nil, err
+		}
+		pluginType := PluginType{
+			Name:     value.Name,
+			Path:     value.Path,
+			Schema:   schema,
+			Override: value.Override,
+			Inline:   value.Inline,
+			Node:     pluginNode(value.Node),
+		}
+		if value.Alias != nil {
+			pluginType.Alias = value.Alias.Name
+		}
+		request.Types = append(request.Types, pluginType)
+	}
+	return request, nil
+}
+
+func pluginNode(node *ir.Node) *PluginNode {
+	if node == nil {
+		return nil
+	}
+	result := &PluginNode{
+		Kind:                 node.Kind.String(),
+		Path:                 node.Path,
+		Enum:                 node.Enum,
+		Format:               node.Format,
+		ReadOnly:             node.ReadOnly,
+		Deprecated:           node.Deprecated,
+		Items:                pluginNode(node.Items),
+		AdditionalProperties: pluginNode(node.AdditionalProperties),
+		Closed:               node.Closed,
+		Discriminator:        node.Discriminator,
+		Exclusive:            node.Exclusive,
+		Constraints:          node.Constraints,
+	}
+	if node.Ref != nil {
+		result.Ref = node.Ref.Name
+	}
+	for _, property := range node.Properties {
+		result.Properties = append(result.Properties, PluginProperty{
+			Name:     property.Name,
+			Required: property.Required,
+			Node:     pluginNode(property.Node),
+		})
+	}
+	for _, property := range node.PatternProperties {
+		result.PatternProperties = append(result.PatternProperties, PluginProperty{
+			Name: property.Pattern,
+			Node: pluginNode(property.Node),
+		})
+	}
+	for _, variant := range node.Variants {
+		result.Variants = append(result.Variants, pluginNode(variant))
+	}
+	return result
+}
+
+// Run sends request to the plugin and returns the files it generated.
+func (p *Plugin) Run(request *PluginRequest) (*PluginResponse, error) {
+	input, err := json.Marshal(request)
+	if err != nil {
+		return nil, err
+	}
+	stdout := &bytes.Buffer{}
+	stderr := &bytes.Buffer{}
+	cmd := exec.Command(p.Path)
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	if err := cmd.Run(); err != nil {
+		if message := strings.TrimSpace(stderr.String()); message != "" {
+			return nil, errors.New(fmt.Sprintf("plugin %s: %s: %s", p.Lang, err, message))
+		}
+		return nil, errors.New(fmt.Sprintf("plugin %s: %s", p.Lang, err))
+	}
+	response := &PluginResponse{}
+	if err := json.Unmarshal(stdout.Bytes(), response); err != nil {
+		return nil, errors.New(fmt.Sprintf("plugin %s: invalid response: %s", p.Lang, err))
+	}
+	if response.Error != "" {
+		return nil, errors.New(fmt.Sprintf("plugin %s: %s", p.Lang, response.Error))
+	}
+	return response, nil
+}
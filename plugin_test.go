@@ -0,0 +1,108 @@
+package schema2code
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// testPluginEnv makes the test binary run as the plugin echo.
+const testPluginEnv = "SCHEMA2CODE_TEST_PLUGIN"
+
+func TestMain(m *testing.M) {
+	if os.Getenv(testPluginEnv) != "" {
+		runTestPlugin()
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// runTestPlugin answers a request with the names and kinds of the types, or
+// with the error given by the option fail.
+func runTestPlugin() {
+	request := &PluginRequest{}
+	if err := json.NewDecoder(os.Stdin).Decode(request); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	options := struct {
+		Prefix string `json:"prefix"`
+		Fail   string `json:"fail"`
+	}{}
+	if err := json.Unmarshal(request.Options, &options); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	response := &PluginResponse{Error: options.Fail}
+	lines := []string{fmt.Sprintf("version %d", request.Version)}
+	for _, value := range request.Types {
+		line := options.Prefix + value.Name
+		if value.Node != nil {
+			line += " " + value.Node.Kind
+			if value.Node.Ref != "" {
+				line += " " + value.Node.Ref
+			}
+		}
+		lines = append(lines, line)
+	}
+	response.Files = []PluginFile{{Name: "types.txt", Content: strings.Join(lines, "\n") + "\n"}}
+	json.NewEncoder(os.Stdout).Encode(response)
+}
+
+func TestPlugin(t *testing.T) {
+	dir := t.TempDir()
+	executable, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(executable, filepath.Join(dir, PluginPrefix+"echo")); err != nil {
+		t.Skip(err)
+	}
+	t.Setenv("PATH", dir)
+	t.Setenv(testPluginEnv, "1")
+	if names := Plugins(); len(names) != 1 || names[0] != "echo" {
+		t.Errorf("got plugins %v, want echo", names)
+	}
+	for _, name := range []string{"", "x/../echo", `x\..\echo`, "..", "/echo"} {
+		if _, err := FindPlugin(name); err == nil || !strings.Contains(err.Error(), "invalid plugin name") {
+			t.Errorf("%q: got error %v", name, err)
+		}
+	}
+
+	write := func(name string, data string) {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("a.json", `{"$defs": {"item": {"type": "object", "properties": {"tags": {"type": "array", "items": {"type": "string"}}}}, "list": {"type": "array", "items": {"$ref": "#/$defs/item"}}}}`)
+	write("schema2code.json", `{"inputs": ["a.json"], "targets": [
+		{"lang": "echo", "output": "types.txt", "options": {"prefix": "type ", "comment": "--"}},
+		{"name": "failing", "lang": "echo", "output": "failing.txt", "options": {"fail": "cannot generate"}}
+	]}`)
+	project, err := LoadProject(filepath.Join(dir, "schema2code.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := project.generateTarget(&project.Targets[0]); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "types.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := fmt.Sprintf("version %d\ntype item object\ntype list array\n", PluginVersion)
+	if !strings.HasPrefix(string(data), "-- "+GeneratedHeader+"\n") || !strings.HasSuffix(string(data), "\n\n"+want) {
+		t.Errorf("got\n%s\nwant\n%s", data, want)
+	}
+
+	_, err = project.generateTarget(&project.Targets[1])
+	if err == nil || err.Error() != "plugin echo: cannot generate" {
+		t.Errorf("got error %v, want the error of the plugin", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "failing.txt")); err == nil {
+		t.Errorf("the output of a failing plugin is written")
+	}
+}
@@ -0,0 +1,304 @@
+package schema2code
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/azurity/schema2code/common"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ProjectFileNames are the names FindProject looks for, in order.
+var ProjectFileNames = []string{"schema2code.json", "schema2code.yaml", "schema2code.yml"}
+
+// Project lists the schemas of a repository and the targets generated from
+// them, as read from a schema2code.json or schema2code.yaml file.
+type Project struct {
+	// Inputs are the schemas of every target without inputs of its own.
+	Inputs  []ProjectInput  `json:"inputs"`
+	Targets []ProjectTarget `json:"targets"`
+	// Dir is the directory the paths of the project are relative to.
+	Dir string `json:"-"`
+}
+
+// ProjectInput is a schema file. It may be written as the bare file name.
+type ProjectInput struct {
+	File string `json:"file"`
+	// RootType names the type of the root schema, when it describes a value.
+	RootType string `json:"rootType,omitempty"`
+	// Format overrides the format given by the file extension.
+	Format string `json:"format,omitempty"`
+}
+
+func (input *ProjectInput) UnmarshalJSON(data []byte) error {
+	if err := json.Unmarshal(data, &input.File); err == nil {
+		return nil
+	}
+	type plain ProjectInput
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	return decoder.Decode((*plain)(input))
+}
+
+// ProjectTarget is one generated file.
+type ProjectTarget struct {
+	// Name identifies the target in messages, the output path is used when
+	// empty.
+	Name string `json:"name,omitempty"`
+	// Lang names the backend, such as "go" or "ts".
+	Lang string `json:"lang"`
+	// Output is the generated file, or with the split option the directory
+	// or zip archive of the generated files.
+	Output string `json:"output"`
+	// Inputs replace the inputs of the project for this target.
+	Inputs []ProjectInput `json:"inputs,omitempty"`
+	// Options are the fields of the config of the backend, such as
+	// GolangConfig. The Go package defaults to the name of the output
+	// directory.
+	Options json.RawMessage `json:"options,omitempty"`
+	// Overrides replace generated types, by name, with existing ones.
+	Overrides map[string]TypeOverride `json:"overrides,omitempty"`
+}
+
+func (t *ProjectTarget) String() string {
+	if t.Name != "" {
+		return t.Name
+	}
+	return t.Output
+}
+
+// FindProject returns the path of the project file in dir.
+func FindProject(dir string) (string, error) {
+	for _, name := range ProjectFileNames {
+		fileName := filepath.Join(dir, name)
+		if _, err := os.Stat(fileName); err == nil {
+			return fileName, nil
+		}
+	}
+	return "", errors.New(fmt.Sprintf("no %s found in %s", strings.Join(ProjectFileNames, " or "), dir))
+}
+
+// LoadProject reads a project file, in JSON or YAML depending on its
+// extension.
+func LoadProject(fileName string) (*Project, error) {
+	raw, err := readFile(fileName, "")
+	if err != nil {
+		return nil, err
+	}
+	project := &Project{}
+	decoder := json.NewDecoder(bytes.NewReader(raw.JSON))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(project); err != nil {
+		return nil, errors.New(fmt.Sprintf("invalid project %s: %s", fileName, err))
+	}
+	abs, err := filepath.Abs(fileName)
+	if err != nil {
+		return nil, err
+	}
+	project.Dir = filepath.Dir(abs)
+
+	for i := range project.Targets {
+		target := &project.Targets[i]
+		if target.Output == "" {
+			return nil, errors.New(fmt.Sprintf("invalid project %s: target %d has no output", fileName, i))
+		}
+		if len(project.Inputs) == 0 && len(target.Inputs) == 0 {
+			return nil, errors.New(fmt.Sprintf("invalid project %s: target %s has no inputs", fileName, target))
+		}
+		if _, err := project.Config(target); err != nil {
+			return nil, errors.New(fmt.Sprintf("invalid project %s: %s", fileName, err))
+		}
+	}
+	if err := project.checkOutputs(); err != nil {
+		return nil, errors.New(fmt.Sprintf("invalid project %s: %s", fileName, err))
+	}
+	return project, nil
+}
+
+// checkOutputs fails when two targets write the same file, or one writes in
+// the output directory of a split target, which removes the generated files
+// it did not write.
+func (p *Project) checkOutputs() error {
+	dirs := make([]bool, len(p.Targets))
+	for i := range p.Targets {
+		config, err := p.Config(&p.Targets[i])
+		if err != nil {
+			return err
+		}
+		dirs[i] = config.(common.IConfig).Common().Split && !strings.HasSuffix(p.Targets[i].Output, ".zip")
+	}
+	for i := range p.Targets {
+		for j := i + 1; j < len(p.Targets); j += 1 {
+			a, b := p.Path(p.Targets[i].Output), p.Path(p.Targets[j].Output)
+			if a == b || (dirs[i] && isInside(b, a)) || (dirs[j] && isInside(a, b)) {
+				return errors.New(fmt.Sprintf("targets %s and %s share their output, a split target needs a directory of its own", &p.Targets[i], &p.Targets[j]))
+			}
+		}
+	}
+	return nil
+}
+
+// isInside reports whether name is in the directory dir, or dir itself.
+func isInside(name string, dir string) bool {
+	rel, err := filepath.Rel(dir, name)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// Path returns a path of the project as an absolute path.
+func (p *Project) Path(name string) string {
+	if filepath.IsAbs(name) {
+		return name
+	}
+	return filepath.Join(p.Dir, name)
+}
+
+// Config returns the config of the backend of target, such as GolangConfig or
+// TypescriptConfig.
+func (p *Project) Config(target *ProjectTarget) (interface{}, error) {
+	backend, ok := LookupBackend(strings.ToLower(target.Lang))
+	if !ok {
+		return nil, errors.New(fmt.Sprintf("target %s: unknown language %q, expected one of %s", target, target.Lang, strings.Join(Languages(), ", ")))
+	}
+	config := backend.NewConfig()
+	if len(target.Options) != 0 {
+		decoder := json.NewDecoder(bytes.NewReader(target.Options))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(config); err != nil {
+			return nil, errors.New(fmt.Sprintf("target %s: invalid options: %s", target, err))
+		}
+	}
+	if config, ok := config.(*GolangConfig); ok && config.Package == "" {
+		config.Package = DefaultPackage(p.Path(target.Output), config.Split)
+	}
+	if len(target.Overrides) != 0 {
+		casedConfig := config.(common.IConfig).Common()
+		if casedConfig.Overrides == nil {
+			casedConfig.Overrides = map[string]TypeOverride{}
+		}
+		for name, override := range target.Overrides {
+			casedConfig.Overrides[name] = override
+		}
+	}
+	return config, nil
+}
+
+// DefaultPackage returns the Go package of code generated to output, the name
+// of its directory.
+func DefaultPackage(output string, split bool) string {
+	if split {
+		return strings.TrimSuffix(filepath.Base(output), ".zip")
+	}
+	return filepath.Base(filepath.Dir(output))
+}
+
+func (p *Project) targetInputs(target *ProjectTarget) []ProjectInput {
+	if len(target.Inputs) != 0 {
+		return target.Inputs
+	}
+	return p.Inputs
+}
+
+// sources reads the inputs of target, and returns them with its config.
+func (p *Project) sources(target *ProjectTarget) ([]source, interface{}, error) {
+	config, err := p.Config(target)
+	if err != nil {
+		return nil, nil, err
+	}
+	casedConfig := config.(common.IConfig).Common()
+
+	inputs := p.targetInputs(target)
+	sources := []source{}
+	for _, input := range inputs {
+		format := input.Format
+		if format == "" {
+			format = casedConfig.Format
+		}
+		raw, err := readFile(p.Path(input.File), format)
+		if err != nil {
+			return nil, nil, err
+		}
+		rootType := input.RootType
+		if rootType == "" && len(inputs) == 1 {
+			rootType = casedConfig.RootType
+		}
+		sources = append(sources, source{raw, rootType})
+	}
+	return sources, config, nil
+}
+
+// GenerateTarget generates target and writes the code to writer. A split
+// target fails, its files need a Sink.
+func (p *Project) GenerateTarget(target *ProjectTarget, writer io.Writer) error {
+	sources, config, err := p.sources(target)
+	if err != nil {
+		return err
+	}
+	return generate(sources, &writerSink{writer: writer}, config)
+}
+
+// Generate generates every target of the project into its output file. An
+// output file is only written when its target succeeds.
+func (p *Project) Generate() error {
+	for i := range p.Targets {
+		if _, err := p.generateTarget(&p.Targets[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// generateTarget generates target into its output file. It returns the local
+// files the target depends on, as far as they could be loaded.
+func (p *Project) generateTarget(target *ProjectTarget) ([]string, error) {
+	files := []string{}
+	for _, input := range p.targetInputs(target) {
+		files = append(files, p.Path(input.File))
+	}
+	sources, config, err := p.sources(target)
+	if err != nil {
+		return files, err
+	}
+	result, err := build(sources, config)
+	if err != nil {
+		return result.files, err
+	}
+	generated := MemorySink{}
+	if err := render(result.module, result.hash, generated, config); err != nil {
+		return result.files, err
+	}
+	split := config.(common.IConfig).Common().Split
+	return result.files, writeOutput(p.Path(target.Output), split, generated)
+}
+
+// Check compares the output file of every target with the code generated
+// now, and returns those which differ.
+func (p *Project) Check(options *CheckOptions) ([]Drift, error) {
+	drifts := []Drift{}
+	for i := range p.Targets {
+		target := &p.Targets[i]
+		sources, config, err := p.sources(target)
+		if err != nil {
+			return nil, err
+		}
+		targetDrifts, err := check(sources, config, p.Path(target.Output), target.Output, options)
+		if err != nil {
+			return nil, err
+		}
+		drifts = append(drifts, targetDrifts...)
+	}
+	return drifts, nil
+}
+
+// GenerateProject loads the project file fileName and generates all of its
+// targets.
+func GenerateProject(fileName string) error {
+	project, err := LoadProject(fileName)
+	if err != nil {
+		return err
+	}
+	return project.Generate()
+}
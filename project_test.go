@@ -0,0 +1,92 @@
+package schema2code
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadProject(t *testing.T) {
+	cases := []struct {
+		name    string
+		file    string
+		project string
+		err     string
+	}{
+		{
+			name:    "targets of their own",
+			project: `{"inputs": ["schema.json"], "targets": [{"lang": "go", "output": "gen/a", "options": {"split": true}}, {"lang": "ts", "output": "gen/b.ts"}, {"lang": "go", "output": "gen/a.zip", "options": {"split": true}}]}`,
+		},
+		{
+			name:    "bare and detailed inputs",
+			project: `{"inputs": ["a.json", {"file": "b.yaml", "rootType": "B"}], "targets": [{"name": "api", "lang": "ts", "output": "api.ts", "options": {"noHoist": true}, "overrides": {"item": {"type": "Item"}}}]}`,
+		},
+		{
+			name:    "unknown field in yaml",
+			file:    "schema2code.yaml",
+			project: "inputs: [a.yaml]\ntargets:\n  - lang: go\n    output: a.go\n    options:\n      package: api\n      split: false\n    input: b.yaml\n",
+			err:     `unknown field "input"`,
+		},
+		{
+			name:    "unknown project field",
+			project: `{"inputs": ["a.json"], "target": [{"lang": "go", "output": "a.go"}]}`,
+			err:     `unknown field "target"`,
+		},
+		{
+			name:    "unknown input field",
+			project: `{"inputs": [{"file": "a.json", "root": "A"}], "targets": [{"lang": "go", "output": "a.go"}]}`,
+			err:     `unknown field "root"`,
+		},
+		{
+			name:    "unknown target field",
+			project: `{"inputs": ["a.json"], "targets": [{"lang": "go", "out": "a.go"}]}`,
+			err:     `unknown field "out"`,
+		},
+		{
+			name:    "unknown option",
+			project: `{"inputs": ["a.json"], "targets": [{"name": "api", "lang": "go", "output": "a.go", "options": {"packge": "api"}}]}`,
+			err:     `target api: invalid options: json: unknown field "packge"`,
+		},
+		{
+			name:    "unknown language",
+			project: `{"inputs": ["a.json"], "targets": [{"lang": "cobol", "output": "a.cbl"}]}`,
+			err:     `target a.cbl: unknown language "cobol"`,
+		},
+		{
+			name:    "target without inputs",
+			project: `{"targets": [{"lang": "go", "output": "a.go"}]}`,
+			err:     "target a.go has no inputs",
+		},
+		{
+			name:    "same output",
+			project: `{"inputs": ["schema.json"], "targets": [{"lang": "go", "output": "gen/a.go"}, {"name": "other", "lang": "go", "output": "./gen/a.go"}]}`,
+			err:     "targets gen/a.go and other share their output",
+		},
+		{
+			name:    "output in a split directory",
+			project: `{"inputs": ["schema.json"], "targets": [{"lang": "ts", "output": "gen/types/b.ts"}, {"lang": "go", "output": "gen", "options": {"split": true}}]}`,
+			err:     "targets gen/types/b.ts and gen share their output",
+		},
+	}
+	for _, c := range cases {
+		file := c.file
+		if file == "" {
+			file = "schema2code.json"
+		}
+		fileName := filepath.Join(t.TempDir(), file)
+		if err := os.WriteFile(fileName, []byte(c.project), 0644); err != nil {
+			t.Fatal(err)
+		}
+		_, err := LoadProject(fileName)
+		if c.err == "" {
+			if err != nil {
+				t.Errorf("%s: %v", c.name, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), c.err) {
+			t.Errorf("%s: got error %v, want %q", c.name, err, c.err)
+		}
+	}
+}
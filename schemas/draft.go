@@ -0,0 +1,204 @@
+package schemas
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Draft is a version of the JSON Schema specification.
+type Draft int
+
+const (
+	Draft4 Draft = iota + 4
+	Draft6
+	Draft7
+	Draft2019
+	Draft2020
+)
+
+// DefaultDraft is the draft assumed for documents without $schema.
+const DefaultDraft = Draft2020
+
+var draftNames = map[Draft]string{
+	Draft4:    "draft-04",
+	Draft6:    "draft-06",
+	Draft7:    "draft-07",
+	Draft2019: "2019-09",
+	Draft2020: "2020-12",
+}
+
+func (d Draft) String() string {
+	if name, ok := draftNames[d]; ok {
+		return name
+	}
+
+	return fmt.Sprintf("Draft(%d)", int(d))
+}
+
+// DraftFromURI returns the draft of a meta-schema URI as found in $schema.
+func DraftFromURI(uri string) (Draft, bool) {
+	uri = strings.TrimSuffix(strings.TrimSpace(uri), "#")
+	uri = strings.TrimPrefix(strings.TrimPrefix(uri, "http://"), "https://")
+
+	switch uri {
+	case "json-schema.org/draft-04/schema":
+		return Draft4, true
+	case "json-schema.org/draft-06/schema":
+		return Draft6, true
+	case "json-schema.org/draft-07/schema":
+		return Draft7, true
+	case "json-schema.org/draft/2019-09/schema":
+		return Draft2019, true
+	case "json-schema.org/draft/2020-12/schema":
+		return Draft2020, true
+	default:
+		return 0, false
+	}
+}
+
+// ParseDraft reads a draft name such as "draft-07", "7", "2020-12" or a
+// meta-schema URI. An empty name stands for DefaultDraft.
+func ParseDraft(name string) (Draft, error) {
+	if name == "" {
+		return DefaultDraft, nil
+	}
+
+	if draft, ok := DraftFromURI(name); ok {
+		return draft, nil
+	}
+
+	switch strings.TrimPrefix(strings.TrimPrefix(strings.ToLower(name), "draft"), "-") {
+	case "4", "04":
+		return Draft4, nil
+	case "6", "06":
+		return Draft6, nil
+	case "7", "07":
+		return Draft7, nil
+	case "2019-09", "2019":
+		return Draft2019, nil
+	case "2020-12", "2020":
+		return Draft2020, nil
+	default:
+		return 0, fmt.Errorf("unknown draft %q", name)
+	}
+}
+
+// Normalize rewrites item and its subschemas, written against draft unless a
+// $schema says otherwise, into the 2020-12 form the generators expect:
+//
+//   - a boolean exclusiveMinimum or exclusiveMaximum becomes the numeric bound;
+//   - an items array becomes prefixItems, and additionalItems becomes items;
+//   - dependencies is split into dependentRequired and dependentSchemas when
+//     decoding;
+//   - id is read as $id up to draft-04;
+//   - up to draft-07, keywords next to $ref other than annotations and
+//     definitions are ignored.
+func Normalize(item *Type, draft Draft) {
+	if item.Version != nil {
+		if version, ok := DraftFromURI(*item.Version); ok {
+			draft = version
+		}
+	}
+
+	if draft <= Draft4 && item.ID == nil {
+		item.ID = item.legacyID
+	}
+
+	item.legacyID = nil
+
+	if draft <= Draft7 && item.Ref != nil {
+		*item = Type{
+			Version:               item.Version,
+			Ref:                   item.Ref,
+			Title:                 item.Title,
+			Description:           item.Description,
+			Default:               item.Default,
+			Comment:               item.Comment,
+			Examples:              item.Examples,
+			Deprecated:            item.Deprecated,
+			ReadOnly:              item.ReadOnly,
+			WriteOnly:             item.WriteOnly,
+			Definitions:           item.Definitions,
+			GoJSONSchemaExtension: item.GoJSONSchemaExtension,
+			Location:              item.Location,
+		}
+	}
+
+	if item.Minimum != nil || item.ExclusiveMinimum != nil {
+		item.Minimum, item.ExclusiveMinimum = normalizeBound(item.Minimum, item.ExclusiveMinimum)
+	}
+
+	if item.Maximum != nil || item.ExclusiveMaximum != nil {
+		item.Maximum, item.ExclusiveMaximum = normalizeBound(item.Maximum, item.ExclusiveMaximum)
+	}
+
+	if item.tupleItems != nil {
+		if draft < Draft2020 || item.PrefixItems == nil {
+			item.PrefixItems = item.tupleItems
+		}
+
+		item.Items = item.AdditionalItems
+	} else if draft < Draft2020 {
+		item.PrefixItems = nil
+	}
+
+	item.tupleItems = nil
+	item.AdditionalItems = nil
+
+	_ = item.Subschemas(func(_ []string, sub *Type) error {
+		Normalize(sub, draft)
+
+		return nil
+	})
+}
+
+// documentDraft returns the draft a document without $schema is read with:
+// draft, unless the document uses the boolean exclusiveMinimum or
+// exclusiveMaximum of draft-04, which later drafts reject.
+func documentDraft(root *Type, draft Draft) Draft {
+	if root.Version != nil {
+		if _, ok := DraftFromURI(*root.Version); ok {
+			return draft
+		}
+	}
+
+	if draft > Draft4 && booleanBounds(root) {
+		return Draft4
+	}
+
+	return draft
+}
+
+// booleanBounds reports whether item, or a schema nested in it without its own
+// $schema, has a boolean exclusive bound.
+func booleanBounds(item *Type) bool {
+	if (item.ExclusiveMinimum != nil && item.ExclusiveMinimum.Bool != nil) ||
+		(item.ExclusiveMaximum != nil && item.ExclusiveMaximum.Bool != nil) {
+		return true
+	}
+
+	found := false
+	_ = item.Subschemas(func(_ []string, sub *Type) error {
+		if !found && sub.Version == nil {
+			found = booleanBounds(sub)
+		}
+
+		return nil
+	})
+
+	return found
+}
+
+// normalizeBound turns a draft-04 boolean exclusive bound into the numeric
+// form used since draft-06.
+func normalizeBound(inclusive *float64, exclusive *ExclusiveBound) (*float64, *ExclusiveBound) {
+	if exclusive == nil || exclusive.Bool == nil {
+		return inclusive, exclusive
+	}
+
+	if *exclusive.Bool && inclusive != nil {
+		return nil, &ExclusiveBound{Number: inclusive}
+	}
+
+	return inclusive, nil
+}
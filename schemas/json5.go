@@ -0,0 +1,443 @@
+package schemas
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// json5Parser reads JSON5, which covers JSON with comments: comments, trailing
+// commas, unquoted keys, single quoted strings, hexadecimal numbers and
+// multi-line strings.
+type json5Parser struct {
+	src    []rune
+	pos    int
+	line   int
+	column int
+}
+
+func parseJSON5(data string) (*node, error) {
+	p := &json5Parser{
+		src:  []rune(strings.TrimPrefix(data, "\ufeff")),
+		line: 1,
+	}
+
+	if err := p.skipSpace(); err != nil {
+		return nil, err
+	}
+
+	root, err := p.parseValue()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := p.skipSpace(); err != nil {
+		return nil, err
+	}
+
+	if !p.eof() {
+		return nil, p.errorf("unexpected character %q after value", p.peek(0))
+	}
+
+	return root, nil
+}
+
+func (p *json5Parser) errorf(format string, args ...interface{}) error {
+	return &SyntaxError{Line: p.line, Column: p.column + 1, Msg: fmt.Sprintf(format, args...)}
+}
+
+func (p *json5Parser) eof() bool {
+	return p.pos >= len(p.src)
+}
+
+func (p *json5Parser) peek(offset int) rune {
+	if p.pos+offset >= len(p.src) {
+		return 0
+	}
+
+	return p.src[p.pos+offset]
+}
+
+func (p *json5Parser) advance() {
+	if p.eof() {
+		return
+	}
+
+	if p.src[p.pos] == '\n' {
+		p.line += 1
+		p.column = 0
+	} else {
+		p.column += 1
+	}
+
+	p.pos += 1
+}
+
+// skipSpace skips white space and comments.
+func (p *json5Parser) skipSpace() error {
+	for !p.eof() {
+		r := p.peek(0)
+
+		switch {
+		case r == '/' && p.peek(1) == '/':
+			for !p.eof() && p.peek(0) != '\n' {
+				p.advance()
+			}
+		case r == '/' && p.peek(1) == '*':
+			line, column := p.line, p.column+1
+			p.advance()
+			p.advance()
+
+			for !(p.peek(0) == '*' && p.peek(1) == '/') {
+				if p.eof() {
+					return &SyntaxError{Line: line, Column: column, Msg: "unterminated comment"}
+				}
+
+				p.advance()
+			}
+
+			p.advance()
+			p.advance()
+		case unicode.IsSpace(r) || r == '\ufeff':
+			p.advance()
+		default:
+			return nil
+		}
+	}
+
+	return nil
+}
+
+func (p *json5Parser) parseValue() (*node, error) {
+	line, column := p.line, p.column+1
+
+	switch r := p.peek(0); {
+	case r == '{':
+		return p.parseObject()
+	case r == '[':
+		return p.parseArray()
+	case r == '"' || r == '\'':
+		text, err := p.parseString()
+		if err != nil {
+			return nil, err
+		}
+
+		return &node{kind: stringNode, text: text, line: line, column: column}, nil
+	case r == '-' || r == '+' || r == '.' || (r >= '0' && r <= '9'):
+		return p.parseNumber()
+	case isIdentifierStart(r):
+		word := p.parseIdentifier()
+
+		switch word {
+		case "null":
+			return &node{kind: nullNode, text: word, line: line, column: column}, nil
+		case "true", "false":
+			return &node{kind: boolNode, text: word, line: line, column: column}, nil
+		case "Infinity", "NaN":
+			return nil, &SyntaxError{Line: line, Column: column, Msg: fmt.Sprintf("%s cannot be represented in JSON", word)}
+		default:
+			return nil, &SyntaxError{Line: line, Column: column, Msg: fmt.Sprintf("unexpected identifier %q", word)}
+		}
+	case r == 0:
+		return nil, p.errorf("unexpected end of input")
+	default:
+		return nil, p.errorf("unexpected character %q", r)
+	}
+}
+
+func (p *json5Parser) parseObject() (*node, error) {
+	object := newObjectNode(p.line, p.column+1)
+	p.advance()
+
+	for {
+		if err := p.skipSpace(); err != nil {
+			return nil, err
+		}
+
+		if p.peek(0) == '}' {
+			p.advance()
+
+			return object, nil
+		}
+
+		line, column := p.line, p.column+1
+
+		var key string
+
+		switch r := p.peek(0); {
+		case r == '"' || r == '\'':
+			text, err := p.parseString()
+			if err != nil {
+				return nil, err
+			}
+
+			key = text
+		case isIdentifierStart(r):
+			key = p.parseIdentifier()
+		default:
+			return nil, p.errorf("expected a member name")
+		}
+
+		if err := p.skipSpace(); err != nil {
+			return nil, err
+		}
+
+		if p.peek(0) != ':' {
+			return nil, p.errorf("expected ':' after a member name")
+		}
+
+		p.advance()
+
+		if err := p.skipSpace(); err != nil {
+			return nil, err
+		}
+
+		value, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+
+		if _, ok := object.fields[key]; ok {
+			return nil, &SyntaxError{Line: line, Column: column, Msg: fmt.Sprintf("duplicate key %q", key)}
+		}
+
+		_ = object.set(key, value)
+
+		if err := p.skipSpace(); err != nil {
+			return nil, err
+		}
+
+		switch p.peek(0) {
+		case ',':
+			p.advance()
+		case '}':
+		default:
+			return nil, p.errorf("expected ',' or '}' after an object member")
+		}
+	}
+}
+
+func (p *json5Parser) parseArray() (*node, error) {
+	array := &node{kind: arrayNode, line: p.line, column: p.column + 1}
+	p.advance()
+
+	for {
+		if err := p.skipSpace(); err != nil {
+			return nil, err
+		}
+
+		if p.peek(0) == ']' {
+			p.advance()
+
+			return array, nil
+		}
+
+		item, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+
+		array.items = append(array.items, item)
+
+		if err := p.skipSpace(); err != nil {
+			return nil, err
+		}
+
+		switch p.peek(0) {
+		case ',':
+			p.advance()
+		case ']':
+		default:
+			return nil, p.errorf("expected ',' or ']' after an array element")
+		}
+	}
+}
+
+func isIdentifierStart(r rune) bool {
+	return r == '$' || r == '_' || unicode.IsLetter(r)
+}
+
+func isIdentifierPart(r rune) bool {
+	return isIdentifierStart(r) || unicode.IsDigit(r) || r == '\u200c' || r == '\u200d' ||
+		unicode.Is(unicode.Mn, r) || unicode.Is(unicode.Mc, r) || unicode.Is(unicode.Pc, r)
+}
+
+func (p *json5Parser) parseIdentifier() string {
+	start := p.pos
+	for !p.eof() && isIdentifierPart(p.peek(0)) {
+		p.advance()
+	}
+
+	return string(p.src[start:p.pos])
+}
+
+var json5Escapes = map[rune]string{
+	'b':  "\b",
+	'f':  "\f",
+	'n':  "\n",
+	'r':  "\r",
+	't':  "\t",
+	'v':  "\v",
+	'0':  "\x00",
+	'"':  "\"",
+	'\'': "'",
+	'\\': "\\",
+	'/':  "/",
+}
+
+func (p *json5Parser) parseString() (string, error) {
+	line, column := p.line, p.column+1
+	quote := p.peek(0)
+	builder := strings.Builder{}
+	p.advance()
+
+	for {
+		r := p.peek(0)
+
+		switch {
+		case p.eof() || r == '\n':
+			return "", &SyntaxError{Line: line, Column: column, Msg: "unterminated string"}
+		case r == quote:
+			p.advance()
+
+			return builder.String(), nil
+		case r == '\\':
+			escape := p.peek(1)
+
+			switch {
+			case escape == '\n':
+				p.advance()
+				p.advance()
+			case escape == '\r':
+				p.advance()
+				p.advance()
+
+				if p.peek(0) == '\n' {
+					p.advance()
+				}
+			case escape == 'x' || escape == 'u':
+				size := 2
+				if escape == 'u' {
+					size = 4
+				}
+
+				code, err := p.parseHexEscape(size)
+				if err != nil {
+					return "", err
+				}
+
+				if utf16IsHighSurrogate(code) && p.peek(0) == '\\' && p.peek(1) == 'u' {
+					low, err := p.parseHexEscape(4)
+					if err != nil {
+						return "", err
+					}
+
+					code = (code-0xd800)<<10 + (low - 0xdc00) + 0x10000
+				}
+
+				if !utf8.ValidRune(code) {
+					code = utf8.RuneError
+				}
+
+				builder.WriteRune(code)
+			default:
+				if text, ok := json5Escapes[escape]; ok {
+					builder.WriteString(text)
+				} else if escape >= '1' && escape <= '9' {
+					return "", p.errorf("invalid escape sequence")
+				} else {
+					builder.WriteRune(escape)
+				}
+
+				p.advance()
+				p.advance()
+			}
+		default:
+			builder.WriteRune(r)
+			p.advance()
+		}
+	}
+}
+
+func utf16IsHighSurrogate(r rune) bool {
+	return r >= 0xd800 && r < 0xdc00
+}
+
+func (p *json5Parser) parseHexEscape(size int) (rune, error) {
+	if p.pos+2+size > len(p.src) {
+		return 0, p.errorf("invalid escape sequence")
+	}
+
+	code, err := strconv.ParseUint(string(p.src[p.pos+2:p.pos+2+size]), 16, 32)
+	if err != nil {
+		return 0, p.errorf("invalid escape sequence")
+	}
+
+	for i := 0; i < 2+size; i += 1 {
+		p.advance()
+	}
+
+	return rune(code), nil
+}
+
+func (p *json5Parser) parseNumber() (*node, error) {
+	line, column := p.line, p.column+1
+	start := p.pos
+
+	sign := ""
+	if p.peek(0) == '-' || p.peek(0) == '+' {
+		if p.peek(0) == '-' {
+			sign = "-"
+		}
+
+		p.advance()
+	}
+
+	if isIdentifierStart(p.peek(0)) {
+		word := p.parseIdentifier()
+		if word == "Infinity" || word == "NaN" {
+			return nil, &SyntaxError{Line: line, Column: column, Msg: fmt.Sprintf("%s cannot be represented in JSON", word)}
+		}
+
+		return nil, &SyntaxError{Line: line, Column: column, Msg: fmt.Sprintf("invalid number %q", string(p.src[start:p.pos]))}
+	}
+
+	for !p.eof() && (isIdentifierPart(p.peek(0)) || p.peek(0) == '.' ||
+		((p.peek(0) == '+' || p.peek(0) == '-') && (p.src[p.pos-1] == 'e' || p.src[p.pos-1] == 'E'))) {
+		p.advance()
+	}
+
+	text := string(p.src[start:p.pos])
+	digits := strings.TrimLeft(text, "+-")
+	n := &node{kind: numberNode, line: line, column: column}
+
+	if strings.HasPrefix(digits, "0x") || strings.HasPrefix(digits, "0X") {
+		value, err := strconv.ParseUint(digits[2:], 16, 64)
+		if err != nil {
+			return nil, &SyntaxError{Line: line, Column: column, Msg: fmt.Sprintf("invalid number %q", text)}
+		}
+
+		n.text = sign + strconv.FormatUint(value, 10)
+
+		return n, nil
+	}
+
+	value, err := strconv.ParseFloat(digits, 64)
+	if err != nil || math.IsInf(value, 0) || strings.ContainsAny(digits, "_xXoObB") {
+		return nil, &SyntaxError{Line: line, Column: column, Msg: fmt.Sprintf("invalid number %q", text)}
+	}
+
+	if strings.ContainsAny(digits, ".eE") {
+		n.text = sign + strconv.FormatFloat(value, 'g', -1, 64)
+	} else {
+		n.text = sign + strings.TrimLeft(digits, "0")
+		if n.text == sign {
+			n.text = sign + "0"
+		}
+	}
+
+	return n, nil
+}
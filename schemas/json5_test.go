@@ -0,0 +1,70 @@
+package schemas
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestParseJSON5(t *testing.T) {
+	cases := []struct {
+		name  string
+		json5 string
+		json  string
+	}{
+		{name: "plain JSON", json5: `{"type": "object", "required": ["a"], "x": null}`, json: `{"type":"object","required":["a"],"x":null}`},
+		{name: "comments", json5: "// heading\n{\n  /* block\n  comment */ \"type\": \"string\", // trailing\n}", json: `{"type":"string"}`},
+		{name: "unquoted keys", json5: `{type: 'integer', $ref: "#/a", _x1: true}`, json: `{"type":"integer","$ref":"#/a","_x1":true}`},
+		{name: "trailing commas", json5: `{a: [1, 2,], b: {c: 1,},}`, json: `{"a":[1,2],"b":{"c":1}}`},
+		{name: "single quotes", json5: `['it\'s', "say \"hi\"", 'double " inside']`, json: `["it's","say \"hi\"","double \" inside"]`},
+		{name: "escapes", json5: `["\té\x41", "line\
+continued"]`, json: `["\téA","linecontinued"]`},
+		{name: "numbers", json5: `[0x1F, -0x10, +1, .5, 5., 1e3, -0, 007]`, json: `[31,-16,1,0.5,5,1000,-0,7]`},
+		{name: "key order kept", json5: `{z: 1, a: 2, m: 3}`, json: `{"z":1,"a":2,"m":3}`},
+	}
+	for _, c := range cases {
+		root, err := parseJSON5(c.json5)
+		if err != nil {
+			t.Errorf("%s: %v", c.name, err)
+			continue
+		}
+		data, err := nodeToJSON(root)
+		if err != nil {
+			t.Errorf("%s: %v", c.name, err)
+			continue
+		}
+		if string(data) != c.json {
+			t.Errorf("%s: got %s, want %s", c.name, data, c.json)
+		}
+	}
+}
+
+func TestParseJSON5Errors(t *testing.T) {
+	cases := []struct {
+		name   string
+		json5  string
+		line   int
+		column int
+		msg    string
+	}{
+		{name: "duplicate key", json5: "{a: 1,\n a: 2}", line: 2, column: 2, msg: "duplicate key"},
+		{name: "unterminated comment", json5: "{/* open", line: 1, column: 2, msg: "unterminated comment"},
+		{name: "infinity", json5: "[1, Infinity]", line: 1, column: 5, msg: "cannot be represented in JSON"},
+		{name: "negative NaN", json5: "-NaN", line: 1, column: 1, msg: "cannot be represented in JSON"},
+		{name: "unknown identifier", json5: "{a: yes}", line: 1, column: 5, msg: "unexpected identifier"},
+		{name: "trailing content", json5: "{} {}", line: 1, column: 4, msg: "after value"},
+		{name: "missing colon", json5: "{a 1}", line: 1, msg: ":"},
+		{name: "end of input", json5: "[1,", line: 1, msg: "end of input"},
+	}
+	for _, c := range cases {
+		_, err := parseJSON5(c.json5)
+		var syntaxErr *SyntaxError
+		if !errors.As(err, &syntaxErr) {
+			t.Errorf("%s: got %v, want a syntax error", c.name, err)
+			continue
+		}
+		if syntaxErr.Line != c.line || (c.column != 0 && syntaxErr.Column != c.column) || !strings.Contains(syntaxErr.Msg, c.msg) {
+			t.Errorf("%s: got %v, want line %d column %d and %q", c.name, err, c.line, c.column, c.msg)
+		}
+	}
+}
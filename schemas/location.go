@@ -0,0 +1,177 @@
+package schemas
+
+import (
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Location is the place of a schema in its source document. Line and Column
+// are 1-based and zero when unknown.
+type Location struct {
+	// File is the URL of the document.
+	File   string
+	Line   int
+	Column int
+	// Pointer is the JSON pointer to the schema, as written in the document.
+	Pointer string
+}
+
+// IsZero reports whether nothing is known about the location.
+func (l Location) IsZero() bool {
+	return l == Location{}
+}
+
+// String formats the location as "file:line:column: #pointer", leaving out the
+// unknown parts. The pointer to the root is left out when the line is known.
+func (l Location) String() string {
+	if l.IsZero() {
+		return ""
+	}
+
+	parts := []string{}
+
+	file := displayFile(l.File)
+	if l.Line > 0 {
+		if file != "" {
+			file += ":"
+		}
+
+		file += strconv.Itoa(l.Line) + ":" + strconv.Itoa(l.Column)
+	}
+
+	if file != "" {
+		parts = append(parts, file)
+	}
+
+	if l.Pointer != "" || l.Line == 0 {
+		parts = append(parts, "#"+l.Pointer)
+	}
+
+	return strings.Join(parts, ": ")
+}
+
+// displayFile shows file URLs as paths. Documents read from a stream have a
+// directory as URL, which is not shown.
+func displayFile(file string) string {
+	if u, err := url.Parse(file); err == nil && u.Scheme == "file" {
+		file = filepath.FromSlash(u.Path)
+	}
+
+	if strings.HasSuffix(file, "/") || strings.HasSuffix(file, string(filepath.Separator)) {
+		return ""
+	}
+
+	return file
+}
+
+// Error is an error about a schema, reported at its location.
+type Error struct {
+	Location Location
+	Msg      string
+	// Err is the underlying error, if any.
+	Err error
+}
+
+func (e *Error) Error() string {
+	msg := e.Msg
+	if msg == "" && e.Err != nil {
+		msg = e.Err.Error()
+	}
+
+	if e.Location.IsZero() {
+		return msg
+	}
+
+	return e.Location.String() + ": " + msg
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// ErrorAt returns an error reported at the location of item.
+func ErrorAt(item *Type, msg string) error {
+	return &Error{Location: item.Location, Msg: msg}
+}
+
+// WrapErrorAt returns err reported at the location of item, unless it already
+// has a location.
+func WrapErrorAt(item *Type, err error) error {
+	if _, ok := err.(*Error); ok {
+		return err
+	}
+
+	if _, ok := err.(*ValidationError); ok {
+		return err
+	}
+
+	return &Error{Location: item.Location, Err: err}
+}
+
+type position struct {
+	line   int
+	column int
+}
+
+// positions maps the JSON pointer of every value of a node tree to its
+// position.
+func positions(root *node) map[string]position {
+	result := map[string]position{}
+
+	var walk func(n *node, pointer []string)
+	walk = func(n *node, pointer []string) {
+		result[FormatPointer(pointer)] = position{line: n.line, column: n.column}
+
+		switch n.kind {
+		case objectNode:
+			for _, key := range n.keys {
+				walk(n.fields[key], append(append([]string{}, pointer...), key))
+			}
+		case arrayNode:
+			for i, item := range n.items {
+				walk(item, append(append([]string{}, pointer...), strconv.Itoa(i)))
+			}
+		}
+	}
+
+	walk(root, []string{})
+
+	return result
+}
+
+// sourceKeywords lists the keywords a subschema keyword may have been written
+// as, before the legacy forms are decoded.
+var sourceKeywords = map[string][]string{
+	"$defs":            {"$defs", "definitions"},
+	"dependentSchemas": {"dependentSchemas", "dependencies"},
+}
+
+// locate sets the location of item and its subschemas, which must not have
+// been normalized yet so their structure still follows the document.
+func locate(item *Type, file string, pointer []string, source map[string]position) {
+	item.Location = Location{File: file, Pointer: FormatPointer(pointer)}
+	if pos, ok := source[item.Location.Pointer]; ok {
+		item.Location.Line, item.Location.Column = pos.line, pos.column
+	}
+
+	for i, sub := range item.tupleItems {
+		locate(sub, file, append(append([]string{}, pointer...), "items", strconv.Itoa(i)), source)
+	}
+
+	_ = item.Subschemas(func(segments []string, sub *Type) error {
+		written := append([]string{}, segments...)
+
+		for _, keyword := range sourceKeywords[segments[0]] {
+			written[0] = keyword
+			if _, ok := source[FormatPointer(append(append([]string{}, pointer...), written...))]; ok {
+				break
+			}
+		}
+
+		locate(sub, file, append(append([]string{}, pointer...), written...), source)
+
+		return nil
+	})
+}
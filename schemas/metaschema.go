@@ -0,0 +1,153 @@
+package schemas
+
+import (
+	"embed"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+)
+
+//go:embed metaschemas
+var metaschemaFiles embed.FS
+
+// metaschemaPaths maps the URIs of the official meta-schemas, scheme removed,
+// to their embedded copy.
+var metaschemaPaths = map[string]string{
+	"json-schema.org/draft-04/schema":                      "metaschemas/draft-04.json",
+	"json-schema.org/draft-06/schema":                      "metaschemas/draft-06.json",
+	"json-schema.org/draft-07/schema":                      "metaschemas/draft-07.json",
+	"json-schema.org/draft/2019-09/schema":                 "metaschemas/2019-09/schema.json",
+	"json-schema.org/draft/2019-09/meta/core":              "metaschemas/2019-09/meta/core.json",
+	"json-schema.org/draft/2019-09/meta/applicator":        "metaschemas/2019-09/meta/applicator.json",
+	"json-schema.org/draft/2019-09/meta/validation":        "metaschemas/2019-09/meta/validation.json",
+	"json-schema.org/draft/2019-09/meta/meta-data":         "metaschemas/2019-09/meta/meta-data.json",
+	"json-schema.org/draft/2019-09/meta/format":            "metaschemas/2019-09/meta/format.json",
+	"json-schema.org/draft/2019-09/meta/content":           "metaschemas/2019-09/meta/content.json",
+	"json-schema.org/draft/2020-12/schema":                 "metaschemas/2020-12/schema.json",
+	"json-schema.org/draft/2020-12/meta/core":              "metaschemas/2020-12/meta/core.json",
+	"json-schema.org/draft/2020-12/meta/applicator":        "metaschemas/2020-12/meta/applicator.json",
+	"json-schema.org/draft/2020-12/meta/unevaluated":       "metaschemas/2020-12/meta/unevaluated.json",
+	"json-schema.org/draft/2020-12/meta/validation":        "metaschemas/2020-12/meta/validation.json",
+	"json-schema.org/draft/2020-12/meta/meta-data":         "metaschemas/2020-12/meta/meta-data.json",
+	"json-schema.org/draft/2020-12/meta/format-annotation": "metaschemas/2020-12/meta/format-annotation.json",
+	"json-schema.org/draft/2020-12/meta/content":           "metaschemas/2020-12/meta/content.json",
+}
+
+var metaschemaURIs = map[Draft]string{
+	Draft4:    "http://json-schema.org/draft-04/schema",
+	Draft6:    "http://json-schema.org/draft-06/schema",
+	Draft7:    "http://json-schema.org/draft-07/schema",
+	Draft2019: "https://json-schema.org/draft/2019-09/schema",
+	Draft2020: "https://json-schema.org/draft/2020-12/schema",
+}
+
+// openMetaschema opens the embedded copy of an official meta-schema, so they
+// are available without network access.
+func openMetaschema(uri string) (io.ReadCloser, bool) {
+	uri, _ = splitFragment(uri)
+	uri = strings.TrimPrefix(strings.TrimPrefix(uri, "http://"), "https://")
+
+	name, ok := metaschemaPaths[uri]
+	if !ok {
+		return nil, false
+	}
+
+	f, err := metaschemaFiles.Open(name)
+	if err != nil {
+		return nil, false
+	}
+
+	return f, true
+}
+
+// metaschema is the meta-schema of a draft, ready for validation.
+type metaschema struct {
+	resolver *Resolver
+	root     *Type
+	// keywords lists the keywords defined by the meta-schema and its
+	// vocabularies.
+	keywords map[string]bool
+}
+
+var (
+	metaschemasOnce sync.Once
+	metaschemas     map[Draft]*metaschema
+	metaschemasErr  error
+)
+
+// metaschemaFor returns the meta-schema of draft. All of them share one
+// resolver, loading the embedded files only.
+func metaschemaFor(draft Draft) (*metaschema, error) {
+	metaschemasOnce.Do(func() {
+		resolver := NewResolver(&Loader{embeddedOnly: true})
+		metaschemas = map[Draft]*metaschema{}
+
+		for version, uri := range metaschemaURIs {
+			doc, err := resolver.Document(uri)
+			if err != nil {
+				metaschemasErr = fmt.Errorf("failed to load meta-schema %s: %w", uri, err)
+
+				return
+			}
+
+			meta := &metaschema{
+				resolver: resolver,
+				root:     doc.Root,
+				keywords: map[string]bool{},
+			}
+			if err := meta.collectKeywords(doc.Root, map[*Type]bool{}); err != nil {
+				metaschemasErr = err
+
+				return
+			}
+
+			// draft-04 takes $ref from JSON Reference, outside of its meta-schema
+			meta.keywords["$ref"] = true
+
+			metaschemas[version] = meta
+		}
+	})
+
+	if metaschemasErr != nil {
+		return nil, metaschemasErr
+	}
+
+	meta, ok := metaschemas[draft]
+	if !ok {
+		return nil, fmt.Errorf("no meta-schema for %s", draft)
+	}
+
+	return meta, nil
+}
+
+// collectKeywords gathers the properties of item and of the schemas it pulls
+// in through allOf and $ref, which is how vocabularies are combined.
+func (m *metaschema) collectKeywords(item *Type, seen map[*Type]bool) error {
+	if seen[item] {
+		return nil
+	}
+
+	seen[item] = true
+
+	for keyword := range item.Properties {
+		m.keywords[keyword] = true
+	}
+
+	for _, sub := range item.AllOf {
+		if sub.Ref == nil {
+			continue
+		}
+
+		_, target, _, err := m.resolver.Resolve(m.resolver.BaseURI(sub), *sub.Ref)
+		if err != nil {
+			return err
+		}
+
+		if err := m.collectKeywords(target, seen); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -29,6 +29,7 @@ package schemas
 import (
 	"encoding/json"
 	"fmt"
+	"reflect"
 )
 
 // Schema is the root schema.
@@ -41,38 +42,55 @@ type Schema struct {
 
 // UnmarshalJSON implements json.Unmarshaler for Schema struct.
 func (s *Schema) UnmarshalJSON(data []byte) error {
-	var unmarshSchema unmarshalerSchema
-	if err := json.Unmarshal(data, &unmarshSchema); err != nil {
+	// The root is decoded as a Type so legacy fields are handled the same way
+	// at every level.
+	var root Type
+	if err := json.Unmarshal(data, &root); err != nil {
 		return fmt.Errorf("failed to unmarshal schema: %w", err)
 	}
 
-	// Fall back to id if $id is not present.
-	if unmarshSchema.ID == "" {
-		unmarshSchema.ID = unmarshSchema.LegacyID
+	*s = Schema{
+		ObjectAsType: (*ObjectAsType)(&root),
+		Definitions:  root.Definitions,
 	}
 
-	// Take care of legacy fields.
-	var legacySchema struct {
-		Definitions Definitions `json:"definitions,omitempty"`
+	if root.ID != nil {
+		s.ID = *root.ID
 	}
 
-	if err := json.Unmarshal(data, &legacySchema); err != nil {
-		return fmt.Errorf("failed to unmarshal schema: %w", err)
+	if root.legacyID != nil {
+		s.LegacyID = *root.legacyID
 	}
 
-	if unmarshSchema.Definitions == nil && legacySchema.Definitions != nil {
-		unmarshSchema.Definitions = legacySchema.Definitions
+	return nil
+}
+
+// HasRootType reports whether the root of the schema describes a type, rather
+// than only holding definitions, identifiers and annotations.
+func (s *Schema) HasRootType() bool {
+	if s.ObjectAsType == nil {
+		return false
 	}
 
-	*s = Schema(unmarshSchema)
+	root := Type(*s.ObjectAsType)
+	root.Location = Location{}
+	root.Version = nil
+	root.ID = nil
+	root.legacyID = nil
+	root.Vocabulary = nil
+	root.Anchor = nil
+	root.DynamicAnchor = nil
+	root.RecursiveAnchor = false
+	root.Definitions = nil
+	root.Comment = nil
+	root.Title = nil
+	root.Description = nil
+	root.Examples = nil
 
-	return nil
+	return !reflect.DeepEqual(root, Type{})
 }
 
-type (
-	unmarshalerSchema Schema
-	ObjectAsType      Type
-)
+type ObjectAsType Type
 
 // TypeList is a list of type names.
 type TypeList []string
@@ -114,12 +132,21 @@ type Type struct {
 	// RFC draft-wright-json-schema-00.
 	Version *string `json:"$schema,omitempty"` // Section 6.1.
 	Ref     *string `json:"$ref,omitempty"`    // Section 7.
+	// RFC draft-bhutton-json-schema-01, section 8.2.
+	ID            *string         `json:"$id,omitempty"`            // Section 8.2.1.
+	Vocabulary    map[string]bool `json:"$vocabulary,omitempty"`    // Section 8.1.2.
+	Anchor        *string         `json:"$anchor,omitempty"`        // Section 8.2.2.
+	DynamicAnchor *string         `json:"$dynamicAnchor,omitempty"` // Section 8.2.2.
+	DynamicRef    *string         `json:"$dynamicRef,omitempty"`    // Section 8.2.3.2.
+	// RFC draft-handrews-json-schema-02, section 8.2.4.
+	RecursiveRef    *string `json:"$recursiveRef,omitempty"`    // Section 8.2.4.2.
+	RecursiveAnchor bool    `json:"$recursiveAnchor,omitempty"` // Section 8.2.4.3.
 	// RFC draft-wright-json-schema-validation-00, section 5.
-	MultipleOf           *int             `json:"multipleOf,omitempty"`           // Section 5.1.
+	MultipleOf           *float64         `json:"multipleOf,omitempty"`           // Section 5.1.
 	Maximum              *float64         `json:"maximum,omitempty"`              // Section 5.2.
-	ExclusiveMaximum     *bool            `json:"exclusiveMaximum,omitempty"`     // Section 5.3.
+	ExclusiveMaximum     *ExclusiveBound  `json:"exclusiveMaximum,omitempty"`     // Section 5.3.
 	Minimum              *float64         `json:"minimum,omitempty"`              // Section 5.4.
-	ExclusiveMinimum     *bool            `json:"exclusiveMinimum,omitempty"`     // Section 5.5.
+	ExclusiveMinimum     *ExclusiveBound  `json:"exclusiveMinimum,omitempty"`     // Section 5.5.
 	MaxLength            *int             `json:"maxLength,omitempty"`            // Section 5.6.
 	MinLength            *int             `json:"minLength,omitempty"`            // Section 5.7.
 	Pattern              *string          `json:"pattern,omitempty"`              // Section 5.8.
@@ -153,10 +180,45 @@ type Type struct {
 	// RFC draft-handrews-json-schema-validation-02, appendix A.
 	Definitions      Definitions      `json:"$defs,omitempty"`
 	DependentSchemas map[string]*Type `json:"dependentSchemas,omitempty"`
+	// RFC draft-bhutton-json-schema-01, sections 8 to 11.
+	Comment               *string `json:"$comment,omitempty"`              // Section 8.3.
+	If                    *Type   `json:"if,omitempty"`                    // Section 10.2.2.1.
+	Then                  *Type   `json:"then,omitempty"`                  // Section 10.2.2.2.
+	Else                  *Type   `json:"else,omitempty"`                  // Section 10.2.2.3.
+	PrefixItems           []*Type `json:"prefixItems,omitempty"`           // Section 10.3.1.1.
+	Contains              *Type   `json:"contains,omitempty"`              // Section 10.3.1.3.
+	PropertyNames         *Type   `json:"propertyNames,omitempty"`         // Section 10.3.2.4.
+	UnevaluatedItems      *Type   `json:"unevaluatedItems,omitempty"`      // Section 11.2.
+	UnevaluatedProperties *Type   `json:"unevaluatedProperties,omitempty"` // Section 11.3.
+	// RFC draft-bhutton-json-schema-validation-01.
+	// Const is nil when absent, and points to nil for `"const": null`.
+	Const            *interface{}  `json:"const,omitempty"`            // Section 6.1.3.
+	MaxContains      *int          `json:"maxContains,omitempty"`      // Section 6.4.4.
+	MinContains      *int          `json:"minContains,omitempty"`      // Section 6.4.5.
+	ContentEncoding  *string       `json:"contentEncoding,omitempty"`  // Section 8.3.
+	ContentMediaType *string       `json:"contentMediaType,omitempty"` // Section 8.4.
+	ContentSchema    *Type         `json:"contentSchema,omitempty"`    // Section 8.5.
+	Deprecated       bool          `json:"deprecated,omitempty"`       // Section 9.3.
+	ReadOnly         bool          `json:"readOnly,omitempty"`         // Section 9.4.
+	WriteOnly        bool          `json:"writeOnly,omitempty"`        // Section 9.4.
+	Examples         []interface{} `json:"examples,omitempty"`         // Section 9.5.
+
+	// Location is where the schema was read from.
+	Location Location `json:"-"`
+
+	// legacyID and tupleItems hold the draft-04 id and an items array until
+	// Normalize knows the draft they were written against.
+	legacyID   *string
+	tupleItems []*Type
 
 	// ExtGoCustomType is the name of a (qualified or not) custom Go type
 	// to use for the field.
 	GoJSONSchemaExtension *GoJSONSchemaExtension `json:"goJSONSchema,omitempty"` //nolint:tagliatelle // breaking change
+
+	// Discriminator is the OpenAPI discriminator of a oneOf, XDiscriminator
+	// the same as an extension keyword.
+	Discriminator  *Discriminator `json:"discriminator,omitempty"`
+	XDiscriminator *Discriminator `json:"x-discriminator,omitempty"` //nolint:tagliatelle // extension keyword
 }
 
 // UnmarshalJSON accepts booleans as schemas where `true` is equivalent to `{}`
@@ -173,6 +235,34 @@ func (value *Type) UnmarshalJSON(raw []byte) error {
 		return nil
 	}
 
+	// Up to draft 2019-09, items holds an array for tuples, which are described
+	// by prefixItems since draft 2020-12.
+	tuple := struct {
+		Items json.RawMessage `json:"items,omitempty"`
+	}{}
+	if err := json.Unmarshal(raw, &tuple); err != nil {
+		return fmt.Errorf("failed to unmarshal type: %w", err)
+	}
+
+	var tupleItems []*Type
+	if len(tuple.Items) > 0 && tuple.Items[0] == '[' {
+		if err := json.Unmarshal(tuple.Items, &tupleItems); err != nil {
+			return fmt.Errorf("failed to unmarshal type: %w", err)
+		}
+
+		var fields map[string]json.RawMessage
+		if err := json.Unmarshal(raw, &fields); err != nil {
+			return fmt.Errorf("failed to unmarshal type: %w", err)
+		}
+
+		delete(fields, "items")
+
+		var err error
+		if raw, err = json.Marshal(fields); err != nil {
+			return fmt.Errorf("failed to unmarshal type: %w", err)
+		}
+	}
+
 	var obj ObjectAsType
 	if err := json.Unmarshal(raw, &obj); err != nil {
 		return fmt.Errorf("failed to unmarshal type: %w", err)
@@ -181,8 +271,12 @@ func (value *Type) UnmarshalJSON(raw []byte) error {
 	// Take care of legacy fields from older RFC versions.
 	legacyObj := struct {
 		// RFC draft-wright-json-schema-validation-00, section 5.
-		Dependencies map[string]*Type `json:"dependencies,omitempty"`
-		Definitions  Definitions      `json:"definitions,omitempty"` // Section 5.26.
+		Dependencies map[string]json.RawMessage `json:"dependencies,omitempty"`
+		Definitions  Definitions                `json:"definitions,omitempty"` // Section 5.26.
+		// RFC draft-zyp-json-schema-04, section 7.2.
+		ID interface{} `json:"id,omitempty"`
+		// Distinguishes `"const": null` from a missing const.
+		Const json.RawMessage `json:"const,omitempty"`
 	}{}
 	if err := json.Unmarshal(raw, &legacyObj); err != nil {
 		return fmt.Errorf("failed to unmarshal type: %w", err)
@@ -192,8 +286,47 @@ func (value *Type) UnmarshalJSON(raw []byte) error {
 		obj.Definitions = legacyObj.Definitions
 	}
 
-	if legacyObj.Dependencies != nil && obj.DependentSchemas == nil {
-		obj.DependentSchemas = legacyObj.Dependencies
+	obj.tupleItems = tupleItems
+
+	// dependencies is split into dependentRequired and dependentSchemas.
+	for name, dependency := range legacyObj.Dependencies {
+		if len(dependency) > 0 && dependency[0] == '[' {
+			var required []string
+			if err := json.Unmarshal(dependency, &required); err != nil {
+				return fmt.Errorf("failed to unmarshal type: %w", err)
+			}
+
+			if obj.DependentRequired == nil {
+				obj.DependentRequired = map[string][]string{}
+			}
+
+			if _, ok := obj.DependentRequired[name]; !ok {
+				obj.DependentRequired[name] = required
+			}
+
+			continue
+		}
+
+		var schema Type
+		if err := json.Unmarshal(dependency, &schema); err != nil {
+			return fmt.Errorf("failed to unmarshal type: %w", err)
+		}
+
+		if obj.DependentSchemas == nil {
+			obj.DependentSchemas = map[string]*Type{}
+		}
+
+		if _, ok := obj.DependentSchemas[name]; !ok {
+			obj.DependentSchemas[name] = &schema
+		}
+	}
+
+	if id, ok := legacyObj.ID.(string); ok {
+		obj.legacyID = &id
+	}
+
+	if legacyObj.Const != nil && obj.Const == nil {
+		obj.Const = new(interface{})
 	}
 
 	*value = Type(obj)
@@ -201,8 +334,152 @@ func (value *Type) UnmarshalJSON(raw []byte) error {
 	return nil
 }
 
+// ExclusiveBound is the value of exclusiveMinimum and exclusiveMaximum: a
+// boolean modifier of minimum and maximum up to draft-04, and a number since
+// draft-06.
+type ExclusiveBound struct {
+	Bool   *bool
+	Number *float64
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (b *ExclusiveBound) UnmarshalJSON(raw []byte) error {
+	var flag bool
+	if err := json.Unmarshal(raw, &flag); err == nil {
+		*b = ExclusiveBound{Bool: &flag}
+
+		return nil
+	}
+
+	var number float64
+	if err := json.Unmarshal(raw, &number); err != nil {
+		return fmt.Errorf("failed to unmarshal exclusive bound: %w", err)
+	}
+
+	*b = ExclusiveBound{Number: &number}
+
+	return nil
+}
+
+// MarshalJSON implements json.Marshaler.
+func (b ExclusiveBound) MarshalJSON() ([]byte, error) {
+	if b.Number != nil {
+		return json.Marshal(*b.Number)
+	}
+
+	return json.Marshal(b.Bool != nil && *b.Bool)
+}
+
+// LowerBound returns the effective lower bound of a numeric schema, combining
+// minimum with both forms of exclusiveMinimum.
+func (value *Type) LowerBound() (float64, bool, bool) {
+	return effectiveBound(value.Minimum, value.ExclusiveMinimum, func(a, b float64) bool { return a > b })
+}
+
+// UpperBound returns the effective upper bound of a numeric schema, combining
+// maximum with both forms of exclusiveMaximum.
+func (value *Type) UpperBound() (float64, bool, bool) {
+	return effectiveBound(value.Maximum, value.ExclusiveMaximum, func(a, b float64) bool { return a < b })
+}
+
+// effectiveBound returns the bound, whether it is exclusive and whether there
+// is a bound at all. stricter reports if a is a stricter bound than b.
+func effectiveBound(inclusive *float64, exclusive *ExclusiveBound, stricter func(a, b float64) bool) (float64, bool, bool) {
+	if exclusive != nil && exclusive.Number != nil {
+		if inclusive != nil && stricter(*inclusive, *exclusive.Number) {
+			return *inclusive, false, true
+		}
+
+		return *exclusive.Number, true, true
+	}
+
+	if inclusive == nil {
+		return 0, false, false
+	}
+
+	return *inclusive, exclusive != nil && exclusive.Bool != nil && *exclusive.Bool, true
+}
+
 type GoJSONSchemaExtension struct {
 	Type       *string  `json:"type,omitempty"`
 	Identifier *string  `json:"identifier,omitempty"`
 	Imports    []string `json:"imports,omitempty"`
 }
+
+// Discriminator names the property telling the variants of a oneOf apart.
+type Discriminator struct {
+	PropertyName string `json:"propertyName"`
+	// Mapping maps values of the property to the $ref of a variant, or to the
+	// name of its definition.
+	Mapping map[string]string `json:"mapping,omitempty"`
+}
+
+// UnmarshalJSON also accepts the property name alone.
+func (d *Discriminator) UnmarshalJSON(raw []byte) error {
+	var name string
+	if err := json.Unmarshal(raw, &name); err == nil {
+		*d = Discriminator{PropertyName: name}
+
+		return nil
+	}
+
+	type plain Discriminator
+	if err := json.Unmarshal(raw, (*plain)(d)); err != nil {
+		return fmt.Errorf("failed to unmarshal discriminator: %w", err)
+	}
+
+	return nil
+}
+
+// EffectiveDiscriminator returns the discriminator of value, given by either
+// keyword.
+func (value *Type) EffectiveDiscriminator() *Discriminator {
+	if value.Discriminator != nil {
+		return value.Discriminator
+	}
+
+	return value.XDiscriminator
+}
+
+// EffectiveType returns the type of value, inferred from its const or enum
+// when it has none: the type of their values when they all have the same, a
+// number when some of them only are integers.
+func (value *Type) EffectiveType() TypeList {
+	if len(value.Type) != 0 {
+		return value.Type
+	}
+	values := value.Enum
+	if value.Const != nil {
+		values = []interface{}{*value.Const}
+	}
+	inferred := ""
+	for _, item := range values {
+		name := ValueType(item)
+		switch {
+		case inferred == "" || inferred == name:
+			inferred = name
+		case (inferred == TypeNameInteger || inferred == TypeNameNumber) && (name == TypeNameInteger || name == TypeNameNumber):
+			inferred = TypeNameNumber
+		default:
+			return nil
+		}
+	}
+	if inferred == "" {
+		return nil
+	}
+	return TypeList{inferred}
+}
+
+// IsFalse reports whether value is the `false` schema, accepting nothing.
+func (value *Type) IsFalse() bool {
+	return isFalseSchema(value)
+}
+
+// IsTrue reports whether value accepts any value of any type, as the `true`
+// schema or one of annotations only does.
+func (value *Type) IsTrue() bool {
+	return len(value.Type) == 0 && value.Ref == nil && value.Enum == nil && value.Const == nil &&
+		value.AllOf == nil && value.AnyOf == nil && value.OneOf == nil && value.Not == nil &&
+		value.Properties == nil && value.PatternProperties == nil && value.AdditionalProperties == nil &&
+		value.Items == nil && value.PrefixItems == nil
+}
@@ -0,0 +1,147 @@
+package schemas
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// SyntaxError reports a malformed schema document. Line and Column are 1-based.
+type SyntaxError struct {
+	Line   int
+	Column int
+	Msg    string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("line %d, column %d: %s", e.Line, e.Column, e.Msg)
+}
+
+type nodeKind int
+
+const (
+	nullNode nodeKind = iota
+	boolNode
+	numberNode
+	stringNode
+	arrayNode
+	objectNode
+)
+
+// node is a JSON value read by one of the tolerant readers, together with its
+// position in the source document.
+type node struct {
+	kind nodeKind
+	// text holds the value of scalars as written, except for numbers which are
+	// kept in JSON syntax.
+	text   string
+	keys   []string
+	fields map[string]*node
+	items  []*node
+	line   int
+	column int
+}
+
+func newObjectNode(line, column int) *node {
+	return &node{
+		kind:   objectNode,
+		fields: map[string]*node{},
+		line:   line,
+		column: column,
+	}
+}
+
+// set adds a member to an object node, rejecting duplicate keys.
+func (n *node) set(key string, value *node) error {
+	if _, ok := n.fields[key]; ok {
+		return &SyntaxError{Line: value.line, Column: value.column, Msg: fmt.Sprintf("duplicate key %q", key)}
+	}
+
+	n.keys = append(n.keys, key)
+	n.fields[key] = value
+
+	return nil
+}
+
+// encode writes the node as JSON.
+func (n *node) encode(buf *bytes.Buffer) error {
+	switch n.kind {
+	case nullNode:
+		buf.WriteString("null")
+	case boolNode:
+		buf.WriteString(strings.ToLower(n.text))
+	case numberNode:
+		buf.WriteString(n.text)
+	case stringNode:
+		data, err := json.Marshal(n.text)
+		if err != nil {
+			return err
+		}
+
+		buf.Write(data)
+	case arrayNode:
+		buf.WriteByte('[')
+
+		for i, item := range n.items {
+			if i != 0 {
+				buf.WriteByte(',')
+			}
+
+			if err := item.encode(buf); err != nil {
+				return err
+			}
+		}
+
+		buf.WriteByte(']')
+	case objectNode:
+		buf.WriteByte('{')
+
+		for i, key := range n.keys {
+			if i != 0 {
+				buf.WriteByte(',')
+			}
+
+			data, err := json.Marshal(key)
+			if err != nil {
+				return err
+			}
+
+			buf.Write(data)
+			buf.WriteByte(':')
+
+			if err := n.fields[key].encode(buf); err != nil {
+				return err
+			}
+		}
+
+		buf.WriteByte('}')
+	}
+
+	return nil
+}
+
+// nodeToJSON encodes a node tree as a JSON document.
+func nodeToJSON(root *node) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	if err := root.encode(buf); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// schemaFromNode decodes a node tree into a Schema.
+func schemaFromNode(root *node) (*Schema, error) {
+	data, err := nodeToJSON(root)
+	if err != nil {
+		return nil, err
+	}
+
+	var schema Schema
+	if err := json.Unmarshal(data, &schema); err != nil {
+		return nil, err
+	}
+
+	return &schema, nil
+}
@@ -1,20 +1,179 @@
 package schemas
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
 	"os"
+	"path"
 	"path/filepath"
-	//"github.com/atombender/go-jsonschema/pkg/yamlutils"
-	//"github.com/goccy/go-yaml"
+	"strings"
+	"unicode/utf8"
 )
 
 var errInvalidSchemaRef = fmt.Errorf("schema reference must a file name or HTTP URL")
 
+// Formats of schema documents.
+const (
+	FormatJSON  = "json"
+	FormatJSON5 = "json5"
+	FormatYAML  = "yaml"
+)
+
+// FormatFromFileName guesses the format of a schema document from its file
+// extension, defaulting to JSON.
+func FormatFromFileName(fileName string) string {
+	switch strings.ToLower(path.Ext(fileName)) {
+	case ".yaml", ".yml":
+		return FormatYAML
+	case ".json5", ".jsonc":
+		return FormatJSON5
+	default:
+		return FormatJSON
+	}
+}
+
+// FromFile reads a schema document, choosing the format from the file extension.
+func FromFile(fileName string) (*Schema, error) {
+	f, err := os.Open(fileName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file: %w", err)
+	}
+
+	defer func() {
+		_ = f.Close()
+	}()
+
+	return FromReader(f, FormatFromFileName(fileName))
+}
+
+// FromReader reads a schema document in the given format.
+func FromReader(r io.Reader, format string) (*Schema, error) {
+	switch format {
+	case FormatJSON, "":
+		return FromJSONReader(r)
+	case FormatJSON5, "jsonc":
+		return FromJSON5Reader(r)
+	case FormatYAML:
+		return FromYAMLReader(r)
+	default:
+		return nil, fmt.Errorf("unknown schema format %q", format)
+	}
+}
+
+// RawDocument is a schema document read as JSON, before it is decoded into a
+// Schema. It remembers the position of every value in the source.
+type RawDocument struct {
+	// URL is the location the document was retrieved from.
+	URL  string
+	JSON []byte
+
+	positions map[string]position
+}
+
+// ReadDocument reads a schema document in the given format. docURL is only
+// used to locate errors.
+func ReadDocument(docURL string, r io.Reader, format string) (*RawDocument, error) {
+	parse := parseJSON5
+	name := "JSON5"
+
+	switch format {
+	case FormatJSON, "":
+		name = "JSON"
+	case FormatJSON5, "jsonc":
+	case FormatYAML:
+		parse, name = parseYAML, "YAML"
+	default:
+		return nil, fmt.Errorf("unknown schema format %q", format)
+	}
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", name, err)
+	}
+
+	if name == "JSON" {
+		var value interface{}
+		if err := json.Unmarshal(data, &value); err != nil {
+			location := Location{File: docURL}
+
+			var syntaxErr *json.SyntaxError
+			if errors.As(err, &syntaxErr) {
+				location.Line, location.Column = offsetPosition(data, syntaxErr.Offset)
+			}
+
+			return nil, &Error{Location: location, Msg: fmt.Sprintf("failed to unmarshal JSON: %s", err), Err: err}
+		}
+	}
+
+	// JSON is read by the JSON5 reader as well, for the positions.
+	root, err := parse(string(data))
+	if err != nil {
+		var syntaxErr *SyntaxError
+		if errors.As(err, &syntaxErr) {
+			return nil, &Error{
+				Location: Location{File: docURL, Line: syntaxErr.Line, Column: syntaxErr.Column},
+				Msg:      fmt.Sprintf("failed to parse %s: %s", name, syntaxErr.Msg),
+				Err:      err,
+			}
+		}
+
+		return nil, fmt.Errorf("failed to parse %s: %w", name, err)
+	}
+
+	raw := &RawDocument{
+		URL:       docURL,
+		JSON:      data,
+		positions: positions(root),
+	}
+
+	if name != "JSON" {
+		if raw.JSON, err = nodeToJSON(root); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal %s: %w", name, err)
+		}
+	}
+
+	return raw, nil
+}
+
+// offsetPosition converts a byte offset in data to a line and a column.
+func offsetPosition(data []byte, offset int64) (int, int) {
+	if offset > int64(len(data)) {
+		offset = int64(len(data))
+	}
+
+	before := data[:offset]
+	line := bytes.Count(before, []byte("\n")) + 1
+	column := utf8.RuneCount(before[bytes.LastIndexByte(before, '\n')+1:])
+
+	return line, column
+}
+
+// Location returns the location of the value at the JSON pointer.
+func (d *RawDocument) Location(pointer string) Location {
+	location := Location{File: d.URL, Pointer: pointer}
+	if pos, ok := d.positions[pointer]; ok {
+		location.Line, location.Column = pos.line, pos.column
+	}
+
+	return location
+}
+
+// FromJSONBytes decodes a JSON schema document.
+func FromJSONBytes(data []byte) (*Schema, error) {
+	var schema Schema
+	if err := json.Unmarshal(data, &schema); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal JSON: %w", err)
+	}
+
+	return &schema, nil
+}
+
 func FromJSONFile(fileName string) (*Schema, error) {
 	f, err := os.Open(fileName)
 	if err != nil {
@@ -37,64 +196,125 @@ func FromJSONReader(r io.Reader) (*Schema, error) {
 	return &schema, nil
 }
 
-//func FromYAMLFile(fileName string) (*Schema, error) {
-//	f, err := os.Open(fileName)
-//	if err != nil {
-//		return nil, fmt.Errorf("failed to open file: %w", err)
-//	}
-//
-//	defer func() {
-//		_ = f.Close()
-//	}()
-//
-//	return FromYAMLReader(f)
-//}
-
-//func FromYAMLReader(r io.Reader) (*Schema, error) {
-//	// Marshal to JSON first because YAML decoder doesn't understand JSON tags.
-//	var m map[string]interface{}
-//
-//	if err := yaml.NewDecoder(r).Decode(&m); err != nil {
-//		return nil, fmt.Errorf("failed to unmarshal YAML: %w", err)
-//	}
-//
-//	yamlutils.FixMapKeys(m)
-//
-//	b, err := json.Marshal(m)
-//	if err != nil {
-//		return nil, fmt.Errorf("failed to marshal JSON: %w", err)
-//	}
-//
-//	var schema Schema
-//
-//	if err = json.Unmarshal(b, &schema); err != nil {
-//		return nil, fmt.Errorf("failed to unmarshal JSON: %w", err)
-//	}
-//
-//	return &schema, nil
-//}
+func FromJSON5File(fileName string) (*Schema, error) {
+	f, err := os.Open(fileName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file: %w", err)
+	}
+
+	defer func() {
+		_ = f.Close()
+	}()
+
+	return FromJSON5Reader(f)
+}
+
+// FromJSON5Reader reads a schema written in JSON5, or in JSON with comments
+// and trailing commas.
+func FromJSON5Reader(r io.Reader) (*Schema, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read JSON5: %w", err)
+	}
+
+	root, err := parseJSON5(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse JSON5: %w", err)
+	}
+
+	schema, err := schemaFromNode(root)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal JSON5: %w", err)
+	}
+
+	return schema, nil
+}
+
+func FromYAMLFile(fileName string) (*Schema, error) {
+	f, err := os.Open(fileName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file: %w", err)
+	}
+
+	defer func() {
+		_ = f.Close()
+	}()
+
+	return FromYAMLReader(f)
+}
+
+func FromYAMLReader(r io.Reader) (*Schema, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read YAML: %w", err)
+	}
+
+	root, err := parseYAML(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse YAML: %w", err)
+	}
+
+	// Marshal to JSON first so the schema is decoded through its JSON tags.
+	schema, err := schemaFromNode(root)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal YAML: %w", err)
+	}
+
+	return schema, nil
+}
 
 type Loader struct {
 	workingDir string
+	// embeddedOnly restricts the loader to the embedded meta-schemas.
+	embeddedOnly bool
+}
+
+// NewLoader creates a Loader resolving relative file names against workingDir.
+func NewLoader(workingDir string) *Loader {
+	return &Loader{workingDir: workingDir}
 }
 
 func (l *Loader) Load(fromURL string) (io.ReadCloser, error) {
+	if rc, ok := openMetaschema(fromURL); ok {
+		return rc, nil
+	}
+
+	if l.embeddedOnly {
+		return nil, fmt.Errorf("%w: %q", errInvalidSchemaRef, fromURL)
+	}
+
 	u, err := url.Parse(fromURL)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse url: %w", err)
 	}
 
 	if u.Scheme == "http" || u.Scheme == "https" {
-		resp, err := http.NewRequestWithContext(context.Background(), http.MethodGet, fromURL, nil)
+		req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, fromURL, nil)
 		if err != nil {
 			return nil, fmt.Errorf("failed to create request: %w", err)
 		}
 
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch schema: %w", err)
+		}
+
+		if resp.StatusCode != http.StatusOK {
+			_ = resp.Body.Close()
+
+			return nil, fmt.Errorf("failed to fetch schema: %s: %s", fromURL, resp.Status)
+		}
+
 		return resp.Body, nil
 	}
 
 	if (u.Scheme == "" || u.Scheme == "file") && u.Host == "" && u.Path != "" {
-		rc, err := os.Open(filepath.Join(l.workingDir, u.Path))
+		fileName := filepath.FromSlash(u.Path)
+		if !filepath.IsAbs(fileName) {
+			fileName = filepath.Join(l.workingDir, fileName)
+		}
+
+		rc, err := os.Open(fileName)
 		if err != nil {
 			return nil, fmt.Errorf("failed to open file: %w", err)
 		}
@@ -0,0 +1,140 @@
+package schemas
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+var (
+	errPointerNotFound = errors.New("json pointer target not found")
+	errStopLookup      = errors.New("stop lookup")
+)
+
+// legacyKeywords maps keywords of older drafts to the field they are parsed into.
+var legacyKeywords = map[string]string{
+	"definitions":  "$defs",
+	"dependencies": "dependentSchemas",
+}
+
+// canonicalKeyword returns the keyword the first token of pointer is parsed into
+// within t, following the legacy forms of Normalize.
+func canonicalKeyword(t *Type, pointer []string) string {
+	token := pointer[0]
+	if alias, ok := legacyKeywords[token]; ok {
+		return alias
+	}
+
+	switch {
+	case token == "items" && len(pointer) > 1 && isIndex(pointer[1]) && t.PrefixItems != nil:
+		return "prefixItems"
+	case token == "additionalItems" && t.PrefixItems != nil:
+		return "items"
+	}
+
+	return token
+}
+
+func isIndex(token string) bool {
+	if token == "" {
+		return false
+	}
+
+	for _, r := range token {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+
+	return true
+}
+
+// ParsePointer splits a JSON pointer (RFC 6901), as found in a URI fragment,
+// into its unescaped reference tokens.
+func ParsePointer(fragment string) ([]string, error) {
+	unescaped, err := url.PathUnescape(fragment)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unescape json pointer: %w", err)
+	}
+
+	if unescaped == "" {
+		return []string{}, nil
+	}
+
+	if !strings.HasPrefix(unescaped, "/") {
+		return nil, fmt.Errorf("json pointer must start with '/': %q", fragment)
+	}
+
+	segments := strings.Split(unescaped[1:], "/")
+	for i, segment := range segments {
+		segments[i] = strings.ReplaceAll(strings.ReplaceAll(segment, "~1", "/"), "~0", "~")
+	}
+
+	return segments, nil
+}
+
+// FormatPointer joins reference tokens into a JSON pointer.
+func FormatPointer(segments []string) string {
+	builder := strings.Builder{}
+	for _, segment := range segments {
+		builder.WriteString("/")
+		builder.WriteString(strings.ReplaceAll(strings.ReplaceAll(segment, "~", "~0"), "/", "~1"))
+	}
+
+	return builder.String()
+}
+
+// PointerURI formats reference tokens as a URI fragment, "#" included.
+func PointerURI(segments []string) string {
+	builder := strings.Builder{}
+	builder.WriteString("#")
+	for _, segment := range segments {
+		builder.WriteString("/")
+		builder.WriteString(url.PathEscape(strings.ReplaceAll(strings.ReplaceAll(segment, "~", "~0"), "/", "~1")))
+	}
+
+	return builder.String()
+}
+
+// Lookup returns the subschema of t addressed by the reference tokens pointer.
+func (t *Type) Lookup(pointer []string) (*Type, error) {
+	current := t
+
+	for len(pointer) > 0 {
+		var next *Type
+
+		var consumed int
+
+		_ = current.Subschemas(func(segments []string, sub *Type) error {
+			if len(segments) > len(pointer) {
+				return nil
+			}
+
+			for i, segment := range segments {
+				token := pointer[i]
+				if i == 0 {
+					token = canonicalKeyword(current, pointer)
+				}
+
+				if token != segment {
+					return nil
+				}
+			}
+
+			next = sub
+			consumed = len(segments)
+
+			return errStopLookup
+		})
+
+		if next == nil {
+			return nil, fmt.Errorf("%w: %s", errPointerNotFound, FormatPointer(pointer))
+		}
+
+		current = next
+		pointer = pointer[consumed:]
+	}
+
+	return current, nil
+}
@@ -0,0 +1,423 @@
+package schemas
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Document is a schema document known to a Resolver.
+type Document struct {
+	// URL is the location the document was retrieved from.
+	URL string
+	// Base is the base URI of the document, its $id if present and URL otherwise.
+	Base   string
+	Schema *Schema
+	// Root is the document viewed as a single schema, definitions included.
+	Root *Type
+	// Source is the JSON text the document was decoded from, when it was
+	// loaded from a RawDocument.
+	Source []byte
+}
+
+// Name returns the file name of the document without its extension.
+func (d *Document) Name() string {
+	u, err := url.Parse(d.Base)
+	if err != nil || u.Path == "" {
+		return ""
+	}
+
+	name := path.Base(u.Path)
+
+	return strings.TrimSuffix(name, path.Ext(name))
+}
+
+// resource is a schema identified by an absolute URI, either through $id,
+// $anchor or by being the root of a document.
+type resource struct {
+	doc     *Document
+	item    *Type
+	pointer []string
+}
+
+// Resolver loads the documents referenced through $ref and caches them by URL,
+// so every document is read only once. It indexes the resources embedded in
+// every document, following the base URI rules of JSON Schema 2020-12.
+type Resolver struct {
+	loader    *Loader
+	draft     Draft
+	validate  *ValidateOptions
+	documents map[string]*Document
+	resources map[string]*resource
+	bases     map[*Type]string
+}
+
+func NewResolver(loader *Loader) *Resolver {
+	return &Resolver{
+		loader:    loader,
+		draft:     DefaultDraft,
+		documents: map[string]*Document{},
+		resources: map[string]*resource{},
+		bases:     map[*Type]string{},
+	}
+}
+
+// SetDraft sets the draft assumed for documents without $schema.
+func (r *Resolver) SetDraft(draft Draft) {
+	r.draft = draft
+}
+
+// SetValidation makes the resolver validate every document against the
+// meta-schema of its draft before using it. A nil options disables validation.
+func (r *Resolver) SetValidation(options *ValidateOptions) {
+	r.validate = options
+}
+
+// BaseURI returns the base URI in effect for item, which is the URI of the
+// closest enclosing resource.
+func (r *Resolver) BaseURI(item *Type) string {
+	return r.bases[item]
+}
+
+// FileURL converts a file name to an absolute file URL.
+func FileURL(fileName string) (string, error) {
+	abs, err := filepath.Abs(fileName)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve path: %w", err)
+	}
+
+	abs = filepath.ToSlash(abs)
+	if !strings.HasPrefix(abs, "/") {
+		abs = "/" + abs
+	}
+
+	return (&url.URL{Scheme: "file", Path: abs}).String(), nil
+}
+
+// FilePath returns the file name of a file URL, such as made by FileURL.
+func FilePath(fileURL string) (string, bool) {
+	u, err := url.Parse(fileURL)
+	if err != nil || u.Scheme != "file" || u.Path == "" || strings.HasSuffix(u.Path, "/") {
+		return "", false
+	}
+
+	return filepath.FromSlash(u.Path), true
+}
+
+// ResolveURI resolves ref against base.
+func ResolveURI(base, ref string) (string, error) {
+	baseURL, err := url.Parse(base)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse base uri: %w", err)
+	}
+
+	refURL, err := url.Parse(ref)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse $ref: %w", err)
+	}
+
+	return baseURL.ResolveReference(refURL).String(), nil
+}
+
+func splitFragment(uri string) (string, string) {
+	if index := strings.IndexByte(uri, '#'); index >= 0 {
+		return uri[:index], uri[index+1:]
+	}
+
+	return uri, ""
+}
+
+// LoadDocument validates, when enabled, and registers a schema document read
+// by ReadDocument. Every schema of the document is given its location.
+func (r *Resolver) LoadDocument(raw *RawDocument) (*Document, error) {
+	if r.validate != nil {
+		violations, err := Validate(raw, r.draft, r.validate)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(violations) > 0 {
+			docURL, _ := splitFragment(raw.URL)
+
+			return nil, &ValidationError{URL: docURL, Violations: violations}
+		}
+	}
+
+	schema, err := FromJSONBytes(raw.JSON)
+	if err != nil {
+		return nil, &Error{Location: Location{File: raw.URL}, Err: err}
+	}
+
+	if schema.ObjectAsType != nil {
+		locate((*Type)(schema.ObjectAsType), raw.URL, []string{}, raw.positions)
+	}
+
+	doc, err := r.AddDocument(raw.URL, schema)
+	if err != nil {
+		return nil, err
+	}
+
+	doc.Source = raw.JSON
+
+	return doc, nil
+}
+
+// AddDocument registers an already parsed schema retrieved from docURL. The
+// schema is normalized in place, see Normalize.
+func (r *Resolver) AddDocument(docURL string, schema *Schema) (*Document, error) {
+	docURL, _ = splitFragment(docURL)
+
+	root := (*Type)(schema.ObjectAsType)
+	if root == nil {
+		root = &Type{}
+		schema.ObjectAsType = (*ObjectAsType)(root)
+	}
+
+	root.Definitions = schema.Definitions
+	Normalize(root, documentDraft(root, r.draft))
+	schema.Definitions = root.Definitions
+
+	schema.ID = ""
+	if root.ID != nil {
+		schema.ID = *root.ID
+	}
+
+	doc := &Document{
+		URL:    docURL,
+		Base:   docURL,
+		Schema: schema,
+		Root:   root,
+	}
+
+	if schema.ID != "" {
+		base, err := ResolveURI(docURL, schema.ID)
+		if err != nil {
+			return nil, err
+		}
+
+		doc.Base, _ = splitFragment(base)
+		r.documents[doc.Base] = doc
+	}
+
+	r.documents[docURL] = doc
+
+	return doc, r.index(doc, root, doc.Base, []string{})
+}
+
+// index registers item and its subschemas as resources. base is the base URI
+// of the enclosing resource.
+func (r *Resolver) index(doc *Document, item *Type, base string, pointer []string) error {
+	isResource := len(pointer) == 0
+
+	if item.ID != nil {
+		uri, err := ResolveURI(base, *item.ID)
+		if err != nil {
+			return err
+		}
+
+		resourceURI, fragment := splitFragment(uri)
+		if fragment != "" && resourceURI == base {
+			// older drafts use a fragment only $id as an anchor
+			if err := r.addResource(uri, doc, item, pointer); err != nil {
+				return err
+			}
+		} else {
+			base = resourceURI
+			isResource = true
+		}
+	}
+
+	if isResource {
+		if err := r.addResource(base, doc, item, pointer); err != nil {
+			return err
+		}
+
+		if len(pointer) == 0 && base != doc.URL {
+			r.resources[doc.URL] = r.resources[base]
+		}
+	}
+
+	// a dynamic anchor is also a plain anchor
+	for _, anchor := range []*string{item.Anchor, item.DynamicAnchor} {
+		if anchor == nil {
+			continue
+		}
+
+		uri, err := ResolveURI(base, "#"+*anchor)
+		if err != nil {
+			return err
+		}
+
+		if err := r.addResource(uri, doc, item, pointer); err != nil {
+			return err
+		}
+	}
+
+	r.bases[item] = base
+
+	return item.Subschemas(func(segments []string, sub *Type) error {
+		return r.index(doc, sub, base, append(append([]string{}, pointer...), segments...))
+	})
+}
+
+func (r *Resolver) addResource(uri string, doc *Document, item *Type, pointer []string) error {
+	if existing, ok := r.resources[uri]; ok && existing.item != item {
+		return fmt.Errorf("duplicate schema resource %s", uri)
+	}
+
+	r.resources[uri] = &resource{
+		doc:     doc,
+		item:    item,
+		pointer: pointer,
+	}
+
+	return nil
+}
+
+// Document returns the document at docURL, loading it on first use.
+func (r *Resolver) Document(docURL string) (*Document, error) {
+	docURL, _ = splitFragment(docURL)
+	if doc, ok := r.documents[docURL]; ok {
+		return doc, nil
+	}
+
+	rc, err := r.loader.Load(docURL)
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		_ = rc.Close()
+	}()
+
+	raw, err := ReadDocument(docURL, rc, FormatFromFileName(docURL))
+	if err != nil {
+		return nil, err
+	}
+
+	return r.LoadDocument(raw)
+}
+
+// Documents returns every document known to the resolver.
+func (r *Resolver) Documents() []*Document {
+	seen := map[*Document]bool{}
+	docs := []*Document{}
+
+	for _, doc := range r.documents {
+		if !seen[doc] {
+			seen[doc] = true
+			docs = append(docs, doc)
+		}
+	}
+
+	sort.Slice(docs, func(i, j int) bool {
+		return docs[i].URL < docs[j].URL
+	})
+
+	return docs
+}
+
+// LoadReferenced loads the documents referenced through $ref by the known
+// documents, recursively, so Documents returns every document they depend on.
+// It returns the URLs of the documents which could not be loaded, whose $ref
+// fail when they are resolved.
+func (r *Resolver) LoadReferenced() []string {
+	failed := map[string]bool{}
+	done := map[*Document]bool{}
+
+	for {
+		pending := []*Document{}
+
+		for _, doc := range r.Documents() {
+			if !done[doc] {
+				done[doc] = true
+				pending = append(pending, doc)
+			}
+		}
+
+		if len(pending) == 0 {
+			break
+		}
+
+		for _, doc := range pending {
+			r.loadReferences(doc.Root, failed)
+		}
+	}
+
+	urls := []string{}
+
+	for docURL := range failed {
+		urls = append(urls, docURL)
+	}
+
+	sort.Strings(urls)
+
+	return urls
+}
+
+func (r *Resolver) loadReferences(item *Type, failed map[string]bool) {
+	if item.Ref != nil {
+		if uri, err := ResolveURI(r.bases[item], *item.Ref); err == nil {
+			docURL, _ := splitFragment(uri)
+			if _, ok := r.resources[docURL]; !ok && !failed[docURL] {
+				if _, err := r.Document(docURL); err != nil {
+					failed[docURL] = true
+				}
+			}
+		}
+	}
+
+	_ = item.Subschemas(func(_ []string, sub *Type) error {
+		r.loadReferences(sub, failed)
+
+		return nil
+	})
+}
+
+// Resolve follows ref found in a schema whose base URI is base. It returns the
+// document containing the target, the target itself and the JSON pointer
+// segments leading from the document root to the target.
+func (r *Resolver) Resolve(base, ref string) (*Document, *Type, []string, error) {
+	uri, err := ResolveURI(base, ref)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	if res, ok := r.resources[uri]; ok {
+		return res.doc, res.item, res.pointer, nil
+	}
+
+	resourceURI, fragment := splitFragment(uri)
+
+	res, ok := r.resources[resourceURI]
+	if !ok {
+		if _, err := r.Document(resourceURI); err != nil {
+			return nil, nil, nil, err
+		}
+
+		if res, ok = r.resources[uri]; ok {
+			return res.doc, res.item, res.pointer, nil
+		}
+
+		res = r.resources[resourceURI]
+	}
+
+	if fragment != "" && !strings.HasPrefix(fragment, "/") {
+		return nil, nil, nil, fmt.Errorf("anchor not found for $ref %q", ref)
+	}
+
+	pointer, err := ParsePointer(fragment)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("unsupported $ref %q: %w", ref, err)
+	}
+
+	target, err := res.item.Lookup(pointer)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to resolve $ref %q: %w", ref, err)
+	}
+
+	return res.doc, target, append(append([]string{}, res.pointer...), pointer...), nil
+}
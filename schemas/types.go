@@ -1,5 +1,10 @@
 package schemas
 
+import (
+	"encoding/json"
+	"math"
+)
+
 const (
 	TypeNameString  = "string"
 	TypeNameArray   = "array"
@@ -19,3 +24,32 @@ func IsPrimitiveType(t string) bool {
 		return false
 	}
 }
+
+// ValueType returns the name of the type of a decoded JSON value, "integer"
+// for a whole number.
+func ValueType(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return TypeNameNull
+	case bool:
+		return TypeNameBoolean
+	case string:
+		return TypeNameString
+	case float64:
+		if v == math.Trunc(v) && !math.IsInf(v, 0) {
+			return TypeNameInteger
+		}
+		return TypeNameNumber
+	case int, int64:
+		return TypeNameInteger
+	case json.Number:
+		if _, err := v.Int64(); err == nil {
+			return TypeNameInteger
+		}
+		return TypeNameNumber
+	case []interface{}:
+		return TypeNameArray
+	default:
+		return TypeNameObject
+	}
+}
@@ -0,0 +1,628 @@
+package schemas
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// Violation is a place where a schema document breaks the meta-schema of its
+// draft.
+type Violation struct {
+	// Location is the place of the offending value in the document.
+	Location Location
+	Message  string
+
+	keyword  string
+	expected []string
+}
+
+// ValidationError lists every violation found in a schema document.
+type ValidationError struct {
+	URL        string
+	Violations []Violation
+}
+
+func (e *ValidationError) Error() string {
+	builder := strings.Builder{}
+	if file := displayFile(e.URL); file != "" {
+		builder.WriteString(fmt.Sprintf("invalid schema %s:", file))
+	} else {
+		builder.WriteString("invalid schema:")
+	}
+
+	for _, violation := range e.Violations {
+		builder.WriteString(fmt.Sprintf("\n  %s: %s", violation.Location, violation.Message))
+	}
+
+	return builder.String()
+}
+
+// ValidateOptions tunes the validation of schema documents.
+type ValidateOptions struct {
+	// DisallowUnknownKeywords reports keywords the draft does not define.
+	DisallowUnknownKeywords bool
+}
+
+// extensionKeywords are the keywords understood by the generators on top of
+// the specification.
+var extensionKeywords = map[string]bool{
+	"goJSONSchema":    true,
+	"discriminator":   true,
+	"x-discriminator": true,
+}
+
+// Validate checks a JSON schema document against the meta-schema of the draft
+// named by its $schema, or of draft when it has none or an unknown one. Without
+// $schema, a document using the boolean exclusive bounds of draft-04 is
+// checked against draft-04.
+func Validate(raw *RawDocument, draft Draft, options *ValidateOptions) ([]Violation, error) {
+	var instance interface{}
+	if err := json.Unmarshal(raw.JSON, &instance); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal JSON: %w", err)
+	}
+
+	if object, ok := instance.(map[string]interface{}); ok {
+		if uri, ok := object["$schema"].(string); ok {
+			if version, ok := DraftFromURI(uri); ok {
+				draft = version
+			}
+		}
+	}
+
+	if schema, err := FromJSONBytes(raw.JSON); err == nil && schema.ObjectAsType != nil {
+		draft = documentDraft((*Type)(schema.ObjectAsType), draft)
+	}
+
+	meta, err := metaschemaFor(draft)
+	if err != nil {
+		return nil, err
+	}
+
+	v := &validator{
+		meta:      meta,
+		patterns:  map[string]*regexp.Regexp{},
+		locations: map[string]map[string]interface{}{},
+	}
+
+	violations, err := v.validate(meta.root, instance, []string{})
+	if err != nil {
+		return nil, err
+	}
+
+	if options != nil && options.DisallowUnknownKeywords {
+		for pointer, object := range v.locations {
+			for keyword := range object {
+				if !meta.keywords[keyword] && !extensionKeywords[keyword] {
+					violations = append(violations, Violation{
+						Location: Location{Pointer: pointer + FormatPointer([]string{keyword})},
+						Message:  fmt.Sprintf("unknown keyword %q", keyword),
+					})
+				}
+			}
+		}
+	}
+
+	sort.SliceStable(violations, func(i, j int) bool {
+		return violations[i].Location.Pointer < violations[j].Location.Pointer
+	})
+
+	unique := violations[:0]
+	for i, violation := range violations {
+		if i > 0 && violation.Location == violations[i-1].Location && violation.Message == violations[i-1].Message {
+			continue
+		}
+
+		violation.Location = raw.Location(violation.Location.Pointer)
+		unique = append(unique, violation)
+	}
+
+	return unique, nil
+}
+
+type validator struct {
+	meta     *metaschema
+	patterns map[string]*regexp.Regexp
+	// locations holds the objects validated as schemas, by JSON pointer.
+	locations map[string]map[string]interface{}
+}
+
+// isFalseSchema reports whether schema is `false`, locations aside.
+func isFalseSchema(schema *Type) bool {
+	if schema.Not == nil {
+		return false
+	}
+
+	item, not := *schema, *schema.Not
+	item.Location, not.Location = Location{}, Location{}
+	item.Not = &not
+
+	return reflect.DeepEqual(item, Type{Not: &Type{}})
+}
+
+func jsonTypeName(instance interface{}) string {
+	switch value := instance.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		if value == math.Trunc(value) {
+			return "integer"
+		}
+
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	default:
+		return "object"
+	}
+}
+
+func matchesType(types TypeList, instance interface{}) bool {
+	actual := jsonTypeName(instance)
+	for _, name := range types {
+		if name == actual || (name == "number" && actual == "integer") {
+			return true
+		}
+	}
+
+	return false
+}
+
+func formatValue(value interface{}) string {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+
+	return string(data)
+}
+
+func (v *validator) pattern(expr string) *regexp.Regexp {
+	if re, ok := v.patterns[expr]; ok {
+		return re
+	}
+
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		// patterns Go cannot compile are not checked
+		re = nil
+	}
+
+	v.patterns[expr] = re
+
+	return re
+}
+
+// resolve follows one of the reference keywords of schema. Dynamic references
+// land on the meta-schema root when it declares the same dynamic anchor, which
+// is where validation always starts.
+func (v *validator) resolve(schema *Type, ref string) (*Type, error) {
+	resolver := v.meta.resolver
+
+	_, target, _, err := resolver.Resolve(resolver.BaseURI(schema), ref)
+	if err != nil {
+		return nil, err
+	}
+
+	root := v.meta.root
+
+	switch {
+	case schema.DynamicRef != nil && ref == *schema.DynamicRef:
+		if target.DynamicAnchor != nil && root.DynamicAnchor != nil && *target.DynamicAnchor == *root.DynamicAnchor {
+			return root, nil
+		}
+	case schema.RecursiveRef != nil && ref == *schema.RecursiveRef:
+		if target.RecursiveAnchor && root.RecursiveAnchor {
+			return root, nil
+		}
+	}
+
+	return target, nil
+}
+
+func (v *validator) validate(schema *Type, instance interface{}, pointer []string) ([]Violation, error) {
+	violations := []Violation{}
+	report := func(keyword string, format string, args ...interface{}) {
+		violations = append(violations, Violation{
+			Location: Location{Pointer: FormatPointer(pointer)},
+			Message:  fmt.Sprintf(format, args...),
+			keyword:  keyword,
+		})
+	}
+	child := func(segment string) []string {
+		return append(append([]string{}, pointer...), segment)
+	}
+	apply := func(sub *Type, instance interface{}, pointer []string) error {
+		result, err := v.validate(sub, instance, pointer)
+		violations = append(violations, result...)
+
+		return err
+	}
+
+	if object, ok := instance.(map[string]interface{}); ok && schema == v.meta.root {
+		v.locations[FormatPointer(pointer)] = object
+	}
+
+	if isFalseSchema(schema) {
+		report("not", "no value is allowed here")
+
+		return violations, nil
+	}
+
+	if len(schema.Type) > 0 && !matchesType(schema.Type, instance) {
+		report("type", "expected %s, got %s", strings.Join(schema.Type, " or "), jsonTypeName(instance))
+		violations[len(violations)-1].expected = schema.Type
+
+		return violations, nil
+	}
+
+	if schema.Enum != nil {
+		found := false
+		for _, item := range schema.Enum {
+			if reflect.DeepEqual(item, instance) {
+				found = true
+			}
+		}
+
+		if !found {
+			names := make([]string, 0, len(schema.Enum))
+			types := TypeList{}
+			for _, item := range schema.Enum {
+				names = append(names, formatValue(item))
+				types = append(types, jsonTypeName(item))
+			}
+
+			report("enum", "%s is not one of %s", formatValue(instance), strings.Join(names, ", "))
+
+			// none of the values has the type of the instance
+			if !matchesType(types, instance) {
+				violations[len(violations)-1].keyword = "type"
+				violations[len(violations)-1].expected = types
+			}
+		}
+	}
+
+	if schema.Const != nil && !reflect.DeepEqual(*schema.Const, instance) {
+		report("const", "must be %s", formatValue(*schema.Const))
+	}
+
+	for _, ref := range []*string{schema.Ref, schema.DynamicRef, schema.RecursiveRef} {
+		if ref == nil {
+			continue
+		}
+
+		target, err := v.resolve(schema, *ref)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := apply(target, instance, pointer); err != nil {
+			return nil, err
+		}
+	}
+
+	switch value := instance.(type) {
+	case float64:
+		if schema.MultipleOf != nil && *schema.MultipleOf > 0 {
+			if quotient := value / *schema.MultipleOf; quotient != math.Trunc(quotient) {
+				report("multipleOf", "must be a multiple of %g", *schema.MultipleOf)
+			}
+		}
+
+		if bound, exclusive, ok := schema.LowerBound(); ok {
+			if exclusive && value <= bound {
+				report("exclusiveMinimum", "must be greater than %g", bound)
+			} else if !exclusive && value < bound {
+				report("minimum", "must be greater than or equal to %g", bound)
+			}
+		}
+
+		if bound, exclusive, ok := schema.UpperBound(); ok {
+			if exclusive && value >= bound {
+				report("exclusiveMaximum", "must be less than %g", bound)
+			} else if !exclusive && value > bound {
+				report("maximum", "must be less than or equal to %g", bound)
+			}
+		}
+	case string:
+		length := utf8.RuneCountInString(value)
+		if schema.MinLength != nil && length < *schema.MinLength {
+			report("minLength", "must be at least %d characters long", *schema.MinLength)
+		}
+
+		if schema.MaxLength != nil && length > *schema.MaxLength {
+			report("maxLength", "must be at most %d characters long", *schema.MaxLength)
+		}
+
+		if schema.Pattern != nil {
+			if re := v.pattern(*schema.Pattern); re != nil && !re.MatchString(value) {
+				report("pattern", "must match pattern %q", *schema.Pattern)
+			}
+		}
+	case []interface{}:
+		if err := v.validateArray(schema, value, pointer, report, child, apply); err != nil {
+			return nil, err
+		}
+	case map[string]interface{}:
+		if err := v.validateObject(schema, value, pointer, report, child, apply); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, sub := range schema.AllOf {
+		if err := apply(sub, instance, pointer); err != nil {
+			return nil, err
+		}
+	}
+
+	if schema.AnyOf != nil || schema.OneOf != nil {
+		for _, combinator := range []struct {
+			keyword string
+			value   []*Type
+		}{
+			{"anyOf", schema.AnyOf},
+			{"oneOf", schema.OneOf},
+		} {
+			if combinator.value == nil {
+				continue
+			}
+
+			results := [][]Violation{}
+			matched := 0
+
+			for _, sub := range combinator.value {
+				result, err := v.validate(sub, instance, pointer)
+				if err != nil {
+					return nil, err
+				}
+
+				if len(result) == 0 {
+					matched += 1
+				}
+
+				results = append(results, result)
+			}
+
+			switch {
+			case matched == 0:
+				violations = append(violations, closestBranch(results, FormatPointer(pointer), instance, combinator.keyword)...)
+			case matched > 1 && combinator.keyword == "oneOf":
+				report("oneOf", "must match exactly one schema of oneOf, matches %d", matched)
+			}
+		}
+	}
+
+	if schema.Not != nil {
+		result, err := v.validate(schema.Not, instance, pointer)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(result) == 0 {
+			report("not", "must not match the schema of not")
+		}
+	}
+
+	if schema.If != nil {
+		result, err := v.validate(schema.If, instance, pointer)
+		if err != nil {
+			return nil, err
+		}
+
+		branch := schema.Then
+		if len(result) != 0 {
+			branch = schema.Else
+		}
+
+		if branch != nil {
+			if err := apply(branch, instance, pointer); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return violations, nil
+}
+
+func (v *validator) validateArray(schema *Type, value []interface{}, pointer []string, report func(string, string, ...interface{}), child func(string) []string, apply func(*Type, interface{}, []string) error) error {
+	for i, item := range value {
+		sub := schema.Items
+		if i < len(schema.PrefixItems) {
+			sub = schema.PrefixItems[i]
+		}
+
+		if sub != nil {
+			if err := apply(sub, item, child(fmt.Sprint(i))); err != nil {
+				return err
+			}
+		}
+	}
+
+	if schema.Contains != nil {
+		count := 0
+		for i, item := range value {
+			result, err := v.validate(schema.Contains, item, child(fmt.Sprint(i)))
+			if err != nil {
+				return err
+			}
+
+			if len(result) == 0 {
+				count += 1
+			}
+		}
+
+		minContains := 1
+		if schema.MinContains != nil {
+			minContains = *schema.MinContains
+		}
+
+		if count < minContains {
+			report("contains", "must contain at least %d matching items", minContains)
+		}
+
+		if schema.MaxContains != nil && count > *schema.MaxContains {
+			report("maxContains", "must contain at most %d matching items", *schema.MaxContains)
+		}
+	}
+
+	if schema.MinItems != nil && len(value) < *schema.MinItems {
+		report("minItems", "must have at least %d items", *schema.MinItems)
+	}
+
+	if schema.MaxItems != nil && len(value) > *schema.MaxItems {
+		report("maxItems", "must have at most %d items", *schema.MaxItems)
+	}
+
+	if schema.UniqueItems {
+	unique:
+		for i := range value {
+			for j := 0; j < i; j += 1 {
+				if reflect.DeepEqual(value[i], value[j]) {
+					report("uniqueItems", "items %d and %d are equal", j, i)
+
+					break unique
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+func (v *validator) validateObject(schema *Type, value map[string]interface{}, pointer []string, report func(string, string, ...interface{}), child func(string) []string, apply func(*Type, interface{}, []string) error) error {
+	for _, name := range schema.Required {
+		if _, ok := value[name]; !ok {
+			report("required", "missing required property %q", name)
+		}
+	}
+
+	if schema.MinProperties != nil && len(value) < *schema.MinProperties {
+		report("minProperties", "must have at least %d properties", *schema.MinProperties)
+	}
+
+	if schema.MaxProperties != nil && len(value) > *schema.MaxProperties {
+		report("maxProperties", "must have at most %d properties", *schema.MaxProperties)
+	}
+
+	keys := make([]string, 0, len(value))
+	for key := range value {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	patterns := make([]string, 0, len(schema.PatternProperties))
+	for pattern := range schema.PatternProperties {
+		patterns = append(patterns, pattern)
+	}
+
+	sort.Strings(patterns)
+
+	for _, key := range keys {
+		evaluated := false
+
+		if sub, ok := schema.Properties[key]; ok {
+			evaluated = true
+
+			if err := apply(sub, value[key], child(key)); err != nil {
+				return err
+			}
+		}
+
+		for _, pattern := range patterns {
+			if re := v.pattern(pattern); re != nil && re.MatchString(key) {
+				evaluated = true
+
+				if err := apply(schema.PatternProperties[pattern], value[key], child(key)); err != nil {
+					return err
+				}
+			}
+		}
+
+		if !evaluated && schema.AdditionalProperties != nil {
+			if err := apply(schema.AdditionalProperties, value[key], child(key)); err != nil {
+				return err
+			}
+		}
+
+		if schema.PropertyNames != nil {
+			if err := apply(schema.PropertyNames, key, child(key)); err != nil {
+				return err
+			}
+		}
+
+		for _, name := range schema.DependentRequired[key] {
+			if _, ok := value[name]; !ok {
+				report("dependentRequired", "property %q requires property %q", key, name)
+			}
+		}
+
+		if sub, ok := schema.DependentSchemas[key]; ok {
+			if err := apply(sub, value, pointer); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// closestBranch explains why no branch of anyOf or oneOf matched. The branches
+// failing on the type of the value are unlikely to be the intended ones, so
+// the violations of the first other branch with the fewest violations are
+// reported; when every branch rejects the type, the accepted types are listed.
+func closestBranch(results [][]Violation, pointer string, instance interface{}, keyword string) []Violation {
+	var best []Violation
+
+	expected := []string{}
+
+	for _, result := range results {
+		typed := false
+		for _, violation := range result {
+			if violation.Location.Pointer == pointer && violation.keyword == "type" {
+				typed = true
+
+				expected = append(expected, violation.expected...)
+			}
+		}
+
+		if !typed && (best == nil || len(result) < len(best)) {
+			best = result
+		}
+	}
+
+	if best != nil {
+		return best
+	}
+
+	if len(expected) == 0 {
+		return []Violation{{Location: Location{Pointer: pointer}, Message: fmt.Sprintf("must match a schema of %s", keyword), keyword: keyword}}
+	}
+
+	seen := map[string]bool{}
+	names := []string{}
+
+	for _, name := range expected {
+		if !seen[name] {
+			seen[name] = true
+
+			names = append(names, name)
+		}
+	}
+
+	return []Violation{{
+		Location: Location{Pointer: pointer},
+		Message:  fmt.Sprintf("expected %s, got %s", strings.Join(names, " or "), jsonTypeName(instance)),
+		keyword:  "type",
+		expected: names,
+	}}
+}
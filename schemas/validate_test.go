@@ -0,0 +1,95 @@
+package schemas
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	cases := []struct {
+		name       string
+		schema     string
+		draft      Draft
+		disallow   bool
+		violations []string
+	}{
+		{
+			name:   "valid schema",
+			schema: `{"type": "object", "properties": {"a": {"type": "string", "minLength": 1}}, "required": ["a"]}`,
+			draft:  DefaultDraft,
+		},
+		{
+			name:       "unknown type name",
+			schema:     `{"$defs": {"name": {"type": "strnig"}}}`,
+			draft:      DefaultDraft,
+			violations: []string{`/$defs/name/type: "strnig" is not one of "array", "boolean", "integer", "null", "number", "object", "string"`},
+		},
+		{
+			name:   "every violation",
+			schema: `{"minimum": "1", "required": "a", "properties": {"a": {"minLength": -1}}}`,
+			draft:  DefaultDraft,
+			violations: []string{
+				"/minimum: expected number, got string",
+				"/properties/a/minLength: must be greater than or equal to 0",
+				"/required: expected array, got string",
+			},
+		},
+		{
+			name:   "unknown keywords are ignored",
+			schema: `{"typo": 1}`,
+			draft:  DefaultDraft,
+		},
+		{
+			name:       "unknown keywords are disallowed",
+			schema:     `{"typo": 1, "discriminator": {"propertyName": "k"}, "x-discriminator": "k", "goJSONSchema": {}}`,
+			draft:      DefaultDraft,
+			disallow:   true,
+			violations: []string{`/typo: unknown keyword "typo"`},
+		},
+		{
+			name:   "draft-04 from $schema",
+			schema: `{"$schema": "http://json-schema.org/draft-04/schema#", "minimum": 1, "exclusiveMinimum": true}`,
+			draft:  DefaultDraft,
+		},
+		{
+			name:       "2020-12 from $schema",
+			schema:     `{"$schema": "https://json-schema.org/draft/2020-12/schema", "minimum": 1, "exclusiveMinimum": true}`,
+			draft:      Draft4,
+			violations: []string{"/exclusiveMinimum: expected number, got boolean"},
+		},
+		{
+			name:   "boolean bound without $schema",
+			schema: `{"properties": {"a": {"minimum": 1, "exclusiveMinimum": true}}}`,
+			draft:  DefaultDraft,
+		},
+		{
+			name:       "boolean bound in a resource with $schema",
+			schema:     `{"$defs": {"a": {"$schema": "https://json-schema.org/draft/2020-12/schema", "maximum": 1, "exclusiveMaximum": false}}}`,
+			draft:      DefaultDraft,
+			violations: []string{"/$defs/a/exclusiveMaximum: expected number, got boolean"},
+		},
+		{
+			name:   "draft option without $schema",
+			schema: `{"items": [{"type": "string"}], "exclusiveMaximum": true, "maximum": 1}`,
+			draft:  Draft4,
+		},
+	}
+	for _, c := range cases {
+		raw, err := ReadDocument("file:///test/schema.json", strings.NewReader(c.schema), FormatJSON)
+		if err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		violations, err := Validate(raw, c.draft, &ValidateOptions{DisallowUnknownKeywords: c.disallow})
+		if err != nil {
+			t.Errorf("%s: %v", c.name, err)
+			continue
+		}
+		got := []string{}
+		for _, violation := range violations {
+			got = append(got, violation.Location.Pointer+": "+violation.Message)
+		}
+		if strings.Join(got, "\n") != strings.Join(c.violations, "\n") {
+			t.Errorf("%s: got\n%s\nwant\n%s", c.name, strings.Join(got, "\n"), strings.Join(c.violations, "\n"))
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package schemas
+
+import (
+	"sort"
+	"strconv"
+)
+
+// Subschemas calls fn for every schema nested directly in t, together with the
+// JSON pointer segments leading from t to it. Map keyed keywords are visited in
+// key order so the traversal is deterministic.
+func (t *Type) Subschemas(fn func(segments []string, sub *Type) error) error {
+	single := []struct {
+		keyword string
+		value   *Type
+	}{
+		{"additionalItems", t.AdditionalItems},
+		{"items", t.Items},
+		{"additionalProperties", t.AdditionalProperties},
+		{"not", t.Not},
+		{"media", t.Media},
+		{"if", t.If},
+		{"then", t.Then},
+		{"else", t.Else},
+		{"contains", t.Contains},
+		{"propertyNames", t.PropertyNames},
+		{"unevaluatedItems", t.UnevaluatedItems},
+		{"unevaluatedProperties", t.UnevaluatedProperties},
+		{"contentSchema", t.ContentSchema},
+	}
+	for _, item := range single {
+		if item.value != nil {
+			if err := fn([]string{item.keyword}, item.value); err != nil {
+				return err
+			}
+		}
+	}
+
+	lists := []struct {
+		keyword string
+		value   []*Type
+	}{
+		{"prefixItems", t.PrefixItems},
+		{"allOf", t.AllOf},
+		{"anyOf", t.AnyOf},
+		{"oneOf", t.OneOf},
+	}
+	for _, item := range lists {
+		for i, sub := range item.value {
+			if sub != nil {
+				if err := fn([]string{item.keyword, strconv.Itoa(i)}, sub); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	maps := []struct {
+		keyword string
+		value   map[string]*Type
+	}{
+		{"properties", t.Properties},
+		{"patternProperties", t.PatternProperties},
+		{"dependentSchemas", t.DependentSchemas},
+		{"$defs", t.Definitions},
+	}
+	for _, item := range maps {
+		keys := make([]string, 0, len(item.value))
+		for key := range item.value {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			if sub := item.value[key]; sub != nil {
+				if err := fn([]string{item.keyword, key}, sub); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// Walk calls fn for t and every schema nested in it, depth first. Nested
+// definitions are skipped unless withDefinitions is set.
+func Walk(t *Type, withDefinitions bool, fn func(pointer []string, item *Type) error) error {
+	return walk(t, []string{}, withDefinitions, fn)
+}
+
+func walk(t *Type, pointer []string, withDefinitions bool, fn func(pointer []string, item *Type) error) error {
+	if err := fn(pointer, t); err != nil {
+		return err
+	}
+
+	return t.Subschemas(func(segments []string, sub *Type) error {
+		if !withDefinitions && segments[0] == "$defs" {
+			return nil
+		}
+
+		return walk(sub, append(append([]string{}, pointer...), segments...), withDefinitions, fn)
+	})
+}
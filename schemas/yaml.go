@@ -0,0 +1,1076 @@
+package schemas
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// yamlParser reads the subset of YAML 1.2 used to write schemas: block and flow
+// collections, plain, quoted and block scalars, comments, anchors and aliases.
+// Complex keys, multiple documents and custom tags are not supported.
+type yamlParser struct {
+	src       []rune
+	pos       int
+	line      int
+	column    int
+	lineStart int
+	anchors   map[string]*node
+}
+
+type yamlState struct {
+	pos       int
+	line      int
+	column    int
+	lineStart int
+}
+
+var (
+	yamlIntRegex   = regexp.MustCompile(`^[-+]?[0-9]+$`)
+	yamlOctRegex   = regexp.MustCompile(`^0o[0-7]+$`)
+	yamlHexRegex   = regexp.MustCompile(`^0x[0-9a-fA-F]+$`)
+	yamlFloatRegex = regexp.MustCompile(`^[-+]?(\.[0-9]+|[0-9]+(\.[0-9]*)?)([eE][-+]?[0-9]+)?$`)
+)
+
+func parseYAML(data string) (*node, error) {
+	p := &yamlParser{
+		src:     []rune(strings.TrimPrefix(data, "\ufeff")),
+		line:    1,
+		anchors: map[string]*node{},
+	}
+
+	if err := p.skipBlank(); err != nil {
+		return nil, err
+	}
+
+	for !p.eof() && p.column == 0 && p.peek(0) == '%' {
+		p.skipToLineEnd()
+
+		if err := p.skipBlank(); err != nil {
+			return nil, err
+		}
+	}
+
+	if p.atDocumentMarker("---") {
+		p.advanceN(3)
+	}
+
+	p.skipLineSpace()
+
+	if p.atLineEnd() {
+		if err := p.skipBlank(); err != nil {
+			return nil, err
+		}
+	}
+
+	root, err := p.parseBlockNode(-1)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := p.skipBlank(); err != nil {
+		return nil, err
+	}
+
+	if p.atDocumentMarker("...") {
+		p.advanceN(3)
+
+		if err := p.skipBlank(); err != nil {
+			return nil, err
+		}
+	}
+
+	if p.atDocumentMarker("---") {
+		return nil, p.errorf("multiple documents are not supported")
+	}
+
+	if !p.eof() {
+		return nil, p.errorf("unexpected content")
+	}
+
+	return root, nil
+}
+
+func (p *yamlParser) errorf(format string, args ...interface{}) error {
+	return &SyntaxError{Line: p.line, Column: p.column + 1, Msg: fmt.Sprintf(format, args...)}
+}
+
+func (p *yamlParser) eof() bool {
+	return p.pos >= len(p.src)
+}
+
+func (p *yamlParser) peek(offset int) rune {
+	if p.pos+offset >= len(p.src) {
+		return 0
+	}
+
+	return p.src[p.pos+offset]
+}
+
+func (p *yamlParser) advance() {
+	if p.eof() {
+		return
+	}
+
+	if p.src[p.pos] == '\n' {
+		p.line += 1
+		p.column = 0
+		p.lineStart = p.pos + 1
+	} else {
+		p.column += 1
+	}
+
+	p.pos += 1
+}
+
+func (p *yamlParser) advanceN(n int) {
+	for i := 0; i < n; i += 1 {
+		p.advance()
+	}
+}
+
+func (p *yamlParser) save() yamlState {
+	return yamlState{p.pos, p.line, p.column, p.lineStart}
+}
+
+func (p *yamlParser) restore(state yamlState) {
+	p.pos, p.line, p.column, p.lineStart = state.pos, state.line, state.column, state.lineStart
+}
+
+func isBlankRune(r rune) bool {
+	return r == ' ' || r == '\t' || r == '\n' || r == '\r' || r == 0
+}
+
+func isFlowIndicator(r rune) bool {
+	return r == ',' || r == '[' || r == ']' || r == '{' || r == '}'
+}
+
+func (p *yamlParser) atLineEnd() bool {
+	r := p.peek(0)
+	return r == 0 || r == '\n' || r == '\r' || r == '#'
+}
+
+func (p *yamlParser) atDocumentMarker(marker string) bool {
+	if p.column != 0 || p.pos+3 > len(p.src) || string(p.src[p.pos:p.pos+3]) != marker {
+		return false
+	}
+
+	return isBlankRune(p.peek(3))
+}
+
+func (p *yamlParser) atSequenceEntry() bool {
+	return p.peek(0) == '-' && isBlankRune(p.peek(1))
+}
+
+func (p *yamlParser) skipLineSpace() {
+	for p.peek(0) == ' ' || p.peek(0) == '\t' {
+		p.advance()
+	}
+}
+
+func (p *yamlParser) skipToLineEnd() {
+	for !p.eof() && p.peek(0) != '\n' {
+		p.advance()
+	}
+}
+
+// skipBlank moves to the next content, skipping white space, line breaks and
+// comments.
+func (p *yamlParser) skipBlank() error {
+	for !p.eof() {
+		switch p.peek(0) {
+		case ' ', '\t', '\r', '\n':
+			p.advance()
+		case '#':
+			p.skipToLineEnd()
+		default:
+			indentation := string(p.src[p.lineStart:p.pos])
+			if strings.ContainsRune(indentation, '\t') && strings.TrimLeft(indentation, " \t") == "" {
+				return p.errorf("tabs are not allowed for indentation")
+			}
+
+			return nil
+		}
+	}
+
+	return nil
+}
+
+func (p *yamlParser) parseProperties() (string, string, error) {
+	anchor := ""
+	tag := ""
+
+	for {
+		switch p.peek(0) {
+		case '&':
+			p.advance()
+			anchor = p.readName()
+
+			if anchor == "" {
+				return "", "", p.errorf("empty anchor name")
+			}
+		case '!':
+			tag = p.readName()
+		default:
+			return anchor, tag, nil
+		}
+
+		p.skipLineSpace()
+	}
+}
+
+func (p *yamlParser) readName() string {
+	start := p.pos
+	for !p.eof() && !isBlankRune(p.peek(0)) && !isFlowIndicator(p.peek(0)) {
+		p.advance()
+	}
+
+	return string(p.src[start:p.pos])
+}
+
+// finishNode applies the anchor and the tag parsed before a node.
+func (p *yamlParser) finishNode(n *node, anchor, tag string, line, column int) (*node, error) {
+	switch tag {
+	case "":
+	case "!!str":
+		if n.kind == arrayNode || n.kind == objectNode {
+			return nil, &SyntaxError{Line: line, Column: column, Msg: "!!str applied to a collection"}
+		}
+
+		n.kind = stringNode
+	case "!!map", "!!seq", "!!int", "!!float", "!!bool", "!!null":
+	default:
+		return nil, &SyntaxError{Line: line, Column: column, Msg: fmt.Sprintf("unsupported tag %s", tag)}
+	}
+
+	if anchor != "" {
+		p.anchors[anchor] = n
+	}
+
+	return n, nil
+}
+
+func (p *yamlParser) parseAlias() (*node, error) {
+	line, column := p.line, p.column+1
+	p.advance()
+
+	name := p.readName()
+	if n, ok := p.anchors[name]; ok {
+		return n, nil
+	}
+
+	return nil, &SyntaxError{Line: line, Column: column, Msg: fmt.Sprintf("unknown anchor %q", name)}
+}
+
+// parseBlockNode parses the node starting at the current position. Its content
+// must be indented more than parentIndent.
+func (p *yamlParser) parseBlockNode(parentIndent int) (*node, error) {
+	line, column := p.line, p.column+1
+	if p.eof() || p.column <= parentIndent || p.atDocumentMarker("---") || p.atDocumentMarker("...") {
+		return &node{kind: nullNode, line: line, column: column}, nil
+	}
+
+	anchor, tag, err := p.parseProperties()
+	if err != nil {
+		return nil, err
+	}
+
+	if anchor != "" || tag != "" {
+		if p.atLineEnd() {
+			if err := p.skipBlank(); err != nil {
+				return nil, err
+			}
+
+			if p.eof() || p.column <= parentIndent {
+				return p.finishNode(&node{kind: nullNode, line: line, column: column}, anchor, tag, line, column)
+			}
+		}
+
+		line, column = p.line, p.column+1
+	}
+
+	var n *node
+
+	switch {
+	case p.atSequenceEntry():
+		n, err = p.parseBlockSequence(p.column)
+	case p.peek(0) == '|' || p.peek(0) == '>':
+		n, err = p.parseBlockScalar(parentIndent)
+	case p.peek(0) == '*':
+		n, err = p.parseAlias()
+	case p.peek(0) == '[' || p.peek(0) == '{':
+		n, err = p.parseFlowNode()
+	default:
+		indent := p.column
+		plain := p.peek(0) != '"' && p.peek(0) != '\''
+
+		n, err = p.parseBlockScalarLine()
+		if err != nil {
+			return nil, err
+		}
+
+		p.skipLineSpace()
+
+		if p.peek(0) == ':' && (isBlankRune(p.peek(1)) || !plain) {
+			if n.line != p.line {
+				return nil, p.errorf("mapping keys must be on a single line")
+			}
+
+			n, err = p.parseBlockMapping(indent, n)
+		} else if plain {
+			n, err = p.continuePlain(n, parentIndent)
+		}
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return p.finishNode(n, anchor, tag, line, column)
+}
+
+func (p *yamlParser) parseBlockSequence(indent int) (*node, error) {
+	seq := &node{kind: arrayNode, line: p.line, column: p.column + 1}
+
+	for {
+		p.advance()
+		p.skipLineSpace()
+
+		if p.atLineEnd() {
+			if err := p.skipBlank(); err != nil {
+				return nil, err
+			}
+		}
+
+		item, err := p.parseBlockNode(indent)
+		if err != nil {
+			return nil, err
+		}
+
+		seq.items = append(seq.items, item)
+
+		if err := p.skipBlank(); err != nil {
+			return nil, err
+		}
+
+		if p.eof() || p.column < indent || p.atDocumentMarker("---") || p.atDocumentMarker("...") {
+			return seq, nil
+		}
+
+		if p.column > indent {
+			return nil, p.errorf("bad indentation of a sequence entry")
+		}
+
+		if !p.atSequenceEntry() {
+			return seq, nil
+		}
+	}
+}
+
+func (p *yamlParser) parseBlockMapping(indent int, key *node) (*node, error) {
+	mapping := newObjectNode(key.line, key.column)
+
+	for {
+		if key.kind == arrayNode || key.kind == objectNode {
+			return nil, &SyntaxError{Line: key.line, Column: key.column, Msg: "complex mapping keys are not supported"}
+		}
+
+		p.advance() // ':'
+
+		value, err := p.parseMappingValue(indent)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := mapping.set(key.text, value); err != nil {
+			return nil, &SyntaxError{Line: key.line, Column: key.column, Msg: err.(*SyntaxError).Msg}
+		}
+
+		if err := p.skipBlank(); err != nil {
+			return nil, err
+		}
+
+		if p.eof() || p.column < indent || p.atDocumentMarker("---") || p.atDocumentMarker("...") {
+			return mapping, nil
+		}
+
+		if p.column > indent {
+			return nil, p.errorf("bad indentation of a mapping entry")
+		}
+
+		if p.atSequenceEntry() {
+			return nil, p.errorf("unexpected sequence entry in a mapping")
+		}
+
+		if p.peek(0) == '?' && isBlankRune(p.peek(1)) {
+			return nil, p.errorf("complex mapping keys are not supported")
+		}
+
+		plain := p.peek(0) != '"' && p.peek(0) != '\''
+
+		key, err = p.parseBlockScalarLine()
+		if err != nil {
+			return nil, err
+		}
+
+		p.skipLineSpace()
+
+		if p.peek(0) != ':' || (plain && !isBlankRune(p.peek(1))) {
+			return nil, p.errorf("expected ':' after a mapping key")
+		}
+	}
+}
+
+func (p *yamlParser) parseMappingValue(indent int) (*node, error) {
+	p.skipLineSpace()
+	line, column := p.line, p.column+1
+
+	anchor, tag, err := p.parseProperties()
+	if err != nil {
+		return nil, err
+	}
+
+	var n *node
+
+	switch {
+	case p.atLineEnd():
+		if err := p.skipBlank(); err != nil {
+			return nil, err
+		}
+
+		switch {
+		case p.eof() || p.atDocumentMarker("---") || p.atDocumentMarker("..."):
+			n = &node{kind: nullNode, line: line, column: column}
+		case p.column > indent:
+			n, err = p.parseBlockNode(indent)
+		case p.column == indent && p.atSequenceEntry():
+			n, err = p.parseBlockSequence(indent)
+		default:
+			n = &node{kind: nullNode, line: line, column: column}
+		}
+	case p.atSequenceEntry():
+		return nil, p.errorf("block sequences are not allowed on the line of a mapping key")
+	case p.peek(0) == '|' || p.peek(0) == '>':
+		n, err = p.parseBlockScalar(indent)
+	case p.peek(0) == '*':
+		n, err = p.parseAlias()
+	case p.peek(0) == '[' || p.peek(0) == '{':
+		n, err = p.parseFlowNode()
+	default:
+		plain := p.peek(0) != '"' && p.peek(0) != '\''
+
+		n, err = p.parseBlockScalarLine()
+		if err != nil {
+			return nil, err
+		}
+
+		p.skipLineSpace()
+
+		if p.peek(0) == ':' && (isBlankRune(p.peek(1)) || !plain) {
+			return nil, p.errorf("mapping values are not allowed in this context")
+		}
+
+		if plain {
+			n, err = p.continuePlain(n, indent)
+		}
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return p.finishNode(n, anchor, tag, line, column)
+}
+
+// parseBlockScalarLine reads a quoted scalar, or a plain scalar up to the end of
+// the current line.
+func (p *yamlParser) parseBlockScalarLine() (*node, error) {
+	switch p.peek(0) {
+	case '"':
+		return p.parseDoubleQuoted()
+	case '\'':
+		return p.parseSingleQuoted()
+	}
+
+	line, column := p.line, p.column+1
+	text := p.readPlainLine(false)
+
+	if text == "" {
+		return nil, p.errorf("unexpected character %q", p.peek(0))
+	}
+
+	return &node{kind: stringNode, text: text, line: line, column: column}, nil
+}
+
+func (p *yamlParser) readPlainLine(flow bool) string {
+	start := p.pos
+	end := p.pos
+
+	for !p.eof() {
+		r := p.peek(0)
+		if r == '\n' || r == '\r' {
+			break
+		}
+
+		if r == ':' && (isBlankRune(p.peek(1)) || (flow && isFlowIndicator(p.peek(1)))) {
+			break
+		}
+
+		if r == '#' && p.pos > start && (p.src[p.pos-1] == ' ' || p.src[p.pos-1] == '\t') {
+			break
+		}
+
+		if flow && isFlowIndicator(r) {
+			break
+		}
+
+		p.advance()
+
+		if r != ' ' && r != '\t' {
+			end = p.pos
+		}
+	}
+
+	return string(p.src[start:end])
+}
+
+// continuePlain folds the continuation lines of a multi-line plain scalar and
+// resolves its type.
+func (p *yamlParser) continuePlain(n *node, parentIndent int) (*node, error) {
+	builder := strings.Builder{}
+	builder.WriteString(n.text)
+
+	for {
+		state := p.save()
+		p.skipLineSpace()
+
+		if p.peek(0) == '#' || p.eof() {
+			p.restore(state)
+			break
+		}
+
+		breaks := 0
+
+		for !p.eof() {
+			r := p.peek(0)
+			if r == '\n' {
+				breaks += 1
+			} else if r != ' ' && r != '\t' && r != '\r' {
+				break
+			}
+
+			p.advance()
+		}
+
+		if breaks == 0 || p.eof() || p.column <= parentIndent || p.peek(0) == '#' ||
+			p.atDocumentMarker("---") || p.atDocumentMarker("...") {
+			p.restore(state)
+			break
+		}
+
+		text := p.readPlainLine(false)
+		if text == "" {
+			p.restore(state)
+			break
+		}
+
+		if p.peek(0) == ':' {
+			return nil, p.errorf("mapping values are not allowed in this context")
+		}
+
+		if breaks == 1 {
+			builder.WriteString(" ")
+		} else {
+			builder.WriteString(strings.Repeat("\n", breaks-1))
+		}
+
+		builder.WriteString(text)
+	}
+
+	return resolvePlain(builder.String(), n.line, n.column)
+}
+
+func resolvePlain(text string, line, column int) (*node, error) {
+	n := &node{kind: stringNode, text: text, line: line, column: column}
+
+	switch text {
+	case "", "~", "null", "Null", "NULL":
+		n.kind = nullNode
+
+		return n, nil
+	case "true", "True", "TRUE", "false", "False", "FALSE":
+		n.kind = boolNode
+
+		return n, nil
+	case ".inf", ".Inf", ".INF", "+.inf", "+.Inf", "+.INF", "-.inf", "-.Inf", "-.INF", ".nan", ".NaN", ".NAN":
+		return nil, &SyntaxError{Line: line, Column: column, Msg: fmt.Sprintf("%s cannot be represented in JSON", text)}
+	}
+
+	switch {
+	case yamlIntRegex.MatchString(text):
+		sign := ""
+		digits := text
+
+		if digits[0] == '-' || digits[0] == '+' {
+			if digits[0] == '-' {
+				sign = "-"
+			}
+
+			digits = digits[1:]
+		}
+
+		digits = strings.TrimLeft(digits, "0")
+		if digits == "" {
+			digits = "0"
+		}
+
+		n.kind = numberNode
+		n.text = sign + digits
+	case yamlOctRegex.MatchString(text), yamlHexRegex.MatchString(text):
+		base := 8
+		if text[1] == 'x' {
+			base = 16
+		}
+
+		value, err := strconv.ParseUint(text[2:], base, 64)
+		if err != nil {
+			return nil, &SyntaxError{Line: line, Column: column, Msg: fmt.Sprintf("invalid number %s", text)}
+		}
+
+		n.kind = numberNode
+		n.text = strconv.FormatUint(value, 10)
+	case yamlFloatRegex.MatchString(text):
+		value, err := strconv.ParseFloat(text, 64)
+		if err != nil || math.IsInf(value, 0) {
+			return nil, &SyntaxError{Line: line, Column: column, Msg: fmt.Sprintf("invalid number %s", text)}
+		}
+
+		n.kind = numberNode
+		n.text = strconv.FormatFloat(value, 'g', -1, 64)
+	}
+
+	return n, nil
+}
+
+// foldQuotedBreak handles a line break inside a quoted scalar: trailing spaces
+// are dropped, a single break becomes a space and empty lines become breaks.
+func (p *yamlParser) foldQuotedBreak(builder *strings.Builder) {
+	text := strings.TrimRight(builder.String(), " \t")
+	builder.Reset()
+	builder.WriteString(text)
+
+	breaks := 0
+
+	for !p.eof() {
+		r := p.peek(0)
+		if r == '\n' {
+			breaks += 1
+		} else if r != ' ' && r != '\t' && r != '\r' {
+			break
+		}
+
+		p.advance()
+	}
+
+	if breaks == 1 {
+		builder.WriteString(" ")
+	} else {
+		builder.WriteString(strings.Repeat("\n", breaks-1))
+	}
+}
+
+func (p *yamlParser) parseSingleQuoted() (*node, error) {
+	line, column := p.line, p.column+1
+	builder := strings.Builder{}
+	p.advance()
+
+	for {
+		if p.eof() {
+			return nil, &SyntaxError{Line: line, Column: column, Msg: "unterminated quoted scalar"}
+		}
+
+		r := p.peek(0)
+
+		switch {
+		case r == '\'' && p.peek(1) == '\'':
+			builder.WriteRune('\'')
+			p.advanceN(2)
+		case r == '\'':
+			p.advance()
+
+			return &node{kind: stringNode, text: builder.String(), line: line, column: column}, nil
+		case r == '\n' || r == '\r':
+			p.foldQuotedBreak(&builder)
+		default:
+			builder.WriteRune(r)
+			p.advance()
+		}
+	}
+}
+
+var yamlEscapes = map[rune]string{
+	'0':  "\x00",
+	'a':  "\a",
+	'b':  "\b",
+	't':  "\t",
+	'\t': "\t",
+	'n':  "\n",
+	'v':  "\v",
+	'f':  "\f",
+	'r':  "\r",
+	'e':  "\x1b",
+	' ':  " ",
+	'"':  "\"",
+	'/':  "/",
+	'\\': "\\",
+	'N':  "\u0085",
+	'_':  "\u00a0",
+	'L':  "\u2028",
+	'P':  "\u2029",
+}
+
+func (p *yamlParser) parseDoubleQuoted() (*node, error) {
+	line, column := p.line, p.column+1
+	builder := strings.Builder{}
+	p.advance()
+
+	for {
+		if p.eof() {
+			return nil, &SyntaxError{Line: line, Column: column, Msg: "unterminated quoted scalar"}
+		}
+
+		r := p.peek(0)
+
+		switch r {
+		case '"':
+			p.advance()
+
+			return &node{kind: stringNode, text: builder.String(), line: line, column: column}, nil
+		case '\n', '\r':
+			p.foldQuotedBreak(&builder)
+		case '\\':
+			escape := p.peek(1)
+			if escape == '\n' || escape == '\r' {
+				// escaped line break, the content continues without space
+				p.advance()
+
+				for !p.eof() && isBlankRune(p.peek(0)) {
+					p.advance()
+				}
+
+				continue
+			}
+
+			if text, ok := yamlEscapes[escape]; ok {
+				builder.WriteString(text)
+				p.advanceN(2)
+
+				continue
+			}
+
+			size := map[rune]int{'x': 2, 'u': 4, 'U': 8}[escape]
+			if size == 0 || p.pos+2+size > len(p.src) {
+				return nil, p.errorf("invalid escape sequence")
+			}
+
+			code, err := strconv.ParseUint(string(p.src[p.pos+2:p.pos+2+size]), 16, 32)
+			if err != nil || !utf8.ValidRune(rune(code)) {
+				return nil, p.errorf("invalid escape sequence")
+			}
+
+			builder.WriteRune(rune(code))
+			p.advanceN(2 + size)
+		default:
+			builder.WriteRune(r)
+			p.advance()
+		}
+	}
+}
+
+// parseBlockScalar reads a literal (|) or folded (>) block scalar.
+func (p *yamlParser) parseBlockScalar(parentIndent int) (*node, error) {
+	line, column := p.line, p.column+1
+	folded := p.peek(0) == '>'
+	p.advance()
+
+	chomping := ' '
+	explicitIndent := 0
+
+	for i := 0; i < 2; i += 1 {
+		r := p.peek(0)
+		if (r == '+' || r == '-') && chomping == ' ' {
+			chomping = r
+			p.advance()
+		} else if r >= '1' && r <= '9' && explicitIndent == 0 {
+			explicitIndent = int(r - '0')
+			p.advance()
+		}
+	}
+
+	p.skipLineSpace()
+
+	if p.peek(0) == '#' {
+		p.skipToLineEnd()
+	}
+
+	if !p.eof() && p.peek(0) != '\n' && p.peek(0) != '\r' {
+		return nil, p.errorf("unexpected content after block scalar indicator")
+	}
+
+	if p.peek(0) == '\r' {
+		p.advance()
+	}
+
+	p.advance()
+
+	baseIndent := parentIndent
+	if baseIndent < 0 {
+		baseIndent = 0
+	}
+
+	contentIndent := -1
+	if explicitIndent != 0 {
+		contentIndent = baseIndent + explicitIndent
+	}
+
+	lines := []string{}
+
+	for !p.eof() {
+		state := p.save()
+		spaces := 0
+
+		for p.peek(0) == ' ' && (contentIndent < 0 || spaces < contentIndent) {
+			spaces += 1
+			p.advance()
+		}
+
+		if p.eof() || p.peek(0) == '\n' || p.peek(0) == '\r' {
+			lines = append(lines, "")
+
+			if p.peek(0) == '\r' {
+				p.advance()
+			}
+
+			p.advance()
+
+			continue
+		}
+
+		if contentIndent < 0 {
+			if spaces <= parentIndent {
+				p.restore(state)
+				break
+			}
+
+			contentIndent = spaces
+		}
+
+		if spaces < contentIndent || p.atDocumentMarker("---") || p.atDocumentMarker("...") {
+			p.restore(state)
+			break
+		}
+
+		start := p.pos
+		p.skipToLineEnd()
+
+		lines = append(lines, strings.TrimSuffix(string(p.src[start:p.pos]), "\r"))
+		p.advance()
+	}
+
+	trailing := 0
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+		trailing += 1
+	}
+
+	builder := strings.Builder{}
+
+	if folded {
+		first := true
+		moreIndented := false
+		breaks := 0
+
+		for _, text := range lines {
+			if text == "" {
+				breaks += 1
+				continue
+			}
+
+			more := text[0] == ' ' || text[0] == '\t'
+
+			if !first && breaks == 0 && !more && !moreIndented {
+				builder.WriteString(" ")
+			} else if !first && (more || moreIndented) {
+				builder.WriteString("\n")
+			}
+
+			builder.WriteString(strings.Repeat("\n", breaks))
+			builder.WriteString(text)
+
+			first = false
+			moreIndented = more
+			breaks = 0
+		}
+	} else {
+		builder.WriteString(strings.Join(lines, "\n"))
+	}
+
+	text := builder.String()
+
+	switch chomping {
+	case '-':
+	case '+':
+		text += strings.Repeat("\n", trailing+1)
+	default:
+		if text != "" {
+			text += "\n"
+		}
+	}
+
+	return &node{kind: stringNode, text: text, line: line, column: column}, nil
+}
+
+func (p *yamlParser) skipFlowSpace() {
+	for !p.eof() {
+		switch p.peek(0) {
+		case ' ', '\t', '\r', '\n':
+			p.advance()
+		case '#':
+			p.skipToLineEnd()
+		default:
+			return
+		}
+	}
+}
+
+func (p *yamlParser) parseFlowNode() (*node, error) {
+	p.skipFlowSpace()
+	line, column := p.line, p.column+1
+
+	anchor, tag, err := p.parseProperties()
+	if err != nil {
+		return nil, err
+	}
+
+	p.skipFlowSpace()
+
+	var n *node
+
+	switch p.peek(0) {
+	case '[':
+		n, err = p.parseFlowSequence()
+	case '{':
+		n, err = p.parseFlowMapping()
+	case '*':
+		n, err = p.parseAlias()
+	case '"':
+		n, err = p.parseDoubleQuoted()
+	case '\'':
+		n, err = p.parseSingleQuoted()
+	case 0:
+		return nil, p.errorf("unexpected end of document in a flow collection")
+	default:
+		scalarLine, scalarColumn := p.line, p.column+1
+
+		text := p.readPlainLine(true)
+		if text == "" {
+			return nil, p.errorf("unexpected character %q", p.peek(0))
+		}
+
+		n, err = resolvePlain(text, scalarLine, scalarColumn)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return p.finishNode(n, anchor, tag, line, column)
+}
+
+func (p *yamlParser) parseFlowSequence() (*node, error) {
+	seq := &node{kind: arrayNode, line: p.line, column: p.column + 1}
+	p.advance()
+
+	for {
+		p.skipFlowSpace()
+
+		if p.peek(0) == ']' {
+			p.advance()
+
+			return seq, nil
+		}
+
+		item, err := p.parseFlowNode()
+		if err != nil {
+			return nil, err
+		}
+
+		seq.items = append(seq.items, item)
+		p.skipFlowSpace()
+
+		switch p.peek(0) {
+		case ',':
+			p.advance()
+		case ']':
+		default:
+			return nil, p.errorf("expected ',' or ']' in a flow sequence")
+		}
+	}
+}
+
+func (p *yamlParser) parseFlowMapping() (*node, error) {
+	mapping := newObjectNode(p.line, p.column+1)
+	p.advance()
+
+	for {
+		p.skipFlowSpace()
+
+		if p.peek(0) == '}' {
+			p.advance()
+
+			return mapping, nil
+		}
+
+		key, err := p.parseFlowNode()
+		if err != nil {
+			return nil, err
+		}
+
+		if key.kind == arrayNode || key.kind == objectNode {
+			return nil, &SyntaxError{Line: key.line, Column: key.column, Msg: "complex mapping keys are not supported"}
+		}
+
+		p.skipFlowSpace()
+
+		value := &node{kind: nullNode, line: p.line, column: p.column + 1}
+
+		if p.peek(0) == ':' {
+			p.advance()
+			p.skipFlowSpace()
+
+			if p.peek(0) != ',' && p.peek(0) != '}' {
+				value, err = p.parseFlowNode()
+				if err != nil {
+					return nil, err
+				}
+			}
+		}
+
+		if err := mapping.set(key.text, value); err != nil {
+			return nil, &SyntaxError{Line: key.line, Column: key.column, Msg: err.(*SyntaxError).Msg}
+		}
+
+		p.skipFlowSpace()
+
+		switch p.peek(0) {
+		case ',':
+			p.advance()
+		case '}':
+		default:
+			return nil, p.errorf("expected ',' or '}' in a flow mapping")
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package schemas
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestParseYAML(t *testing.T) {
+	cases := []struct {
+		name string
+		yaml string
+		json string
+	}{
+		{
+			name: "block mapping",
+			yaml: "type: object\nproperties:\n  name:\n    type: string\n  age: {type: integer, minimum: 0}\n",
+			json: `{"type":"object","properties":{"name":{"type":"string"},"age":{"type":"integer","minimum":0}}}`,
+		},
+		{
+			name: "sequences",
+			yaml: "required:\n- a\n- b\nenum:\n  - 1\n  - [x, 'y', \"z\"]\n",
+			json: `{"required":["a","b"],"enum":[1,["x","y","z"]]}`,
+		},
+		{
+			name: "nested sequence entries",
+			yaml: "- - a\n  - b\n- c: 1\n  d: 2\n",
+			json: `[["a","b"],{"c":1,"d":2}]`,
+		},
+		{
+			name: "scalars",
+			yaml: "a: ~\nb: null\nc: true\nd: False\ne: 010\nf: 0x1F\ng: 0o17\nh: 1.50\ni: -2e3\nj: 1.2.3\nk: '007'\nl: +5\n",
+			json: `{"a":null,"b":null,"c":true,"d":false,"e":10,"f":31,"g":15,"h":1.5,"i":-2000,"j":"1.2.3","k":"007","l":5}`,
+		},
+		{
+			name: "quoted scalars",
+			yaml: "a: 'it''s # not a comment'\nb: \"tab\\tline\\nunicode \\u00e9\"\nc: \"folded\n  line\"\n",
+			json: `{"a":"it's # not a comment","b":"tab\tline\nunicode é","c":"folded line"}`,
+		},
+		{
+			name: "plain multi-line",
+			yaml: "description: first\n  second\n\n  third\nnext: 1\n",
+			json: `{"description":"first second\nthird","next":1}`,
+		},
+		{
+			name: "literal block scalars",
+			yaml: "a: |\n  line 1\n    indented\n  line 2\nb: |-\n  stripped\n\nc: |+\n  kept\n\nd: 1\n",
+			json: `{"a":"line 1\n  indented\nline 2\n","b":"stripped","c":"kept\n\n","d":1}`,
+		},
+		{
+			name: "folded block scalar",
+			yaml: "a: >\n  folded\n  text\n\n  paragraph\n",
+			json: `{"a":"folded text\nparagraph\n"}`,
+		},
+		{
+			name: "comments",
+			yaml: "# heading\ntype: string # trailing\n# between\npattern: a#b\n",
+			json: `{"type":"string","pattern":"a#b"}`,
+		},
+		{
+			name: "anchors and aliases",
+			yaml: "base: &base {type: string, minLength: 1}\nname: *base\nlist:\n  - &item x\n  - *item\n",
+			json: `{"base":{"type":"string","minLength":1},"name":{"type":"string","minLength":1},"list":["x","x"]}`,
+		},
+		{
+			name: "document markers",
+			yaml: "%YAML 1.2\n---\ntype: string\n...\n",
+			json: `{"type":"string"}`,
+		},
+		{
+			name: "flow collections over lines",
+			yaml: "enum: [a,\n  b, c,]\nobj: {\n  x: 1, \"y\": [],\n}\n",
+			json: `{"enum":["a","b","c"],"obj":{"x":1,"y":[]}}`,
+		},
+		{
+			name: "keys with spaces and colons",
+			yaml: "x y: 1\n\"a: b\": 2\nurl: http://example.com/a\n",
+			json: `{"x y":1,"a: b":2,"url":"http://example.com/a"}`,
+		},
+		{
+			name: "empty values",
+			yaml: "a:\nb: []\nc: {}\n",
+			json: `{"a":null,"b":[],"c":{}}`,
+		},
+	}
+	for _, c := range cases {
+		root, err := parseYAML(c.yaml)
+		if err != nil {
+			t.Errorf("%s: %v", c.name, err)
+			continue
+		}
+		data, err := nodeToJSON(root)
+		if err != nil {
+			t.Errorf("%s: %v", c.name, err)
+			continue
+		}
+		if string(data) != c.json {
+			t.Errorf("%s: got %s, want %s", c.name, data, c.json)
+		}
+	}
+}
+
+func TestParseYAMLErrors(t *testing.T) {
+	cases := []struct {
+		name   string
+		yaml   string
+		line   int
+		column int
+		msg    string
+	}{
+		{name: "duplicate key", yaml: "a: 1\na: 2\n", line: 2, column: 1, msg: "duplicate key"},
+		{name: "unknown alias", yaml: "a: *missing\n", line: 1, column: 4, msg: "missing"},
+		{name: "unclosed flow sequence", yaml: "a: [1, 2\n", line: 2, msg: "]"},
+		{name: "unclosed quote", yaml: "a: 'text\n", line: 1, column: 4, msg: "unterminated quoted scalar"},
+		{name: "several documents", yaml: "a: 1\n---\nb: 2\n", line: 2, column: 1, msg: "multiple documents"},
+		{name: "infinity", yaml: "a: .inf\n", line: 1, msg: "cannot be represented in JSON"},
+	}
+	for _, c := range cases {
+		_, err := parseYAML(c.yaml)
+		var syntaxErr *SyntaxError
+		if !errors.As(err, &syntaxErr) {
+			t.Errorf("%s: got %v, want a syntax error", c.name, err)
+			continue
+		}
+		if syntaxErr.Line != c.line || (c.column != 0 && syntaxErr.Column != c.column) || !strings.Contains(syntaxErr.Msg, c.msg) {
+			t.Errorf("%s: got %v, want line %d column %d and %q", c.name, err, c.line, c.column, c.msg)
+		}
+	}
+}
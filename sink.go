@@ -0,0 +1,257 @@
+package schema2code
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/azurity/schema2code/common"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Sink receives the files generated for a target: a single one, or with
+// CommonConfig.Split a file per type and the shared ones.
+type Sink = common.Sink
+
+// DirSink writes the files under Dir, creating the directories they need.
+type DirSink struct {
+	Dir string
+}
+
+func (s *DirSink) Create(name string) (io.WriteCloser, error) {
+	if clean := path.Clean(name); path.IsAbs(clean) || clean == ".." || strings.HasPrefix(clean, "../") {
+		return nil, errors.New(fmt.Sprintf("file name %s is outside of the output directory", name))
+	}
+	fileName := filepath.Join(s.Dir, filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(fileName), 0755); err != nil {
+		return nil, err
+	}
+	return os.Create(fileName)
+}
+
+// MemorySink keeps the files in memory, by name.
+type MemorySink map[string][]byte
+
+func (s MemorySink) Create(name string) (io.WriteCloser, error) {
+	return &memoryFile{sink: s, name: name}, nil
+}
+
+// Names returns the names of the files, sorted.
+func (s MemorySink) Names() []string {
+	names := []string{}
+	for name := range s {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+type memoryFile struct {
+	bytes.Buffer
+	sink MemorySink
+	name string
+}
+
+func (f *memoryFile) Close() error {
+	f.sink[f.name] = f.Bytes()
+	return nil
+}
+
+// ZipSink writes the files into a zip archive, which Close completes.
+type ZipSink struct {
+	writer *zip.Writer
+}
+
+func NewZipSink(writer io.Writer) *ZipSink {
+	return &ZipSink{writer: zip.NewWriter(writer)}
+}
+
+func (s *ZipSink) Create(name string) (io.WriteCloser, error) {
+	writer, err := s.writer.Create(name)
+	if err != nil {
+		return nil, err
+	}
+	return nopCloser{writer}, nil
+}
+
+func (s *ZipSink) Close() error {
+	return s.writer.Close()
+}
+
+type nopCloser struct {
+	io.Writer
+}
+
+func (nopCloser) Close() error {
+	return nil
+}
+
+// writerSink writes the single file of a target to writer.
+type writerSink struct {
+	writer io.Writer
+	used   bool
+}
+
+func (s *writerSink) Create(name string) (io.WriteCloser, error) {
+	if s.used {
+		return nil, errors.New(fmt.Sprintf("cannot write %s, the output takes a single file, use a Sink to generate several", name))
+	}
+	s.used = true
+	return nopCloser{s.writer}, nil
+}
+
+// headerSink starts every file with the header of generated files.
+type headerSink struct {
+	sink   Sink
+	header string
+}
+
+func (s *headerSink) Create(name string) (io.WriteCloser, error) {
+	file, err := s.sink.Create(name)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.WriteString(file, s.header); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return file, nil
+}
+
+// singleFile returns the only file of files.
+func singleFile(files MemorySink) ([]byte, error) {
+	if len(files) != 1 {
+		return nil, errors.New(fmt.Sprintf("%d files generated, set Split to write them to a directory", len(files)))
+	}
+	for _, data := range files {
+		return data, nil
+	}
+	return nil, nil
+}
+
+// readOutput reads the files generated at output with Split: the entries of a
+// zip archive, or the files of a directory starting with GeneratedHeader.
+// Nothing is read when output does not exist.
+func readOutput(output string) (MemorySink, error) {
+	files := MemorySink{}
+	if strings.HasSuffix(output, ".zip") {
+		archive, err := zip.OpenReader(output)
+		if errors.Is(err, os.ErrNotExist) {
+			return files, nil
+		} else if err != nil {
+			return nil, err
+		}
+		defer archive.Close()
+		for _, entry := range archive.File {
+			reader, err := entry.Open()
+			if err != nil {
+				return nil, err
+			}
+			data, err := io.ReadAll(reader)
+			reader.Close()
+			if err != nil {
+				return nil, err
+			}
+			files[entry.Name] = data
+		}
+		return files, nil
+	}
+
+	err := filepath.WalkDir(output, func(fileName string, entry fs.DirEntry, err error) error {
+		if err != nil || !entry.Type().IsRegular() {
+			return err
+		}
+		generated, err := isGenerated(fileName)
+		if err != nil || !generated {
+			return err
+		}
+		data, err := os.ReadFile(fileName)
+		if err != nil {
+			return err
+		}
+		name, err := filepath.Rel(output, fileName)
+		if err != nil {
+			return err
+		}
+		files[filepath.ToSlash(name)] = data
+		return nil
+	})
+	if errors.Is(err, os.ErrNotExist) {
+		return files, nil
+	}
+	return files, err
+}
+
+// isGenerated reports whether the first line of a file holds GeneratedHeader.
+func isGenerated(fileName string) (bool, error) {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return false, err
+	}
+	defer file.Close()
+	line, err := bufio.NewReader(io.LimitReader(file, 256)).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return false, err
+	}
+	return strings.Contains(line, GeneratedHeader), nil
+}
+
+// writeOutput writes files to output: the single file itself, or with split a
+// zip archive or a directory, where generated files left from earlier types
+// are removed. Such a directory must hold the files of no other target, as
+// LoadProject checks.
+func writeOutput(output string, split bool, files MemorySink) error {
+	if !split {
+		data, err := singleFile(files)
+		if err != nil {
+			return err
+		}
+		if err := os.MkdirAll(filepath.Dir(output), 0755); err != nil {
+			return err
+		}
+		return os.WriteFile(output, data, 0644)
+	}
+
+	if strings.HasSuffix(output, ".zip") {
+		if err := os.MkdirAll(filepath.Dir(output), 0755); err != nil {
+			return err
+		}
+		buffer := &bytes.Buffer{}
+		archive := NewZipSink(buffer)
+		for _, name := range files.Names() {
+			if err := common.WriteFile(archive, name, files[name]); err != nil {
+				return err
+			}
+		}
+		if err := archive.Close(); err != nil {
+			return err
+		}
+		return os.WriteFile(output, buffer.Bytes(), 0644)
+	}
+
+	current, err := readOutput(output)
+	if err != nil {
+		return err
+	}
+	dir := &DirSink{Dir: output}
+	for _, name := range files.Names() {
+		if err := common.WriteFile(dir, name, files[name]); err != nil {
+			return err
+		}
+	}
+	for _, name := range current.Names() {
+		if _, ok := files[name]; !ok {
+			if err := os.Remove(filepath.Join(output, filepath.FromSlash(name))); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
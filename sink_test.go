@@ -0,0 +1,102 @@
+package schema2code
+
+import (
+	"archive/zip"
+	"bytes"
+	"github.com/azurity/schema2code/common"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMemorySink(t *testing.T) {
+	sink := MemorySink{}
+	for _, name := range []string{"b.go", "a/c.go"} {
+		if err := common.WriteFile(sink, name, []byte(name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if names := strings.Join(sink.Names(), " "); names != "a/c.go b.go" {
+		t.Errorf("got names %s", names)
+	}
+	if string(sink["a/c.go"]) != "a/c.go" {
+		t.Errorf("got content %q", sink["a/c.go"])
+	}
+}
+
+func TestDirSink(t *testing.T) {
+	dir := t.TempDir()
+	sink := &DirSink{Dir: filepath.Join(dir, "out")}
+	if err := common.WriteFile(sink, "a/b.go", []byte("b")); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := os.ReadFile(filepath.Join(dir, "out", "a", "b.go")); err != nil || string(data) != "b" {
+		t.Errorf("got %q, %v", data, err)
+	}
+	for _, name := range []string{"../a.go", "a/../../b.go", "/etc/a.go", ".."} {
+		if err := common.WriteFile(sink, name, nil); err == nil || !strings.Contains(err.Error(), "outside of the output directory") {
+			t.Errorf("%s: got error %v", name, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "a.go")); err == nil {
+		t.Errorf("a file is written outside of the output directory")
+	}
+}
+
+func TestZipSink(t *testing.T) {
+	buffer := &bytes.Buffer{}
+	sink := NewZipSink(buffer)
+	if err := common.WriteFile(sink, "a/b.go", []byte("b")); err != nil {
+		t.Fatal(err)
+	}
+	if err := sink.Close(); err != nil {
+		t.Fatal(err)
+	}
+	archive, err := zip.NewReader(bytes.NewReader(buffer.Bytes()), int64(buffer.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(archive.File) != 1 || archive.File[0].Name != "a/b.go" {
+		t.Fatalf("got entries %v", archive.File)
+	}
+	reader, err := archive.File[0].Open()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+	if data, err := io.ReadAll(reader); err != nil || string(data) != "b" {
+		t.Errorf("got %q, %v", data, err)
+	}
+}
+
+func TestWriteOutput(t *testing.T) {
+	output := filepath.Join(t.TempDir(), "gen")
+	generated := "// " + GeneratedHeader + "\n"
+	files := MemorySink{"a.go": []byte(generated + "a"), "b.go": []byte(generated + "b")}
+	if err := writeOutput(output, true, files); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(output, "own.go"), []byte("package gen\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeOutput(output, true, MemorySink{"a.go": []byte(generated + "a2")}); err != nil {
+		t.Fatal(err)
+	}
+	entries, err := os.ReadDir(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := []string{}
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	if strings.Join(names, " ") != "a.go own.go" {
+		t.Errorf("got files %v, want the generated file left removed and the others kept", names)
+	}
+
+	if err := writeOutput(filepath.Join(output, "single.go"), false, files); err == nil {
+		t.Errorf("several files are written to a single output")
+	}
+}
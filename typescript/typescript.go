@@ -6,7 +6,7 @@ import (
 	"errors"
 	"fmt"
 	"github.com/azurity/schema2code/common"
-	"github.com/azurity/schema2code/schemas"
+	"github.com/azurity/schema2code/ir"
 	"io"
 	"sort"
 	"strings"
@@ -15,6 +15,9 @@ import (
 //go:embed helper_ts
 var helperCode []byte
 
+//go:embed checker_ts
+var checkerCode []byte
+
 type TypescriptConfig struct {
 	common.CommonConfig
 	//Package string
@@ -22,6 +25,12 @@ type TypescriptConfig struct {
 
 type Context struct {
 	regexCounter uint64
+	names        map[*ir.Type]string
+	split        bool
+	// refs, imports and helpers are used by the type being generated.
+	refs    map[*ir.Type]bool
+	imports map[string]map[string]bool
+	helpers map[string]bool
 }
 
 type Path struct {
@@ -34,108 +43,91 @@ func validationError(writer *common.CodeWriter, reason string) {
 	// TODO: add more log here
 }
 
+// writeDeprecated marks the declaration written next as deprecated when the schema does.
+func writeDeprecated(writer *common.CodeWriter, node *ir.Node) {
+	if node == nil || !node.Deprecated {
+		return
+	}
+	writer.Write("/** @deprecated */")
+	writer.CommonLine()
+}
+
 func formatName(name string) string {
-	snake := strings.ReplaceAll(name, "-", "_")
-	if snake == "" {
-		return snake
+	return common.Identifier(name)
+}
+
+// checkNames fails when two generated types are given the same name.
+func checkNames(ctx *Context, module *ir.Module) error {
+	seen := map[string]*ir.Type{}
+	for _, value := range module.Types {
+		if value.Override != nil {
+			continue
+		}
+		if other, ok := seen[ctx.names[value]]; ok {
+			return errors.New(fmt.Sprintf("types %s and %s are both named %s", other.Name, value.Name, ctx.names[value]))
+		}
+		seen[ctx.names[value]] = value
 	}
-	return strings.ToUpper(snake[:1]) + snake[1:]
+	return nil
 }
 
-func generateNull(ctx *Context, path *Path, desc *schemas.Type, writer *common.CodeWriter, globalCode *common.CodeWriter, validationCode *common.CodeWriter) (bool, error) {
+func bound(value *ir.Bound) (float64, bool, bool) {
+	if value == nil {
+		return 0, false, false
+	}
+	return value.Value, value.Exclusive, true
+}
+
+func generateNull(ctx *Context, path *Path, node *ir.Node, writer *common.CodeWriter, globalCode *common.CodeWriter, validationCode *common.CodeWriter) (bool, error) {
 	writer.Write("null")
 	return true, nil
 }
 
-func generateBoolean(ctx *Context, path *Path, desc *schemas.Type, writer *common.CodeWriter, globalCode *common.CodeWriter, validationCode *common.CodeWriter) (bool, error) {
+func generateBoolean(ctx *Context, path *Path, node *ir.Node, writer *common.CodeWriter, globalCode *common.CodeWriter, validationCode *common.CodeWriter) (bool, error) {
 	writer.Write("boolean")
 	return true, nil
 }
 
-func generateInteger(ctx *Context, path *Path, desc *schemas.Type, writer *common.CodeWriter, globalCode *common.CodeWriter, validationCode *common.CodeWriter) (bool, error) {
+func generateNumeric(ctx *Context, path *Path, node *ir.Node, helper string, reason string, writer *common.CodeWriter, validationCode *common.CodeWriter) (bool, error) {
 	writer.Write("number")
-	mini := float64(0)
-	maxi := float64(0)
-	hasMini := false
-	hasMaxi := false
-	exMini := false
-	exMaxi := false
-	if desc.Minimum != nil {
-		hasMini = true
-		mini = *desc.Minimum
-		if desc.ExclusiveMinimum != nil {
-			exMini = *desc.ExclusiveMinimum
-		}
-	}
-	if desc.Maximum != nil {
-		hasMaxi = true
-		maxi = *desc.Maximum
-		if desc.ExclusiveMaximum != nil {
-			exMaxi = *desc.ExclusiveMaximum
-		}
-	}
-	multiple := 1
-	useMultiple := false
-	if desc.MultipleOf != nil {
-		useMultiple = true
-		multiple = *desc.MultipleOf
-	}
-	if hasMini || hasMaxi || useMultiple {
+	mini, exMini, hasMini := bound(node.Minimum)
+	maxi, exMaxi, hasMaxi := bound(node.Maximum)
+	multiples := node.MultipleOf
+	if len(multiples) == 0 {
+		multiples = []float64{1}
+	}
+	for i, multiple := range multiples {
+		useMultiple := len(node.MultipleOf) != 0
+		if i != 0 {
+			// the bounds are checked with the first multiple
+			hasMini, hasMaxi = false, false
+		}
+		if !(hasMini || hasMaxi || useMultiple) {
+			continue
+		}
+		ctx.helpers[helper] = true
 		validationCode.CommonLine()
 		validationCode.Write("if (!")
-		validationCode.Write(fmt.Sprintf("integerValidation(%g, %g, %t, %t, %t, %t, %d, %t, %s)", mini, maxi, hasMini, hasMaxi, exMini, exMaxi, multiple, useMultiple, strings.Join(path.namedPath, "")))
+		validationCode.Write(fmt.Sprintf("%s(%g, %g, %t, %t, %t, %t, %g, %t, %s)", helper, mini, maxi, hasMini, hasMaxi, exMini, exMaxi, multiple, useMultiple, strings.Join(path.namedPath, "")))
 		validationCode.Write(") {")
 		validationCode.Indent()
-		validationError(validationCode, "integer check failed")
+		validationError(validationCode, reason)
 		validationCode.Dedent()
 		validationCode.Write("}")
 	}
-	return !(hasMini || hasMaxi || useMultiple), nil
+	return !(node.Minimum != nil || node.Maximum != nil || len(node.MultipleOf) != 0), nil
 }
 
-func generateNumber(ctx *Context, path *Path, desc *schemas.Type, writer *common.CodeWriter, globalCode *common.CodeWriter, validationCode *common.CodeWriter) (bool, error) {
-	writer.Write("number")
-	mini := float64(0)
-	maxi := float64(0)
-	hasMini := false
-	hasMaxi := false
-	exMini := false
-	exMaxi := false
-	if desc.Minimum != nil {
-		hasMini = true
-		mini = *desc.Minimum
-		if desc.ExclusiveMinimum != nil {
-			exMini = *desc.ExclusiveMinimum
-		}
-	}
-	if desc.Maximum != nil {
-		hasMaxi = true
-		maxi = *desc.Maximum
-		if desc.ExclusiveMaximum != nil {
-			exMaxi = *desc.ExclusiveMaximum
-		}
-	}
-	multiple := 1
-	useMultiple := false
-	if desc.MultipleOf != nil {
-		useMultiple = true
-		multiple = *desc.MultipleOf
-	}
-	if hasMini || hasMaxi || useMultiple {
-		validationCode.CommonLine()
-		validationCode.Write("if (!")
-		validationCode.Write(fmt.Sprintf("numberValidation(%g, %g, %t, %t, %t, %t, %d, %t, %s)", mini, maxi, hasMini, hasMaxi, exMini, exMaxi, multiple, useMultiple, strings.Join(path.namedPath, "")))
-		validationCode.Write(") {")
-		validationCode.Indent()
-		validationError(validationCode, "number check failed")
-		validationCode.Dedent()
-		validationCode.Write("}")
-	}
-	return !(hasMini || hasMaxi || useMultiple), nil
+func generateInteger(ctx *Context, path *Path, node *ir.Node, writer *common.CodeWriter, globalCode *common.CodeWriter, validationCode *common.CodeWriter) (bool, error) {
+	return generateNumeric(ctx, path, node, "integerValidation", "integer check failed", writer, validationCode)
+}
+
+func generateNumber(ctx *Context, path *Path, node *ir.Node, writer *common.CodeWriter, globalCode *common.CodeWriter, validationCode *common.CodeWriter) (bool, error) {
+	return generateNumeric(ctx, path, node, "numberValidation", "number check failed", writer, validationCode)
 }
 
-func generateString(ctx *Context, path *Path, desc *schemas.Type, writer *common.CodeWriter, globalCode *common.CodeWriter, validationCode *common.CodeWriter) (bool, error) {
-	if desc.Format != nil {
+func generateString(ctx *Context, path *Path, node *ir.Node, writer *common.CodeWriter, globalCode *common.CodeWriter, validationCode *common.CodeWriter) (bool, error) {
+	if node.Format != nil {
 		// TODO:
 	}
 	writer.Write("string")
@@ -143,16 +135,17 @@ func generateString(ctx *Context, path *Path, desc *schemas.Type, writer *common
 	maxLen := 0
 	useMinLength := false
 	useMaxLength := false
-	if desc.MinLength != nil {
+	if node.MinLength != nil {
 		useMinLength = true
-		minLen = *desc.MinLength
+		minLen = *node.MinLength
 	}
-	if desc.MaxLength != nil {
+	if node.MaxLength != nil {
 		useMaxLength = true
-		maxLen = *desc.MaxLength
+		maxLen = *node.MaxLength
 	}
 	stringName := strings.Join(path.namedPath, "")
 	if useMinLength || useMaxLength {
+		ctx.helpers["stringValidation"] = true
 		validationCode.CommonLine()
 		validationCode.Write("if (!")
 		validationCode.Write(fmt.Sprintf("stringValidation(%d, %d, %t, %t, %s)", minLen, maxLen, useMinLength, useMaxLength, stringName))
@@ -162,55 +155,63 @@ func generateString(ctx *Context, path *Path, desc *schemas.Type, writer *common
 		validationCode.Dedent()
 		validationCode.Write("}")
 	}
-	if desc.Pattern != nil {
+	for _, pattern := range node.Pattern {
 		validationCode.CommonLine()
-		validationCode.Write("if (!")
-		validationCode.Write(fmt.Sprintf("/%s/.test(%s)", *desc.Pattern, stringName))
+		validationCode.Write(fmt.Sprintf("if (%s !== undefined && !", stringName))
+		validationCode.Write(fmt.Sprintf("/%s/.test(%s)", pattern, stringName))
 		validationCode.Write(") {")
 		validationCode.Indent()
 		validationError(validationCode, "string check pattern failed")
 		validationCode.Dedent()
 		validationCode.Write("}")
 	}
-	return !(useMinLength || useMaxLength || desc.Pattern != nil), nil
+	if node.Enum != nil {
+		quoted := []string{}
+		for _, value := range node.Enum {
+			quoted = append(quoted, fmt.Sprintf("%q", value))
+		}
+		validationCode.CommonLine()
+		validationCode.Write(fmt.Sprintf("if (%s !== undefined && [%s].indexOf(%s) < 0) {", stringName, strings.Join(quoted, ", "), stringName))
+		validationCode.Indent()
+		validationError(validationCode, "wrong enum value")
+		validationCode.Dedent()
+		validationCode.Write("}")
+	}
+	return !(useMinLength || useMaxLength || len(node.Pattern) != 0 || node.Enum != nil), nil
 }
 
-func generateArray(ctx *Context, path *Path, desc *schemas.Type, writer *common.CodeWriter, globalCode *common.CodeWriter, validationCode *common.CodeWriter) (bool, error) {
-	if desc.AdditionalItems != nil {
-		return false, errors.New("only support single type array")
-	}
-	if desc.Items == nil {
-		return false, errors.New("array must have item type")
-	}
+func generateArray(ctx *Context, path *Path, node *ir.Node, writer *common.CodeWriter, globalCode *common.CodeWriter, validationCode *common.CodeWriter) (bool, error) {
 	arrayName := strings.Join(path.namedPath, "")
 	validationCode.CommonLine()
 	validationCode.Write(fmt.Sprintf("if (%s !== undefined) {", arrayName))
 	validationCode.Indent()
 
-	if desc.MinItems != nil || desc.MaxItems != nil {
+	hasLength := node.MinItems != nil || node.MaxItems != nil || node.UniqueItems
+	if hasLength {
 		mini := 0
 		maxi := 0
-		if desc.MaxItems != nil {
-			mini = *desc.MinItems
+		if node.MinItems != nil {
+			mini = *node.MinItems
 		}
-		if desc.MaxItems != nil {
-			maxi = *desc.MaxItems
+		if node.MaxItems != nil {
+			maxi = *node.MaxItems
 		}
+		ctx.helpers["arrayValidation"] = true
 		validationCode.Write("if (!")
-		validationCode.Write(fmt.Sprintf("arrayValidation(%d, %d, %t, %t, %t, %s)", mini, maxi, desc.MinItems != nil, desc.MaxItems != nil, desc.UniqueItems, arrayName))
+		validationCode.Write(fmt.Sprintf("arrayValidation(%d, %d, %t, %t, %t, %s)", mini, maxi, node.MinItems != nil, node.MaxItems != nil, node.UniqueItems, arrayName))
 		validationCode.Write(") {")
 		validationCode.Indent()
 		validationError(validationCode, "array check failed")
 		validationCode.Dedent()
 		validationCode.Write("}")
+		validationCode.CommonLine()
 	}
 
 	validationCode.Write(fmt.Sprintf("for (let item of %s) {", arrayName))
 	validationCode.Indent()
 	ignore, err := generateType(ctx, &Path{
 		namedPath: []string{"item"},
-		//quotePath: append(append([]string{}, path.quotePath...), "index"),
-	}, desc.Items, writer, globalCode, validationCode)
+	}, node.Items, writer, globalCode, validationCode)
 	writer.Write("[]")
 	if err != nil {
 		return false, err
@@ -219,52 +220,154 @@ func generateArray(ctx *Context, path *Path, desc *schemas.Type, writer *common.
 	validationCode.Write("}")
 	validationCode.Dedent()
 	validationCode.Write("}")
-	return ignore && !(desc.MinItems != nil || desc.MaxItems != nil), nil
+	return ignore && !hasLength, nil
 }
 
-type sortableKV struct {
-	key   string
-	value interface{}
+// generateMap writes a dictionary as an index signature, checking each value.
+func generateMap(ctx *Context, path *Path, node *ir.Node, writer *common.CodeWriter, globalCode *common.CodeWriter, validationCode *common.CodeWriter) (bool, error) {
+	mapName := strings.Join(path.namedPath, "")
+	writer.Write("{ [key: string]: ")
+	validationCode.CommonLine()
+	validationCode.Write(fmt.Sprintf("if (%s !== undefined) {", mapName))
+	validationCode.Indent()
+	validationCode.Write(fmt.Sprintf("for (let item of Object.values(%s)) {", mapName))
+	validationCode.Indent()
+	ignore, err := generateType(ctx, &Path{
+		namedPath: []string{"item"},
+	}, node.AdditionalProperties, writer, globalCode, validationCode)
+	if err != nil {
+		return false, err
+	}
+	writer.Write(" }")
+	validationCode.Dedent()
+	validationCode.Write("}")
+	validationCode.Dedent()
+	validationCode.Write("}")
+	return ignore, nil
 }
 
-type sortKV []sortableKV
+// generateExtraProperties checks the properties of an object which are not
+// declared against the pattern they match, else as additional properties,
+// telling like generateType whether nothing is checked.
+func generateExtraProperties(ctx *Context, path *Path, node *ir.Node, globalCode *common.CodeWriter, validationCode *common.CodeWriter) (bool, error) {
+	// the values are checked only, their types are not declared
+	discard := &common.CodeWriter{
+		Writer: io.Discard,
+		Tab:    "    ",
+	}
+	ignored := func(value *ir.Node) (bool, error) {
+		return generateType(ctx, &Path{
+			namedPath: []string{"item"},
+		}, value, discard, globalCode, discard)
+	}
+	checkKnown := node.Closed
+	if node.AdditionalProperties != nil {
+		ignore, err := ignored(node.AdditionalProperties)
+		if err != nil {
+			return false, err
+		}
+		checkKnown = !ignore
+	}
+	statements := []func() error{}
+	for _, property := range node.PatternProperties {
+		property := property
+		ignore, err := ignored(property.Node)
+		if err != nil {
+			return false, err
+		}
+		if ignore && !checkKnown {
+			continue
+		}
+		statements = append(statements, func() error {
+			validationCode.Write(fmt.Sprintf("if (/%s/.test(key)) {", property.Pattern))
+			validationCode.Indent()
+			if checkKnown {
+				validationCode.Write("known = true;")
+			}
+			_, err := generateType(ctx, &Path{
+				namedPath: []string{"item"},
+			}, property.Node, discard, globalCode, validationCode)
+			if err != nil {
+				return err
+			}
+			validationCode.Dedent()
+			validationCode.Write("}")
+			return nil
+		})
+	}
+	if checkKnown {
+		statements = append(statements, func() error {
+			validationCode.Write("if (!known) {")
+			validationCode.Indent()
+			if node.Closed {
+				validationCode.Write("throw new Error(\"unknown property \" + key);")
+			} else {
+				_, err := generateType(ctx, &Path{
+					namedPath: []string{"item"},
+				}, node.AdditionalProperties, discard, globalCode, validationCode)
+				if err != nil {
+					return err
+				}
+			}
+			validationCode.Dedent()
+			validationCode.Write("}")
+			return nil
+		})
+	}
+	if len(statements) == 0 {
+		return true, nil
+	}
 
-func (a sortKV) Len() int           { return len(a) }
-func (a sortKV) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
-func (a sortKV) Less(i, j int) bool { return a[i].key < a[j].key }
+	validationCode.CommonLine()
+	validationCode.Write(fmt.Sprintf("for (let [key, item] of Object.entries(%s)) {", strings.Join(path.namedPath, "")))
+	validationCode.Indent()
+	if len(node.Properties) != 0 {
+		declared := []string{}
+		for _, property := range node.Properties {
+			declared = append(declared, fmt.Sprintf("key === \"%s\"", property.Name))
+		}
+		validationCode.Write(fmt.Sprintf("if (%s) continue;", strings.Join(declared, " || ")))
+		validationCode.CommonLine()
+	}
+	if checkKnown {
+		validationCode.Write("let known = false;")
+		validationCode.CommonLine()
+	}
+	for i, statement := range statements {
+		if i != 0 {
+			validationCode.CommonLine()
+		}
+		if err := statement(); err != nil {
+			return false, err
+		}
+	}
+	validationCode.Dedent()
+	validationCode.Write("}")
+	return false, nil
+}
 
-func generateObject(ctx *Context, path *Path, desc *schemas.Type, writer *common.CodeWriter, globalCode *common.CodeWriter, validationCode *common.CodeWriter) (bool, error) {
+func generateObject(ctx *Context, path *Path, node *ir.Node, writer *common.CodeWriter, globalCode *common.CodeWriter, validationCode *common.CodeWriter) (bool, error) {
+	if node.IsMap() {
+		return generateMap(ctx, path, node, writer, globalCode, validationCode)
+	}
 	writer.Write("{")
 	writer.Indent()
 
 	globalIgnore := true
 
-	required := desc.Required
-	if required == nil {
-		required = []string{}
-	}
-
+	objectName := strings.Join(path.namedPath, "")
 	validationCode.CommonLine()
+	validationCode.Write(fmt.Sprintf("if (%s !== undefined) {", objectName))
+	validationCode.Indent()
 
-	sorted := sortKV{}
-	for name, value := range desc.Properties {
-		sorted = append(sorted, sortableKV{name, value})
-	}
-	sort.Sort(sorted)
-
-	for _, iter := range sorted {
-		name := iter.key
-		value := iter.value.(*schemas.Type)
+	for _, property := range node.Properties {
+		name := property.Name
 		propOptional := "?"
-		for _, item := range required {
-			if name == item {
-				propOptional = ""
-				break
-			}
-		}
-
-		if propOptional == "" {
-			validationCode.Write(fmt.Sprintf("if (%s.%s === undefiend) {", strings.Join(path.namedPath, ""), formatName(name)))
+		propertyPath := append(append([]string{}, path.namedPath...), "[\""+name+"\"]")
+		if property.Required {
+			propOptional = ""
+			validationCode.CommonLine()
+			validationCode.Write(fmt.Sprintf("if (%s === undefined) {", strings.Join(propertyPath, "")))
 			validationCode.Indent()
 			validationError(validationCode, "member cannot be undefined")
 			validationCode.Dedent()
@@ -272,108 +375,425 @@ func generateObject(ctx *Context, path *Path, desc *schemas.Type, writer *common
 		}
 
 		writer.CommonLine()
+		if property.Node.Deprecated {
+			writer.Write("/** @deprecated */ ")
+		}
+		if property.Node.ReadOnly {
+			writer.Write("readonly ")
+		}
 		writer.Write(fmt.Sprintf("\"%s\"%s: ", name, propOptional))
 		ignore, err := generateType(ctx, &Path{
-			namedPath: append(append([]string{}, path.namedPath...), "[\""+name+"\"]"),
-			//quotePath: append(append([]string{}, path.quotePath...), "\""+name+"\""),
-		}, value, writer, globalCode, validationCode)
+			namedPath: propertyPath,
+		}, property.Node, writer, globalCode, validationCode)
 		if err != nil {
 			return false, err
 		}
 
 		globalIgnore = globalIgnore && ignore
 	}
+	if node.HasExtraProperties() {
+		ignore, err := generateExtraProperties(ctx, path, node, globalCode, validationCode)
+		if err != nil {
+			return false, err
+		}
+		globalIgnore = globalIgnore && ignore
+	}
+
+	validationCode.Dedent()
+	validationCode.Write("}")
 
 	writer.Dedent()
 	writer.Write("}")
 	return globalIgnore, nil
 }
 
+// typeGuard returns a condition telling whether value is of the kind of node,
+// "" when anything may be.
+func typeGuard(node *ir.Node, value string) string {
+	switch node.Kind {
+	case ir.KindRef:
+		target := node.Ref
+		for target.Alias != nil {
+			target = target.Alias
+		}
+		if target.Node == nil {
+			return ""
+		}
+		return typeGuard(target.Node, value)
+	case ir.KindNull:
+		return fmt.Sprintf("%s === null", value)
+	case ir.KindBoolean:
+		return fmt.Sprintf("typeof %s === \"boolean\"", value)
+	case ir.KindInteger:
+		return fmt.Sprintf("Number.isInteger(%s)", value)
+	case ir.KindNumber:
+		return fmt.Sprintf("typeof %s === \"number\"", value)
+	case ir.KindString:
+		return fmt.Sprintf("typeof %s === \"string\"", value)
+	case ir.KindArray:
+		return fmt.Sprintf("Array.isArray(%s)", value)
+	case ir.KindObject:
+		return fmt.Sprintf("typeof %s === \"object\" && %s !== null && !Array.isArray(%s)", value, value, value)
+	default:
+		return ""
+	}
+}
+
+// variantNames names the variants of a union after their types, for errors.
+func variantNames(ctx *Context, node *ir.Node) []string {
+	names := []string{}
+	for _, variant := range node.Variants {
+		if variant.Kind == ir.KindRef {
+			names = append(names, ctx.names[variant.Ref])
+		} else {
+			names = append(names, variant.Kind.String())
+		}
+	}
+	return names
+}
+
+// generateUnion writes the variants of a union, checked as the one its
+// discriminator names, or else as each of them in turn.
+func generateUnion(ctx *Context, path *Path, node *ir.Node, writer *common.CodeWriter, globalCode *common.CodeWriter, validationCode *common.CodeWriter) (bool, error) {
+	unionName := strings.Join(path.namedPath, "")
+	if node.Discriminator == nil {
+		return generateTrialUnion(ctx, path, node, writer, globalCode, validationCode)
+	}
+	validationCode.CommonLine()
+	validationCode.Write(fmt.Sprintf("if (%s !== undefined) {", unionName))
+	validationCode.Indent()
+	validationCode.Write(fmt.Sprintf("switch (%s[\"%s\"]) {", unionName, node.Discriminator.Property))
+	for i, variant := range node.Variants {
+		if i != 0 {
+			writer.Write(" | ")
+		}
+		for j, value := range node.Discriminator.Values[i] {
+			// the previous case ends with a new line already
+			if i == 0 || j != 0 {
+				validationCode.CommonLine()
+			}
+			validationCode.Write(fmt.Sprintf("case \"%s\":", value))
+		}
+		validationCode.Indent()
+		_, err := generateType(ctx, path, variant, writer, globalCode, validationCode)
+		if err != nil {
+			return false, err
+		}
+		validationCode.CommonLine()
+		validationCode.Write("break;")
+		validationCode.Dedent()
+	}
+	validationCode.Write("default:")
+	validationCode.Indent()
+	validationError(validationCode, fmt.Sprintf("unknown value of discriminator %s", node.Discriminator.Property))
+	validationCode.Dedent()
+	validationCode.Write("}")
+	validationCode.Dedent()
+	validationCode.Write("}")
+	return false, nil
+}
+
+// generateTrialUnion checks a value against each variant, until one passes
+// or, for an exclusive union, to make sure only one does.
+func generateTrialUnion(ctx *Context, path *Path, node *ir.Node, writer *common.CodeWriter, globalCode *common.CodeWriter, validationCode *common.CodeWriter) (bool, error) {
+	unionName := strings.Join(path.namedPath, "")
+	names := variantNames(ctx, node)
+	validationCode.CommonLine()
+	validationCode.Write(fmt.Sprintf("if (%s !== undefined) {", unionName))
+	validationCode.Indent()
+	validationCode.Write("const $matched: string[] = [];")
+	validationCode.CommonLine()
+	validationCode.Write("const $failures: string[] = [];")
+	for i, variant := range node.Variants {
+		if i != 0 {
+			writer.Write(" | ")
+		}
+		validationCode.CommonLine()
+		if node.Exclusive {
+			validationCode.Write("try {")
+		} else {
+			validationCode.Write("if ($matched.length === 0) try {")
+		}
+		validationCode.Indent()
+		if guard := typeGuard(variant, unionName); guard != "" {
+			validationCode.Write(fmt.Sprintf("if (!(%s)) {", guard))
+			validationCode.Indent()
+			validationError(validationCode, fmt.Sprintf("wrong type, expected %s", variant.Kind.String()))
+			validationCode.Dedent()
+			validationCode.Write("}")
+		}
+		_, err := generateType(ctx, path, variant, writer, globalCode, validationCode)
+		if err != nil {
+			return false, err
+		}
+		validationCode.CommonLine()
+		validationCode.Write(fmt.Sprintf("$matched.push(\"%s\");", names[i]))
+		validationCode.Dedent()
+		validationCode.Write("} catch (e) {")
+		validationCode.Indent()
+		validationCode.Write(fmt.Sprintf("$failures.push(\"%s: \" + (e as Error).message);", names[i]))
+		validationCode.Dedent()
+		validationCode.Write("}")
+	}
+	validationCode.CommonLine()
+	validationCode.Write("if ($matched.length === 0) {")
+	validationCode.Indent()
+	validationCode.Write("throw new Error(\"no variant matches: \" + $failures.join(\"; \"));")
+	validationCode.Dedent()
+	validationCode.Write("}")
+	if node.Exclusive {
+		validationCode.CommonLine()
+		validationCode.Write("if ($matched.length > 1) {")
+		validationCode.Indent()
+		validationCode.Write("throw new Error(\"several variants match: \" + $matched.join(\", \"));")
+		validationCode.Dedent()
+		validationCode.Write("}")
+	}
+	validationCode.Dedent()
+	validationCode.Write("}")
+	return false, nil
+}
+
 // ignore value & error
-func generateType(ctx *Context, path *Path, desc *schemas.Type, writer *common.CodeWriter, globalCode *common.CodeWriter, validationCode *common.CodeWriter) (bool, error) {
-	if desc == nil {
-		return false, errors.New("must define type impl")
-	}
-	if desc.Ref != nil {
-		parts := strings.Split(*desc.Ref, "/")
-		if parts[0] != "#" {
-			return false, errors.New("only local $ref is support")
-		}
-		parts = parts[1:]
-		realName := []string{}
-		for i, item := range parts {
-			if i%2 != 0 {
-				realName = append(realName, formatName(item))
-			} else {
-				if item != "$defs" && item != "definitions" {
-					return false, errors.New("wrong $ref format")
+func generateType(ctx *Context, path *Path, node *ir.Node, writer *common.CodeWriter, globalCode *common.CodeWriter, validationCode *common.CodeWriter) (bool, error) {
+	// TODO: impl enum here
+	switch node.Kind {
+	case ir.KindRef:
+		realName := ctx.names[node.Ref]
+		writer.Write(realName)
+		if node.Ref.Override != nil {
+			if node.Ref.Override.Import != "" {
+				if ctx.imports[node.Ref.Override.Import] == nil {
+					ctx.imports[node.Ref.Override.Import] = map[string]bool{}
 				}
+				ctx.imports[node.Ref.Override.Import][realName] = true
 			}
+			return true, nil
 		}
-		writer.Write(strings.Join(realName, ""))
 
+		ctx.refs[node.Ref] = true
 		validationCode.CommonLine()
-		validationCode.Write(fmt.Sprintf("if ($checkTable[\"%s\"] !== undeifned) $checkTable[\"%s\"](%s);", strings.Join(realName, ""), strings.Join(realName, ""), strings.Join(path.namedPath, "")))
+		if ctx.split {
+			validationCode.Write(fmt.Sprintf("$check%s(%s);", realName, strings.Join(path.namedPath, "")))
+		} else {
+			validationCode.Write(fmt.Sprintf("if ($checkTable[\"%s\"] !== undefined) $checkTable[\"%s\"](%s);", realName, realName, strings.Join(path.namedPath, "")))
+		}
 
 		return true, nil
+	case ir.KindNull:
+		return generateNull(ctx, path, node, writer, globalCode, validationCode)
+	case ir.KindBoolean:
+		return generateBoolean(ctx, path, node, writer, globalCode, validationCode)
+	case ir.KindInteger:
+		return generateInteger(ctx, path, node, writer, globalCode, validationCode)
+	case ir.KindNumber:
+		return generateNumber(ctx, path, node, writer, globalCode, validationCode)
+	case ir.KindString:
+		return generateString(ctx, path, node, writer, globalCode, validationCode)
+	case ir.KindArray:
+		return generateArray(ctx, path, node, writer, globalCode, validationCode)
+	case ir.KindUnion:
+		return generateUnion(ctx, path, node, writer, globalCode, validationCode)
+	default:
+		return generateObject(ctx, path, node, writer, globalCode, validationCode)
 	}
-	if len(desc.Type) != 1 {
-		// TODO: try union later by use interface{}
-		return false, errors.New("multiple type is not supported")
+}
+
+// declaration is the code generated for a type.
+type declaration struct {
+	code []byte
+	// enum is set for string enums, checked against their values.
+	enum bool
+	// check is the body of the check function of other types, nil when there
+	// is nothing to check.
+	check []byte
+}
+
+// generateDeclaration generates value, recording what it uses in ctx.
+func generateDeclaration(ctx *Context, value *ir.Type) (*declaration, error) {
+	ctx.refs = map[*ir.Type]bool{}
+	ctx.imports = map[string]map[string]bool{}
+	ctx.helpers = map[string]bool{}
+	renderedName := ctx.names[value]
+
+	fileBuffer := &bytes.Buffer{}
+	fileWriter := &common.CodeWriter{
+		Writer: fileBuffer,
+		Tab:    "    ",
 	}
-	// TODO: impl enum here
-	switch desc.Type[0] {
-	case schemas.TypeNameNull:
-		ign, err := generateNull(ctx, path, desc, writer, globalCode, validationCode)
-		return ign, err
-	case schemas.TypeNameBoolean:
-		ign, err := generateBoolean(ctx, path, desc, writer, globalCode, validationCode)
-		return ign, err
-	case schemas.TypeNameInteger:
-		ign, err := generateInteger(ctx, path, desc, writer, globalCode, validationCode)
-		return ign, err
-	case schemas.TypeNameNumber:
-		ign, err := generateNumber(ctx, path, desc, writer, globalCode, validationCode)
-		return ign, err
-	case schemas.TypeNameString:
-		ign, err := generateString(ctx, path, desc, writer, globalCode, validationCode)
-		return ign, err
-	case schemas.TypeNameArray:
-		ign, err := generateArray(ctx, path, desc, writer, globalCode, validationCode)
-		return ign, err
-	case schemas.TypeNameObject:
-		ign, err := generateObject(ctx, path, desc, writer, globalCode, validationCode)
-		return ign, err
-	default:
-		return false, errors.New(fmt.Sprintf("unknown type %s", desc.Type[0]))
+	target := value.Alias
+	if target == nil && value.Node.Kind == ir.KindRef {
+		target = value.Node.Ref
+	}
+	if target != nil {
+		// checked like a reference to the type it stands for
+		validationBuffer := &bytes.Buffer{}
+		validationWriter := &common.CodeWriter{
+			Writer: validationBuffer,
+			Tab:    "    ",
+		}
+		validationWriter.Indent()
+		fileWriter.CommonLine()
+		writeDeprecated(fileWriter, value.Node)
+		fileWriter.Write(fmt.Sprintf("export type %s = ", renderedName))
+		generateType(ctx, &Path{
+			namedPath: []string{"main"},
+		}, &ir.Node{Kind: ir.KindRef, Ref: target}, fileWriter, fileWriter, validationWriter)
+		if target.Node != nil && target.Node.Enum != nil {
+			fileWriter.CommonLine()
+			fileWriter.Write(fmt.Sprintf("export const %s = %s;", renderedName, ctx.names[target]))
+		}
+		fileWriter.CommonLine()
+		return &declaration{code: fileBuffer.Bytes(), check: validationBuffer.Bytes()}, nil
 	}
+	if value.Node.Enum != nil && value.Node.Kind == ir.KindString {
+		fileWriter.CommonLine()
+		if a, b, ok := common.IdentifierClash(value.Node.Enum); ok {
+			return nil, errors.New(fmt.Sprintf("values %q and %q of %s are both named %s", a, b, value.Name, formatName(a)))
+		}
+		writeDeprecated(fileWriter, value.Node)
+		fileWriter.Write(fmt.Sprintf("export enum %s {", renderedName))
+		fileWriter.Indent()
+		for _, item := range value.Node.Enum {
+			fileWriter.CommonLine()
+			fileWriter.Write(fmt.Sprintf("%s = \"%s\",", formatName(item), item))
+		}
+		fileWriter.Dedent()
+		fileWriter.Write("}")
+		fileWriter.CommonLine()
+
+		return &declaration{code: fileBuffer.Bytes(), enum: true}, nil
+	}
+
+	typeBuffer := &bytes.Buffer{}
+	typeWriter := &common.CodeWriter{
+		Writer: typeBuffer,
+		Tab:    "    ",
+	}
+	validationBuffer := &bytes.Buffer{}
+	validationWriter := &common.CodeWriter{
+		Writer: validationBuffer,
+		Tab:    "    ",
+	}
+	writeDeprecated(typeWriter, value.Node)
+	typeWriter.Write(fmt.Sprintf("export type %s = ", renderedName))
+
+	validationWriter.Indent()
+
+	ignore, err := generateType(ctx, &Path{
+		namedPath: []string{"main"},
+	}, value.Node, typeWriter, fileWriter, validationWriter)
+	if err != nil {
+		return nil, err
+	}
+
+	fileWriter.CommonLine()
+	fileWriter.Writer.Write(typeBuffer.Bytes())
+	fileWriter.CommonLine()
+	if ignore {
+		return &declaration{code: fileBuffer.Bytes()}, nil
+	}
+	return &declaration{code: fileBuffer.Bytes(), check: validationBuffer.Bytes()}, nil
+}
+
+// writeCheck writes the check function of a type, as an entry of $checkTable
+// or as an exported function.
+func writeCheck(writer *common.CodeWriter, head string, tail string, renderedName string, result *declaration) {
+	writer.CommonLine()
+	writer.Write(head)
+	writer.Indent()
+	writer.Write("if (main === undefined) return;")
+	if result.enum {
+		writer.CommonLine()
+		writer.Write(fmt.Sprintf("if (!new Set<string>(Object.values(%s)).has(main)) {", renderedName))
+		writer.Indent()
+		validationError(writer, "wrong enum value")
+		writer.Dedent()
+		writer.Write("}")
+	} else {
+		writer.Writer.Write(result.check)
+	}
+	writer.CommonLine()
+	writer.Write("return;")
+	writer.Dedent()
+	writer.Write(tail)
 }
 
-// TODO: from here
+func writeImports(writer io.Writer, imports map[string]map[string]bool) {
+	sortedImports := []string{}
+	for from := range imports {
+		sortedImports = append(sortedImports, from)
+	}
+	sort.Strings(sortedImports)
+	for _, from := range sortedImports {
+		names := []string{}
+		for name := range imports[from] {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		writer.Write([]byte(fmt.Sprintf("import { %s } from \"%s\";\n", strings.Join(names, ", "), from)))
+	}
+	if len(sortedImports) != 0 {
+		writer.Write([]byte("\n"))
+	}
+}
 
-func GenerateCode(types map[string]*common.TypeDesc, config *TypescriptConfig, writer io.Writer) error {
-	for key, value := range types {
+func writeTypelist(writer *common.CodeWriter, ctx *Context, module *ir.Module) {
+	writer.CommonLine()
+	writer.Write("interface $typelist {")
+	writer.Indent()
+	for _, value := range module.Types {
+		if value.Override != nil {
+			continue
+		}
+		writer.CommonLine()
+		writer.Write(fmt.Sprintf("%s: %s;", ctx.names[value], ctx.names[value]))
+	}
+	writer.Dedent()
+	writer.Write("}")
+	writer.CommonLine()
+	writer.CommonLine()
+	writer.Write("interface $typedChecker extends $typedCheckerImpl {")
+	writer.Indent()
+	writer.Write("check<K extends keyof $typelist>(type: K, main: $typelist[K]): void;")
+	writer.Dedent()
+	writer.Write("}")
+	writer.CommonLine()
+}
+
+// GenerateCode writes models.ts, or with config.Split a module per type,
+// schema2code_helper.ts for the helpers and index.ts exporting everything
+// along with $check.
+func GenerateCode(module *ir.Module, config *TypescriptConfig, output common.Sink) error {
+	ctx := Context{
+		names: map[*ir.Type]string{},
+		split: config.Split,
+	}
+	for _, value := range module.Types {
+		if value.Override != nil {
+			ctx.names[value] = value.Override.Type
+			continue
+		}
 		rendered := []string{}
 		for _, it := range value.Path {
 			rendered = append(rendered, formatName(it))
 		}
-		types[key].RenderedName = strings.Join(rendered, "")
+		ctx.names[value] = strings.Join(rendered, "")
+	}
+	if err := checkNames(&ctx, module); err != nil {
+		return err
+	}
+	if config.Split {
+		return generateModules(&ctx, module, output)
 	}
 
+	imports := map[string]map[string]bool{}
 	fileBuffer := &bytes.Buffer{}
 	fileWriter := &common.CodeWriter{
 		Writer: fileBuffer,
 		Tab:    "    ",
 	}
 
-	ctx := Context{}
-
-	sortedType := sortKV{}
-	for name, value := range types {
-		sortedType = append(sortedType, sortableKV{name, value})
-	}
-	sort.Sort(sortedType)
-
 	globalValidationBuffer := &bytes.Buffer{}
 	globalValidationWriter := &common.CodeWriter{
 		Writer: globalValidationBuffer,
@@ -383,111 +803,164 @@ func GenerateCode(types map[string]*common.TypeDesc, config *TypescriptConfig, w
 	globalValidationWriter.Write("const $checkTable: Record<string, (main: any) => void> = {")
 	globalValidationWriter.Indent()
 
-	fileWriter.CommonLine()
-	fileWriter.Write("interface $typelist {")
-	fileWriter.Indent()
-	for _, iter := range sortedType {
-		value := iter.value.(*common.TypeDesc)
-		fileWriter.CommonLine()
-		fileWriter.Write(fmt.Sprintf("%s: %s;", value.RenderedName, value.RenderedName))
-	}
-	fileWriter.Dedent()
-	fileWriter.Write("}")
-	fileWriter.CommonLine()
-	fileWriter.CommonLine()
-	fileWriter.Write("interface $typedChecker extends $typedCheckerImpl {")
-	fileWriter.Indent()
-	fileWriter.Write("check<K extends keyof $typelist>(type: K, main: $typelist[K]): void;")
-	fileWriter.Dedent()
-	fileWriter.Write("}")
-	fileWriter.CommonLine()
+	writeTypelist(fileWriter, &ctx, module)
 
-	for _, iter := range sortedType {
-		value := iter.value.(*common.TypeDesc)
-		if value.Type.Enum != nil {
-			if len(value.Type.Type) != 1 || value.Type.Type[0] != schemas.TypeNameString {
-				return errors.New("only support string enum")
+	for _, value := range module.Types {
+		if value.Override != nil {
+			continue
+		}
+		renderedName := ctx.names[value]
+		result, err := generateDeclaration(&ctx, value)
+		if err != nil {
+			return err
+		}
+		for from, names := range ctx.imports {
+			if imports[from] == nil {
+				imports[from] = map[string]bool{}
 			}
-
-			fileWriter.CommonLine()
-			fileWriter.Write(fmt.Sprintf("export enum %s {", value.RenderedName))
-			fileWriter.Indent()
-			for _, item := range value.Type.Enum {
-				if cased, ok := item.(string); !ok {
-					return errors.New("only support string enum")
-				} else {
-					fileWriter.CommonLine()
-					fileWriter.Write(fmt.Sprintf("%s = \"%s\",", formatName(cased), cased))
-				}
+			for name := range names {
+				imports[from][name] = true
 			}
-			fileWriter.Dedent()
-			fileWriter.Write("}")
-			fileWriter.CommonLine()
-
-			globalValidationWriter.CommonLine()
-			globalValidationWriter.Write(fmt.Sprintf("\"%s\": function (main?: %s) {", value.RenderedName, value.RenderedName))
-			globalValidationWriter.Indent()
-			globalValidationWriter.Write("if (main === undefined) return;")
-			globalValidationWriter.CommonLine()
-			globalValidationWriter.Write(fmt.Sprintf("if (!new Set<string>(Object.values(%s)).has(main)) {", value.RenderedName))
-			globalValidationWriter.Indent()
-			validationError(globalValidationWriter, "wrong enum value")
-			globalValidationWriter.Dedent()
-			globalValidationWriter.Write("}")
-			globalValidationWriter.CommonLine()
-			globalValidationWriter.Write("return;")
-			globalValidationWriter.Dedent()
-			globalValidationWriter.Write("},")
-			continue
 		}
+		fileWriter.Writer.Write(result.code)
+		if result.enum || result.check != nil {
+			writeCheck(globalValidationWriter, fmt.Sprintf("\"%s\": function (main?: %s) {", renderedName, renderedName), "},", renderedName, result)
+		}
+	}
 
-		typeBuffer := &bytes.Buffer{}
-		typeWriter := &common.CodeWriter{
-			Writer: typeBuffer,
-			Tab:    "    ",
+	globalValidationWriter.Dedent()
+	globalValidationWriter.Write("}")
+	globalValidationWriter.CommonLine()
+
+	file, err := output.Create("models.ts")
+	if err != nil {
+		return err
+	}
+	writeImports(file, imports)
+	file.Write(helperCode)
+	file.Write(checkerCode)
+	file.Write(fileBuffer.Bytes())
+	file.Write(globalValidationBuffer.Bytes())
+	return file.Close()
+}
+
+const helperModule = "schema2code_helper"
+
+func generateModules(ctx *Context, module *ir.Module, output common.Sink) error {
+	files := common.FileSet{}
+	files.Add(helperModule+".ts", "the helpers")
+	files.Add("index.ts", "the index")
+	modules := map[*ir.Type]string{}
+	for _, value := range module.Types {
+		if value.Override != nil {
+			continue
 		}
-		validationBuffer := &bytes.Buffer{}
-		validationWriter := &common.CodeWriter{
-			Writer: validationBuffer,
-			Tab:    "    ",
+		modules[value] = common.FileName(value.Path)
+		if err := files.Add(modules[value]+".ts", value.Name); err != nil {
+			return err
 		}
-		typeWriter.Write(fmt.Sprintf("export type %s = ", value.RenderedName))
+	}
 
-		validationWriter.Indent()
+	indexImports := map[string]map[string]bool{}
+	indexBuffer := &bytes.Buffer{}
+	indexWriter := &common.CodeWriter{
+		Writer: indexBuffer,
+		Tab:    "    ",
+	}
+	indexWriter.CommonLine()
+	indexWriter.Write("const $checkTable: Record<string, (main: any) => void> = {")
+	indexWriter.Indent()
 
-		ignore, err := generateType(&ctx, &Path{
-			namedPath: []string{"main"},
-			//quotePath: []string{},
-		}, value.Type, typeWriter, fileWriter, validationWriter)
+	for _, value := range module.Types {
+		if value.Override != nil {
+			continue
+		}
+		renderedName := ctx.names[value]
+		result, err := generateDeclaration(ctx, value)
 		if err != nil {
 			return err
 		}
+		for ref := range ctx.refs {
+			if ref == value {
+				continue
+			}
+			from := "./" + modules[ref]
+			if ctx.imports[from] == nil {
+				ctx.imports[from] = map[string]bool{}
+			}
+			ctx.imports[from][ctx.names[ref]] = true
+			ctx.imports[from]["$check"+ctx.names[ref]] = true
+		}
+		if len(ctx.helpers) != 0 {
+			ctx.imports["./"+helperModule] = ctx.helpers
+		}
 
-		fileWriter.CommonLine()
-		fileWriter.Writer.Write(typeBuffer.Bytes())
-		fileWriter.CommonLine()
-		if !ignore {
-			globalValidationWriter.CommonLine()
-			globalValidationWriter.Write(fmt.Sprintf("\"%s\": function (main?: %s) {", value.RenderedName, value.RenderedName))
-			globalValidationWriter.Indent()
-			globalValidationWriter.Write("if (main === undefined) return;")
+		file, err := output.Create(modules[value] + ".ts")
+		if err != nil {
+			return err
+		}
+		writeImports(file, ctx.imports)
+		file.Write(bytes.TrimLeft(result.code, "\n"))
+		checkWriter := &common.CodeWriter{
+			Writer: file,
+			Tab:    "    ",
+		}
+		writeCheck(checkWriter, fmt.Sprintf("export function $check%s(main?: %s): void {", renderedName, renderedName), "}", renderedName, result)
+		checkWriter.CommonLine()
+		if err := file.Close(); err != nil {
+			return err
+		}
 
-			globalValidationWriter.Writer.Write(validationBuffer.Bytes())
+		indexImports["./"+modules[value]] = map[string]bool{renderedName: true, "$check" + renderedName: true}
+		indexWriter.CommonLine()
+		indexWriter.Write(fmt.Sprintf("\"%s\": $check%s,", renderedName, renderedName))
+	}
+	indexWriter.Dedent()
+	indexWriter.Write("}")
+	indexWriter.CommonLine()
+
+	// the helpers are local functions of models.ts, exported from their module
+	helpers := bytes.ReplaceAll(append([]byte("\n"), helperCode...), []byte("\nfunction "), []byte("\nexport function "))
+	helpers = append(bytes.TrimSpace(helpers), '\n')
+	if err := common.WriteFile(output, helperModule+".ts", helpers); err != nil {
+		return err
+	}
 
-			globalValidationWriter.CommonLine()
-			globalValidationWriter.Write("return;")
-			globalValidationWriter.Dedent()
-			globalValidationWriter.Write("},")
-		}
+	file, err := output.Create("index.ts")
+	if err != nil {
+		return err
+	}
+	writeImports(file, indexImports)
+	sortedModules := []string{}
+	for from := range indexImports {
+		sortedModules = append(sortedModules, from)
+	}
+	sort.Strings(sortedModules)
+	for _, from := range sortedModules {
+		file.Write([]byte(fmt.Sprintf("export * from \"%s\";\n", from)))
 	}
+	file.Write([]byte("\n"))
+	file.Write(checkerCode)
+	typelistWriter := &common.CodeWriter{
+		Writer: file,
+		Tab:    "    ",
+	}
+	writeTypelist(typelistWriter, ctx, module)
+	file.Write(indexBuffer.Bytes())
+	return file.Close()
+}
 
-	globalValidationWriter.Dedent()
-	globalValidationWriter.Write("}")
-	globalValidationWriter.CommonLine()
+// Backend generates TypeScript, with TypescriptConfig as config.
+type Backend struct{}
 
-	writer.Write(helperCode)
-	writer.Write(fileBuffer.Bytes())
-	writer.Write(globalValidationBuffer.Bytes())
+func (Backend) Name() string {
+	return "ts"
+}
 
-	return nil
+func (Backend) NewConfig() interface{} {
+	return &TypescriptConfig{}
+}
+
+func (Backend) Generate(module *ir.Module, config interface{}, output common.Sink) error {
+	return GenerateCode(module, config.(*TypescriptConfig), output)
 }
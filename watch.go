@@ -0,0 +1,102 @@
+package schema2code
+
+import (
+	"context"
+	"os"
+	"time"
+)
+
+// WatchOptions adjust how a project is watched.
+type WatchOptions struct {
+	// Interval between two looks at the files, 300ms by default.
+	Interval time.Duration
+	// Debounce is how long files must stay unchanged before the targets are
+	// generated again, 500ms by default.
+	Debounce time.Duration
+	// Report is called after each generation of a target, with its error.
+	Report func(target *ProjectTarget, err error)
+}
+
+// fileState is what polling compares to notice a change.
+type fileState struct {
+	exists  bool
+	size    int64
+	modTime time.Time
+}
+
+func statFile(fileName string) fileState {
+	info, err := os.Stat(fileName)
+	if err != nil {
+		return fileState{}
+	}
+	return fileState{exists: true, size: info.Size(), modTime: info.ModTime()}
+}
+
+// Watch generates every target, then polls the files each target depends on,
+// its inputs and the documents they reference, even missing ones, and
+// generates again the targets whose files changed. Failures are passed to
+// options.Report and watching goes on. It returns when ctx is done.
+func (p *Project) Watch(ctx context.Context, options *WatchOptions) error {
+	interval := 300 * time.Millisecond
+	debounce := 500 * time.Millisecond
+	report := func(target *ProjectTarget, err error) {}
+	if options != nil {
+		if options.Interval > 0 {
+			interval = options.Interval
+		}
+		if options.Debounce > 0 {
+			debounce = options.Debounce
+		}
+		if options.Report != nil {
+			report = options.Report
+		}
+	}
+
+	files := make([][]string, len(p.Targets))
+	states := map[string]fileState{}
+	regenerate := func(i int) {
+		target := &p.Targets[i]
+		deps, err := p.generateTarget(target)
+		files[i] = deps
+		for _, fileName := range deps {
+			if _, ok := states[fileName]; !ok {
+				states[fileName] = statFile(fileName)
+			}
+		}
+		report(target, err)
+	}
+	for i := range p.Targets {
+		regenerate(i)
+	}
+
+	changed := map[string]bool{}
+	lastChange := time.Time{}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case now := <-ticker.C:
+			for fileName, state := range states {
+				if current := statFile(fileName); current != state {
+					states[fileName] = current
+					changed[fileName] = true
+					lastChange = now
+				}
+			}
+			if len(changed) == 0 || now.Sub(lastChange) < debounce {
+				continue
+			}
+			for i := range p.Targets {
+				for _, fileName := range files[i] {
+					if changed[fileName] {
+						regenerate(i)
+						break
+					}
+				}
+			}
+			changed = map[string]bool{}
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package schema2code
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWatchMissingReference(t *testing.T) {
+	dir := t.TempDir()
+	project := filepath.Join(dir, "schema2code.json")
+	write := func(fileName string, data string) {
+		if err := os.WriteFile(fileName, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(project, `{"inputs": ["a.json"], "targets": [{"lang": "go", "output": "gen/a.go"}]}`)
+	write(filepath.Join(dir, "a.json"), `{"$defs": {"item": {"type": "object", "properties": {"price": {"$ref": "b.json"}}}}}`)
+	loaded, err := LoadProject(project)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reports := make(chan error, 16)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go loaded.Watch(ctx, &WatchOptions{
+		Interval: 10 * time.Millisecond,
+		Debounce: 20 * time.Millisecond,
+		Report: func(target *ProjectTarget, err error) {
+			reports <- err
+		},
+	})
+	if err := <-reports; err == nil {
+		t.Fatal("generated with a missing reference")
+	}
+	write(filepath.Join(dir, "b.json"), `{"type": "number"}`)
+	select {
+	case err := <-reports:
+		if err != nil {
+			t.Errorf("generated again: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("not generated again once the reference exists")
+	}
+}